@@ -0,0 +1,289 @@
+package mpeg2ts
+
+import (
+	"fmt"
+)
+
+// sampling_frequency_index
+// ISO/IEC 14496-3:2019 Table 1.22
+var aacSamplingFrequencies = []int{
+	96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350,
+}
+
+// audio object types
+// ISO/IEC 14496-3:2019 Table 1.17
+const (
+	AACObjectType_Main = 1
+	AACObjectType_LC   = 2
+	AACObjectType_SSR  = 3
+	AACObjectType_LTP  = 4
+	AACObjectType_SBR  = 5
+	AACObjectType_PS   = 29
+)
+
+func aacObjectTypeName(aot uint8) string {
+	switch aot {
+	case AACObjectType_Main:
+		return "Main"
+	case AACObjectType_LC:
+		return "LC"
+	case AACObjectType_SSR:
+		return "SSR"
+	case AACObjectType_LTP:
+		return "LTP"
+	case AACObjectType_SBR:
+		return "HE-AAC"
+	case AACObjectType_PS:
+		return "HE-AACv2"
+	}
+	return fmt.Sprintf("Unknown(%d)", aot)
+}
+
+// ADTS header
+// ISO/IEC 13818-7:2006 pp.21-22, 36-37
+type ADTSHeader struct {
+	Syncword               uint16 // 12
+	ID                     uint8  // 1 0: MPEG-4, 1: MPEG-2
+	Layer                  uint8  // 2
+	ProtectionAbsent       bool   // 1
+	Profile                uint8  // 2 audio object type - 1
+	SamplingFrequencyIndex uint8  // 4
+	PrivateBit             bool   // 1
+	ChannelConfiguration   uint8  // 3
+	OriginalCopy           bool   // 1
+	Home                   bool   // 1
+	CopyrightIDBit         bool   // 1
+	CopyrightIDStart       bool   // 1
+	FrameLength            uint16 // 13 including the header
+	BufferFullness         uint16 // 11
+	NumberOfRawDataBlocks  uint8  // 2 number_of_raw_data_blocks_in_frame + 1
+	CRC                    uint16 // 16 protection_absent == 0
+}
+
+// ParseADTSHeader decodes the ADTS header at the head of b.
+func ParseADTSHeader(b []byte) (ADTSHeader, error) {
+	if len(b) < 7 {
+		return ADTSHeader{}, errAudioNeedMoreData
+	}
+	h := ADTSHeader{}
+	h.Syncword = uint16(b[0])<<4 | uint16(b[1]>>4)                            // 12
+	h.ID = (b[1] >> 3) & 0x01                                                 // 1
+	h.Layer = (b[1] >> 1) & 0x03                                              // 2
+	h.ProtectionAbsent = (b[1] & 0x01) == 1                                   // 1
+	h.Profile = (b[2] >> 6) & 0x03                                            // 2
+	h.SamplingFrequencyIndex = (b[2] >> 2) & 0x0f                             // 4
+	h.PrivateBit = ((b[2] >> 1) & 0x01) == 1                                  // 1
+	h.ChannelConfiguration = (b[2]&0x01)<<2 | (b[3]>>6)&0x03                  // 3
+	h.OriginalCopy = ((b[3] >> 5) & 0x01) == 1                                // 1
+	h.Home = ((b[3] >> 4) & 0x01) == 1                                        // 1
+	h.CopyrightIDBit = ((b[3] >> 3) & 0x01) == 1                              // 1
+	h.CopyrightIDStart = ((b[3] >> 2) & 0x01) == 1                            // 1
+	h.FrameLength = uint16(b[3]&0x03)<<11 | uint16(b[4])<<3 | uint16(b[5]>>5) // 13
+	h.BufferFullness = uint16(b[5]&0x1f)<<6 | uint16(b[6]>>2)                 // 11
+	h.NumberOfRawDataBlocks = (b[6] & 0x03) + 1                               // 2
+	if h.Syncword != 0xfff || h.Layer != 0 || int(h.SamplingFrequencyIndex) >= len(aacSamplingFrequencies) || h.FrameLength < h.HeaderLength() {
+		return ADTSHeader{}, errAudioNoSync
+	}
+	if !h.ProtectionAbsent {
+		if len(b) < 9 {
+			return ADTSHeader{}, errAudioNeedMoreData
+		}
+		h.CRC = uint16(b[7])<<8 | uint16(b[8])
+	}
+	return h, nil
+}
+
+// HeaderLength returns 7, or 9 with CRC.
+func (h *ADTSHeader) HeaderLength() uint16 {
+	if h.ProtectionAbsent {
+		return 7
+	}
+	return 9
+}
+
+func (h *ADTSHeader) SampleRate() int {
+	if int(h.SamplingFrequencyIndex) >= len(aacSamplingFrequencies) {
+		return 0
+	}
+	return aacSamplingFrequencies[h.SamplingFrequencyIndex]
+}
+
+func (h *ADTSHeader) AudioInfo() AudioInfo {
+	info := AudioInfo{
+		Codec:           "AAC",
+		Profile:         aacObjectTypeName(h.Profile + 1),
+		SampleRate:      h.SampleRate(),
+		SamplesPerFrame: 1024 * int(h.NumberOfRawDataBlocks),
+	}
+	if int(h.ChannelConfiguration) < len(aacChannelLayouts) {
+		info.Channels = aacChannelLayouts[h.ChannelConfiguration].channels
+		info.ChannelLayout = aacChannelLayouts[h.ChannelConfiguration].layout
+	}
+	if info.SamplesPerFrame > 0 {
+		info.Bitrate = int(h.FrameLength) * 8 * info.SampleRate / info.SamplesPerFrame
+	}
+	return info
+}
+
+type adtsFrameReader struct{}
+
+func (adtsFrameReader) readFrame(b []byte) (int, AudioInfo, error) {
+	h, err := ParseADTSHeader(b)
+	if err != nil {
+		return 0, AudioInfo{}, err
+	}
+	if len(b) < int(h.FrameLength) {
+		return 0, AudioInfo{}, errAudioNeedMoreData
+	}
+	return int(h.FrameLength), h.AudioInfo(), nil
+}
+
+// NewADTSSplitter returns a splitter for StreamTypeISO13818_7_AudioWithADTS.
+func NewADTSSplitter() AudioSplitter {
+	return newAudioSplitter(adtsFrameReader{})
+}
+
+// AudioSpecificConfig up to the SBR extension
+// ISO/IEC 14496-3:2019 pp.52-53
+type AudioSpecificConfig struct {
+	AudioObjectType        uint8
+	SamplingFrequencyIndex uint8  // 4
+	SamplingFrequency      uint32 // 24
+	ChannelConfiguration   uint8  // 4
+	// explicit SBR or PS signaling
+	ExtensionAudioObjectType        uint8
+	ExtensionSamplingFrequencyIndex uint8  // 4
+	ExtensionSamplingFrequency      uint32 // 24
+}
+
+func readAudioSpecificConfig(br *bitReader) AudioSpecificConfig {
+	asc := AudioSpecificConfig{}
+	readObjectType := func() uint8 {
+		aot := uint8(br.u(5))
+		if aot == 31 {
+			aot = 32 + uint8(br.u(6))
+		}
+		return aot
+	}
+	readFrequency := func() (uint8, uint32) {
+		index := uint8(br.u(4))
+		if index == 0x0f {
+			return index, uint32(br.u(24))
+		}
+		if int(index) < len(aacSamplingFrequencies) {
+			return index, uint32(aacSamplingFrequencies[index])
+		}
+		return index, 0
+	}
+	asc.AudioObjectType = readObjectType()
+	asc.SamplingFrequencyIndex, asc.SamplingFrequency = readFrequency()
+	asc.ChannelConfiguration = uint8(br.u(4))
+	if asc.AudioObjectType == AACObjectType_SBR || asc.AudioObjectType == AACObjectType_PS {
+		asc.ExtensionAudioObjectType = asc.AudioObjectType
+		asc.ExtensionSamplingFrequencyIndex, asc.ExtensionSamplingFrequency = readFrequency()
+		asc.AudioObjectType = readObjectType()
+	}
+	return asc
+}
+
+func (asc *AudioSpecificConfig) AudioInfo(subFrames int) AudioInfo {
+	info := AudioInfo{
+		Codec:           "AAC",
+		Profile:         aacObjectTypeName(asc.AudioObjectType),
+		SampleRate:      int(asc.SamplingFrequency),
+		SamplesPerFrame: 1024 * subFrames,
+	}
+	if asc.ExtensionAudioObjectType != 0 {
+		// output of SBR is twice the core sampling frequency
+		info.Profile = aacObjectTypeName(asc.ExtensionAudioObjectType)
+		info.SampleRate = int(asc.ExtensionSamplingFrequency)
+		info.SamplesPerFrame *= 2
+	}
+	if int(asc.ChannelConfiguration) < len(aacChannelLayouts) {
+		info.Channels = aacChannelLayouts[asc.ChannelConfiguration].channels
+		info.ChannelLayout = aacChannelLayouts[asc.ChannelConfiguration].layout
+	}
+	return info
+}
+
+// StreamMuxConfig of the first program and layer
+// ISO/IEC 14496-3:2019 pp.487-488
+type LATMStreamMuxConfig struct {
+	AudioMuxVersion           uint8 // 1
+	AudioMuxVersionA          uint8 // 1
+	AllStreamsSameTimeFraming bool  // 1
+	NumSubFrames              uint8 // 6 numSubFrames + 1
+	NumProgram                uint8 // 4 numProgram + 1
+	NumLayer                  uint8 // 3 numLayer + 1
+	AudioSpecificConfig
+}
+
+func latmGetValue(br *bitReader) uint64 {
+	bytesForValue := int(br.u(2))
+	return br.u(8 * (bytesForValue + 1))
+}
+
+func readStreamMuxConfig(br *bitReader) (LATMStreamMuxConfig, error) {
+	c := LATMStreamMuxConfig{}
+	c.AudioMuxVersion = uint8(br.u(1))
+	if c.AudioMuxVersion == 1 {
+		c.AudioMuxVersionA = uint8(br.u(1))
+	}
+	if c.AudioMuxVersionA != 0 {
+		return c, fmt.Errorf("unsupported audioMuxVersionA %d", c.AudioMuxVersionA)
+	}
+	if c.AudioMuxVersion == 1 {
+		latmGetValue(br) // taraBufferFullness
+	}
+	c.AllStreamsSameTimeFraming = br.flag()
+	c.NumSubFrames = uint8(br.u(6)) + 1
+	c.NumProgram = uint8(br.u(4)) + 1
+	c.NumLayer = uint8(br.u(3)) + 1
+	if c.AudioMuxVersion == 1 {
+		latmGetValue(br) // ascLen
+	}
+	c.AudioSpecificConfig = readAudioSpecificConfig(br)
+	if br.err != nil {
+		return c, br.err
+	}
+	return c, nil
+}
+
+// latmFrameReader reads AudioSyncStream (LOAS). StreamMuxConfig is kept for
+// AudioMuxElements with useSameStreamMux.
+type latmFrameReader struct {
+	config    LATMStreamMuxConfig
+	hasConfig bool
+}
+
+// ISO/IEC 14496-3:2019 p.485
+func (lr *latmFrameReader) readFrame(b []byte) (int, AudioInfo, error) {
+	if len(b) < 3 {
+		return 0, AudioInfo{}, errAudioNeedMoreData
+	}
+	if b[0] != 0x56 || b[1]&0xe0 != 0xe0 { // syncword 0x2B7
+		return 0, AudioInfo{}, errAudioNoSync
+	}
+	size := 3 + (int(b[1]&0x1f)<<8 | int(b[2])) // audioMuxLengthBytes
+	if len(b) < size {
+		return 0, AudioInfo{}, errAudioNeedMoreData
+	}
+	br := newBitReader(b[3:size])
+	if !br.flag() { // useSameStreamMux
+		c, err := readStreamMuxConfig(br)
+		if err != nil {
+			return 0, AudioInfo{}, errAudioNoSync
+		}
+		lr.config, lr.hasConfig = c, true
+	}
+	if !lr.hasConfig {
+		// frames before the first StreamMuxConfig cannot be decoded
+		return size, AudioInfo{Codec: "AAC"}, nil
+	}
+	return size, lr.config.AudioInfo(int(lr.config.NumSubFrames)), nil
+}
+
+// NewLATMSplitter returns a splitter for StreamTypeISO14496_3_AudioWithLATM in LOAS AudioSyncStream.
+func NewLATMSplitter() AudioSplitter {
+	return newAudioSplitter(&latmFrameReader{})
+}
@@ -0,0 +1,188 @@
+package mpeg2ts
+
+import (
+	"fmt"
+)
+
+const (
+	// ATSC A/52:2018 Annex A
+	StreamTypeATSC_AC3  = StreamType(0x81)
+	StreamTypeATSC_EAC3 = StreamType(0x87)
+)
+
+var (
+	// nominal bitrates in kbit/s by frmsizecod / 2
+	// ATSC A/52:2018 Table 5.18
+	ac3Bitrates    = []int{32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 448, 512, 576, 640}
+	ac3SampleRates = []int{48000, 44100, 32000}
+	// number of full bandwidth channels and audio coding mode by acmod
+	// ATSC A/52:2018 Table 5.8
+	ac3Channels     = []int{2, 1, 2, 3, 3, 4, 4, 5}
+	ac3ChannelModes = []string{"1+1", "1/0", "2/0", "3/0", "2/1", "3/1", "2/2", "3/2"}
+	// number of audio blocks by numblkscod
+	// ATSC A/52:2018 Table E2.3
+	eac3Blocks = []int{1, 2, 3, 6}
+)
+
+// AC-3 and E-AC-3 syncframe header
+// ATSC A/52:2018 pp.37-40 (AC-3), pp.125-128 (E-AC-3)
+type AC3Header struct {
+	Syncword uint16 // 16
+	// AC-3 syncinfo
+	CRC1       uint16 // 16
+	Fscod      uint8  // 2
+	Frmsizecod uint8  // 6
+	// E-AC-3 bsi
+	Strmtyp     uint8  // 2
+	Substreamid uint8  // 3
+	Frmsiz      uint16 // 11
+	Fscod2      uint8  // 2
+	Numblkscod  uint8  // 2
+
+	Bsid  uint8 // 5
+	Bsmod uint8 // 3 AC-3 only
+	Acmod uint8 // 3
+	Lfeon bool  // 1
+}
+
+// IsEAC3 reports whether the syncframe is E-AC-3 (bsid 11-16).
+func (h *AC3Header) IsEAC3() bool {
+	return h.Bsid > 10
+}
+
+// ParseAC3Header decodes the AC-3 or E-AC-3 syncframe header at the head of b.
+func ParseAC3Header(b []byte) (AC3Header, error) {
+	if len(b) < 8 {
+		return AC3Header{}, errAudioNeedMoreData
+	}
+	h := AC3Header{}
+	h.Syncword = uint16(b[0])<<8 | uint16(b[1])
+	if h.Syncword != 0x0b77 {
+		return AC3Header{}, errAudioNoSync
+	}
+	// bsid is at the same position in AC-3 and E-AC-3
+	h.Bsid = b[5] >> 3
+	br := newBitReader(b[2:])
+	switch {
+	case h.Bsid <= 8:
+		h.CRC1 = uint16(br.u(16))
+		h.Fscod = uint8(br.u(2))
+		h.Frmsizecod = uint8(br.u(6))
+		br.skip(5) // bsid
+		h.Bsmod = uint8(br.u(3))
+		h.Acmod = uint8(br.u(3))
+		if h.Acmod&0x01 != 0 && h.Acmod != 1 {
+			br.skip(2) // cmixlev
+		}
+		if h.Acmod&0x04 != 0 {
+			br.skip(2) // surmixlev
+		}
+		if h.Acmod == 2 {
+			br.skip(2) // dsurmod
+		}
+		h.Lfeon = br.flag()
+		if h.Fscod == 3 || int(h.Frmsizecod)/2 >= len(ac3Bitrates) {
+			return AC3Header{}, errAudioNoSync
+		}
+	case h.Bsid >= 11 && h.Bsid <= 16:
+		h.Strmtyp = uint8(br.u(2))
+		h.Substreamid = uint8(br.u(3))
+		h.Frmsiz = uint16(br.u(11))
+		h.Fscod = uint8(br.u(2))
+		if h.Fscod == 3 {
+			h.Fscod2 = uint8(br.u(2))
+			h.Numblkscod = 3
+		} else {
+			h.Numblkscod = uint8(br.u(2))
+		}
+		h.Acmod = uint8(br.u(3))
+		h.Lfeon = br.flag()
+		if h.Strmtyp == 3 || h.Fscod2 == 3 {
+			return AC3Header{}, errAudioNoSync
+		}
+	default:
+		return AC3Header{}, errAudioNoSync
+	}
+	return h, nil
+}
+
+// FrameLength returns the syncframe size in bytes.
+func (h *AC3Header) FrameLength() int {
+	if h.IsEAC3() {
+		return (int(h.Frmsiz) + 1) * 2
+	}
+	kbps := ac3Bitrates[h.Frmsizecod/2]
+	switch h.Fscod {
+	case 0:
+		return kbps * 4
+	case 1:
+		// 44.1kHz frames are padded by one word in odd frmsizecod
+		return (kbps*96000/44100 + int(h.Frmsizecod&0x01)) * 2
+	}
+	return kbps * 6
+}
+
+func (h *AC3Header) SampleRate() int {
+	if h.IsEAC3() && h.Fscod == 3 {
+		return ac3SampleRates[h.Fscod2] / 2
+	}
+	return ac3SampleRates[h.Fscod]
+}
+
+func (h *AC3Header) SamplesPerFrame() int {
+	if h.IsEAC3() {
+		return 256 * eac3Blocks[h.Numblkscod]
+	}
+	return 1536
+}
+
+func (h *AC3Header) AudioInfo() AudioInfo {
+	info := AudioInfo{
+		Codec:           "AC-3",
+		SampleRate:      h.SampleRate(),
+		Channels:        ac3Channels[h.Acmod],
+		ChannelMode:     ac3ChannelModes[h.Acmod],
+		SamplesPerFrame: h.SamplesPerFrame(),
+	}
+	if h.IsEAC3() {
+		info.Codec = "E-AC-3"
+	}
+	lfe := 0
+	if h.Lfeon {
+		lfe = 1
+	}
+	info.Channels += lfe
+	info.ChannelLayout = fmt.Sprintf("%d.%d", ac3Channels[h.Acmod], lfe)
+	if h.Acmod == 0 {
+		info.ChannelLayout = "1+1"
+	}
+	if h.IsEAC3() {
+		info.Bitrate = h.FrameLength() * 8 * info.SampleRate / info.SamplesPerFrame
+	} else {
+		info.Bitrate = ac3Bitrates[h.Frmsizecod/2] * 1000
+	}
+	if h.IsEAC3() && (h.Strmtyp == 1 || h.Substreamid != 0) {
+		// dependent and additional substreams share the time of the independent substream 0
+		info.SamplesPerFrame = 0
+	}
+	return info
+}
+
+type ac3FrameReader struct{}
+
+func (ac3FrameReader) readFrame(b []byte) (int, AudioInfo, error) {
+	h, err := ParseAC3Header(b)
+	if err != nil {
+		return 0, AudioInfo{}, err
+	}
+	size := h.FrameLength()
+	if len(b) < size {
+		return 0, AudioInfo{}, errAudioNeedMoreData
+	}
+	return size, h.AudioInfo(), nil
+}
+
+// NewAC3Splitter returns a splitter for AC-3 and E-AC-3 elementary streams.
+func NewAC3Splitter() AudioSplitter {
+	return newAudioSplitter(ac3FrameReader{})
+}
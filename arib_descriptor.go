@@ -0,0 +1,513 @@
+package mpeg2ts
+
+import (
+	"fmt"
+	"time"
+)
+
+// ARIB STD-B10 Part 2 descriptors
+
+type StreamIdentifierDescriptor struct {
+	// ARIB STD-B10 Part 2 p.56
+	ComponentTag uint8
+}
+
+type DigitalCopyControlDescriptor struct {
+	// ARIB STD-B10 Part 2 pp.67-68
+	DigitalRecordingControlData uint8 // 2
+	MaximumBitrateFlag          bool  // 1
+	ComponentControlFlag        bool  // 1
+	UserDefined                 uint8 // 4
+	MaximumBitrate              uint8 // 8
+	ComponentControlLength      uint8 // 8
+	ComponentControls           []DigitalCopyComponentControl
+}
+
+type DigitalCopyComponentControl struct {
+	ComponentTag                uint8 // 8
+	DigitalRecordingControlData uint8 // 2
+	MaximumBitrateFlag          bool  // 1
+	Reserved                    uint8 // 1
+	UserDefined                 uint8 // 4
+	MaximumBitrate              uint8 // 8
+}
+
+type AudioComponentDescriptor struct {
+	// ARIB STD-B10 Part 2 pp.60-63
+	StreamContent       uint8 // 4
+	ComponentType       uint8 // 8
+	ComponentTag        uint8 // 8
+	StreamType          StreamType
+	SimulcastGroupTag   uint8 // 8
+	ESMultiLingualFlag  bool  // 1
+	MainComponentFlag   bool  // 1
+	QualityIndicator    uint8 // 2
+	SamplingRate        uint8 // 3
+	ISO639LanguageCode  int   // 24
+	ISO639LanguageCode2 int   // 24
+	TextChar            []byte
+
+	Text string
+}
+
+type DataContentDescriptor struct {
+	// ARIB STD-B10 Part 2 pp.64-65
+	DataComponentID    uint16 // 16
+	EntryComponent     uint8  // 8
+	SelectorLength     uint8  // 8
+	SelectorByte       []byte
+	NumOfComponentRef  uint8 // 8
+	ComponentRef       []uint8
+	ISO639LanguageCode int   // 24
+	TextLength         uint8 // 8
+	TextChar           []byte
+
+	Text string
+}
+
+type VideoDecodeControlDescriptor struct {
+	// ARIB STD-B10 Part 2 p.69
+	StillPicture            bool  // 1
+	SequenceEndCodeFlag     bool  // 1
+	VideoEncodeFormat       uint8 // 4
+	TransferCharacteristics uint8 // 2
+}
+
+type TSInformationDescriptor struct {
+	// ARIB STD-B10 Part 2 pp.71-72
+	RemoteControlKeyID    uint8 // 8
+	LengthOfTSName        uint8 // 6
+	TransmissionTypeCount uint8 // 2
+	TSNameChar            []byte
+	TransmissionTypes     []TSInformationTransmissionType
+
+	TSName string
+}
+
+type TSInformationTransmissionType struct {
+	TransmissionTypeInfo uint8 // 8
+	NumOfService         uint8 // 8
+	ServiceIDs           []uint16
+}
+
+type LogoTransmissionDescriptor struct {
+	// ARIB STD-B10 Part 2 pp.73-74
+	LogoTransmissionType uint8  // 8
+	LogoID               uint16 // 9
+	LogoVersion          uint16 // 12
+	DownloadDataID       uint16 // 16
+	LogoChar             []byte
+}
+
+type SeriesDescriptor struct {
+	// ARIB STD-B10 Part 2 pp.76-77
+	SeriesID            uint16 // 16
+	RepeatLabel         uint8  // 4
+	ProgramPattern      uint8  // 3
+	ExpireDateValidFlag bool   // 1
+	RawExpireDate       uint16 // 16
+	EpisodeNumber       uint16 // 12
+	LastEpisodeNumber   uint16 // 12
+	SeriesNameChar      []byte
+
+	ExpireDate time.Time
+	SeriesName string
+}
+
+type EventGroupDescriptor struct {
+	// ARIB STD-B10 Part 2 pp.78-79
+	GroupType          uint8 // 4
+	EventCount         uint8 // 4
+	Events             []EventGroupEvent
+	OtherNetworkEvents []EventGroupOtherNetworkEvent
+	PrivateDataByte    []byte
+}
+
+type EventGroupEvent struct {
+	ServiceID uint16 // 16
+	EventID   uint16 // 16
+}
+
+type EventGroupOtherNetworkEvent struct {
+	OriginalNetworkID uint16 // 16
+	TransportStreamID uint16 // 16
+	ServiceID         uint16 // 16
+	EventID           uint16 // 16
+}
+
+type ComponentGroupDescriptor struct {
+	// ARIB STD-B10 Part 2 pp.83-84
+	ComponentGroupType uint8 // 3
+	TotalBitRateFlag   bool  // 1
+	NumOfGroup         uint8 // 4
+	Groups             []ComponentGroup
+}
+
+type ComponentGroup struct {
+	ComponentGroupID uint8 // 4
+	NumOfCAUnit      uint8 // 4
+	CAUnits          []ComponentGroupCAUnit
+	TotalBitRate     uint8 // 8
+	TextLength       uint8 // 8
+	TextChar         []byte
+
+	Text string
+}
+
+type ComponentGroupCAUnit struct {
+	CAUnitID       uint8 // 4
+	NumOfComponent uint8 // 4
+	ComponentTags  []uint8
+}
+
+type ContentAvailabilityDescriptor struct {
+	// ARIB STD-B10 Part 2 p.93
+	CopyRestrictionMode  bool  // 1
+	ImageConstraintToken bool  // 1
+	RetentionMode        bool  // 1
+	RetentionState       uint8 // 3
+	EncryptionMode       bool  // 1
+}
+
+type ISDBTerrestrialDeliverySystemDescriptor struct {
+	// ARIB STD-B10 Part 2 pp.98-99
+	AreaCode         uint16   // 12
+	GuardInterval    uint8    // 2
+	TransmissionMode uint8    // 2
+	Frequencies      []uint16 // 16 each, 1/7MHz
+
+	FrequenciesHz []uint64
+}
+
+type PartialReceptionDescriptor struct {
+	// ARIB STD-B10 Part 2 p.99
+	ServiceIDs []uint16
+}
+
+// readSIDescriptor decodes descriptors which are defined outside of Rec. ITU-T H.222.0.
+// It returns false when the tag is not supported.
+func readSIDescriptor(ped *ProgramElementDescriptor, payload []byte, index int, opts SIOptions) (bool, error) {
+	if index+2+int(ped.Length) > len(payload) {
+		return false, fmt.Errorf("descriptor(0x%02X) length %d exceeds payload", ped.Tag, ped.Length)
+	}
+	d := payload[index+2 : index+2+int(ped.Length)]
+
+	switch ped.Tag {
+	case 0x52: // stream_identifier_descriptor
+		if len(d) < 1 {
+			return false, errDescriptorTooShort(ped.Tag)
+		}
+		ped.StreamIdentifierDescriptor.ComponentTag = d[0]
+
+	case 0xC1: // digital_copy_control_descriptor
+		if len(d) < 1 {
+			return false, errDescriptorTooShort(ped.Tag)
+		}
+		dcc := &ped.DigitalCopyControlDescriptor
+		dcc.DigitalRecordingControlData = (d[0] >> 6) & 0x03 // 2
+		dcc.MaximumBitrateFlag = ((d[0] >> 5) & 0x01) == 1   // 1
+		dcc.ComponentControlFlag = ((d[0] >> 4) & 0x01) == 1 // 1
+		dcc.UserDefined = d[0] & 0x0f                        // 4
+		i := 1
+		if dcc.MaximumBitrateFlag {
+			if len(d) < i+1 {
+				return false, errDescriptorTooShort(ped.Tag)
+			}
+			dcc.MaximumBitrate = d[i]
+			i++
+		}
+		if dcc.ComponentControlFlag {
+			if len(d) < i+1 {
+				return false, errDescriptorTooShort(ped.Tag)
+			}
+			dcc.ComponentControlLength = d[i]
+			i++
+			end := i + int(dcc.ComponentControlLength)
+			if len(d) < end {
+				return false, errDescriptorTooShort(ped.Tag)
+			}
+			for i+2 <= end {
+				cc := DigitalCopyComponentControl{}
+				cc.ComponentTag = d[i]                                // 8
+				cc.DigitalRecordingControlData = (d[i+1] >> 6) & 0x03 // 2
+				cc.MaximumBitrateFlag = ((d[i+1] >> 5) & 0x01) == 1   // 1
+				cc.Reserved = (d[i+1] >> 4) & 0x01                    // 1
+				cc.UserDefined = d[i+1] & 0x0f                        // 4
+				i += 2
+				if cc.MaximumBitrateFlag && i < end {
+					cc.MaximumBitrate = d[i]
+					i++
+				}
+				dcc.ComponentControls = append(dcc.ComponentControls, cc)
+			}
+		}
+
+	case 0xC4: // audio_component_descriptor
+		if len(d) < 9 {
+			return false, errDescriptorTooShort(ped.Tag)
+		}
+		ac := &ped.AudioComponentDescriptor
+		ac.StreamContent = d[0] & 0x0f                    // 4
+		ac.ComponentType = d[1]                           // 8
+		ac.ComponentTag = d[2]                            // 8
+		ac.StreamType = StreamType(d[3])                  // 8
+		ac.SimulcastGroupTag = d[4]                       // 8
+		ac.ESMultiLingualFlag = ((d[5] >> 7) & 0x01) == 1 // 1
+		ac.MainComponentFlag = ((d[5] >> 6) & 0x01) == 1  // 1
+		ac.QualityIndicator = (d[5] >> 4) & 0x03          // 2
+		ac.SamplingRate = (d[5] >> 1) & 0x07              // 3
+		ac.ISO639LanguageCode = int(d[6])<<16 | int(d[7])<<8 | int(d[8])
+		i := 9
+		if ac.ESMultiLingualFlag {
+			if len(d) < i+3 {
+				return false, errDescriptorTooShort(ped.Tag)
+			}
+			ac.ISO639LanguageCode2 = int(d[i])<<16 | int(d[i+1])<<8 | int(d[i+2])
+			i += 3
+		}
+		ac.TextChar = copyBytes(d[i:])
+		ac.Text = opts.decodeText(ac.TextChar)
+
+	case 0xC7: // data_content_descriptor
+		if len(d) < 4 {
+			return false, errDescriptorTooShort(ped.Tag)
+		}
+		dc := &ped.DataContentDescriptor
+		dc.DataComponentID = uint16(d[0])<<8 | uint16(d[1]) // 16
+		dc.EntryComponent = d[2]                            // 8
+		dc.SelectorLength = d[3]                            // 8
+		i := 4
+		if len(d) < i+int(dc.SelectorLength)+1 {
+			return false, errDescriptorTooShort(ped.Tag)
+		}
+		dc.SelectorByte = copyBytes(d[i : i+int(dc.SelectorLength)])
+		i += int(dc.SelectorLength)
+		dc.NumOfComponentRef = d[i]
+		i++
+		if len(d) < i+int(dc.NumOfComponentRef)+4 {
+			return false, errDescriptorTooShort(ped.Tag)
+		}
+		dc.ComponentRef = copyBytes(d[i : i+int(dc.NumOfComponentRef)])
+		i += int(dc.NumOfComponentRef)
+		dc.ISO639LanguageCode = int(d[i])<<16 | int(d[i+1])<<8 | int(d[i+2])
+		dc.TextLength = d[i+3]
+		i += 4
+		if len(d) < i+int(dc.TextLength) {
+			return false, errDescriptorTooShort(ped.Tag)
+		}
+		dc.TextChar = copyBytes(d[i : i+int(dc.TextLength)])
+		dc.Text = opts.decodeText(dc.TextChar)
+
+	case 0xC8: // video_decode_control_descriptor
+		if len(d) < 1 {
+			return false, errDescriptorTooShort(ped.Tag)
+		}
+		vdc := &ped.VideoDecodeControlDescriptor
+		vdc.StillPicture = ((d[0] >> 7) & 0x01) == 1        // 1
+		vdc.SequenceEndCodeFlag = ((d[0] >> 6) & 0x01) == 1 // 1
+		vdc.VideoEncodeFormat = (d[0] >> 2) & 0x0f          // 4
+		vdc.TransferCharacteristics = d[0] & 0x03           // 2
+
+	case 0xCD: // TS_information_descriptor
+		if len(d) < 2 {
+			return false, errDescriptorTooShort(ped.Tag)
+		}
+		ts := &ped.TSInformationDescriptor
+		ts.RemoteControlKeyID = d[0]           // 8
+		ts.LengthOfTSName = (d[1] >> 2) & 0x3f // 6
+		ts.TransmissionTypeCount = d[1] & 0x03 // 2
+		i := 2
+		if len(d) < i+int(ts.LengthOfTSName) {
+			return false, errDescriptorTooShort(ped.Tag)
+		}
+		ts.TSNameChar = copyBytes(d[i : i+int(ts.LengthOfTSName)])
+		ts.TSName = opts.decodeText(ts.TSNameChar)
+		i += int(ts.LengthOfTSName)
+		for n := 0; n < int(ts.TransmissionTypeCount); n++ {
+			if len(d) < i+2 {
+				return false, errDescriptorTooShort(ped.Tag)
+			}
+			tt := TSInformationTransmissionType{}
+			tt.TransmissionTypeInfo = d[i] // 8
+			tt.NumOfService = d[i+1]       // 8
+			i += 2
+			if len(d) < i+int(tt.NumOfService)*2 {
+				return false, errDescriptorTooShort(ped.Tag)
+			}
+			tt.ServiceIDs = make([]uint16, tt.NumOfService)
+			for j := range tt.ServiceIDs {
+				tt.ServiceIDs[j] = uint16(d[i])<<8 | uint16(d[i+1])
+				i += 2
+			}
+			ts.TransmissionTypes = append(ts.TransmissionTypes, tt)
+		}
+
+	case 0xCF: // logo_transmission_descriptor
+		if len(d) < 1 {
+			return false, errDescriptorTooShort(ped.Tag)
+		}
+		lt := &ped.LogoTransmissionDescriptor
+		lt.LogoTransmissionType = d[0]
+		switch lt.LogoTransmissionType {
+		case 0x01: // CDT transmission type 1
+			if len(d) < 7 {
+				return false, errDescriptorTooShort(ped.Tag)
+			}
+			lt.LogoID = uint16(d[1]&0x01)<<8 | uint16(d[2])      // 9
+			lt.LogoVersion = uint16(d[3]&0x0f)<<8 | uint16(d[4]) // 12
+			lt.DownloadDataID = uint16(d[5])<<8 | uint16(d[6])   // 16
+		case 0x02: // CDT transmission type 2
+			if len(d) < 3 {
+				return false, errDescriptorTooShort(ped.Tag)
+			}
+			lt.LogoID = uint16(d[1]&0x01)<<8 | uint16(d[2]) // 9
+		case 0x03: // simple logo system
+			lt.LogoChar = copyBytes(d[1:])
+		}
+
+	case 0xD5: // series_descriptor
+		if len(d) < 8 {
+			return false, errDescriptorTooShort(ped.Tag)
+		}
+		sd := &ped.SeriesDescriptor
+		sd.SeriesID = uint16(d[0])<<8 | uint16(d[1])               // 16
+		sd.RepeatLabel = (d[2] >> 4) & 0x0f                        // 4
+		sd.ProgramPattern = (d[2] >> 1) & 0x07                     // 3
+		sd.ExpireDateValidFlag = (d[2] & 0x01) == 1                // 1
+		sd.RawExpireDate = uint16(d[3])<<8 | uint16(d[4])          // 16
+		sd.EpisodeNumber = uint16(d[5])<<4 | uint16(d[6]>>4)       // 12
+		sd.LastEpisodeNumber = uint16(d[6]&0x0f)<<8 | uint16(d[7]) // 12
+		sd.SeriesNameChar = copyBytes(d[8:])
+		sd.SeriesName = opts.decodeText(sd.SeriesNameChar)
+		if sd.ExpireDateValidFlag {
+			sd.ExpireDate = getTimestampByMJD(uint64(sd.RawExpireDate)<<24, opts.location())
+		}
+
+	case 0xD6: // event_group_descriptor
+		if len(d) < 1 {
+			return false, errDescriptorTooShort(ped.Tag)
+		}
+		eg := &ped.EventGroupDescriptor
+		eg.GroupType = (d[0] >> 4) & 0x0f // 4
+		eg.EventCount = d[0] & 0x0f       // 4
+		i := 1
+		if len(d) < i+int(eg.EventCount)*4 {
+			return false, errDescriptorTooShort(ped.Tag)
+		}
+		eg.Events = make([]EventGroupEvent, eg.EventCount)
+		for n := range eg.Events {
+			eg.Events[n].ServiceID = uint16(d[i])<<8 | uint16(d[i+1])
+			eg.Events[n].EventID = uint16(d[i+2])<<8 | uint16(d[i+3])
+			i += 4
+		}
+		if eg.GroupType == 4 || eg.GroupType == 5 {
+			for ; i+8 <= len(d); i += 8 {
+				eg.OtherNetworkEvents = append(eg.OtherNetworkEvents, EventGroupOtherNetworkEvent{
+					OriginalNetworkID: uint16(d[i])<<8 | uint16(d[i+1]),
+					TransportStreamID: uint16(d[i+2])<<8 | uint16(d[i+3]),
+					ServiceID:         uint16(d[i+4])<<8 | uint16(d[i+5]),
+					EventID:           uint16(d[i+6])<<8 | uint16(d[i+7]),
+				})
+			}
+		} else {
+			eg.PrivateDataByte = copyBytes(d[i:])
+		}
+
+	case 0xD9: // component_group_descriptor
+		if len(d) < 1 {
+			return false, errDescriptorTooShort(ped.Tag)
+		}
+		cg := &ped.ComponentGroupDescriptor
+		cg.ComponentGroupType = (d[0] >> 5) & 0x07      // 3
+		cg.TotalBitRateFlag = ((d[0] >> 4) & 0x01) == 1 // 1
+		cg.NumOfGroup = d[0] & 0x0f                     // 4
+		i := 1
+		for n := 0; n < int(cg.NumOfGroup); n++ {
+			if len(d) < i+1 {
+				return false, errDescriptorTooShort(ped.Tag)
+			}
+			g := ComponentGroup{}
+			g.ComponentGroupID = (d[i] >> 4) & 0x0f // 4
+			g.NumOfCAUnit = d[i] & 0x0f             // 4
+			i++
+			for c := 0; c < int(g.NumOfCAUnit); c++ {
+				if len(d) < i+1 {
+					return false, errDescriptorTooShort(ped.Tag)
+				}
+				u := ComponentGroupCAUnit{}
+				u.CAUnitID = (d[i] >> 4) & 0x0f // 4
+				u.NumOfComponent = d[i] & 0x0f  // 4
+				i++
+				if len(d) < i+int(u.NumOfComponent) {
+					return false, errDescriptorTooShort(ped.Tag)
+				}
+				u.ComponentTags = copyBytes(d[i : i+int(u.NumOfComponent)])
+				i += int(u.NumOfComponent)
+				g.CAUnits = append(g.CAUnits, u)
+			}
+			if cg.TotalBitRateFlag {
+				if len(d) < i+1 {
+					return false, errDescriptorTooShort(ped.Tag)
+				}
+				g.TotalBitRate = d[i]
+				i++
+			}
+			if len(d) < i+1 {
+				return false, errDescriptorTooShort(ped.Tag)
+			}
+			g.TextLength = d[i]
+			i++
+			if len(d) < i+int(g.TextLength) {
+				return false, errDescriptorTooShort(ped.Tag)
+			}
+			g.TextChar = copyBytes(d[i : i+int(g.TextLength)])
+			g.Text = opts.decodeText(g.TextChar)
+			i += int(g.TextLength)
+			cg.Groups = append(cg.Groups, g)
+		}
+
+	case 0xDE: // content_availability_descriptor
+		if len(d) < 1 {
+			return false, errDescriptorTooShort(ped.Tag)
+		}
+		ca := &ped.ContentAvailabilityDescriptor
+		ca.CopyRestrictionMode = ((d[0] >> 6) & 0x01) == 1  // 1
+		ca.ImageConstraintToken = ((d[0] >> 5) & 0x01) == 1 // 1
+		ca.RetentionMode = ((d[0] >> 4) & 0x01) == 1        // 1
+		ca.RetentionState = (d[0] >> 1) & 0x07              // 3
+		ca.EncryptionMode = (d[0] & 0x01) == 1              // 1
+
+	case 0xFA: // terrestrial_delivery_system_descriptor (ISDB-T)
+		if len(d) < 2 {
+			return false, errDescriptorTooShort(ped.Tag)
+		}
+		td := &ped.ISDBTerrestrialDeliverySystemDescriptor
+		td.AreaCode = uint16(d[0])<<4 | uint16(d[1]>>4) // 12
+		td.GuardInterval = (d[1] >> 2) & 0x03           // 2
+		td.TransmissionMode = d[1] & 0x03               // 2
+		for i := 2; i+2 <= len(d); i += 2 {
+			f := uint16(d[i])<<8 | uint16(d[i+1])
+			td.Frequencies = append(td.Frequencies, f)
+			td.FrequenciesHz = append(td.FrequenciesHz, uint64(f)*1000000/7)
+		}
+
+	case 0xFB: // partial_reception_descriptor
+		for i := 0; i+2 <= len(d); i += 2 {
+			ped.PartialReceptionDescriptor.ServiceIDs = append(ped.PartialReceptionDescriptor.ServiceIDs, uint16(d[i])<<8|uint16(d[i+1]))
+		}
+
+	default:
+		return readDVBDescriptor(ped, d, opts)
+	}
+	return true, nil
+}
+
+func errDescriptorTooShort(tag uint8) error {
+	return fmt.Errorf("descriptor(0x%02X) is too short", tag)
+}
+
+func copyBytes(b []byte) []byte {
+	r := make([]byte, len(b))
+	copy(r, b)
+	return r
+}
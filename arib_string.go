@@ -0,0 +1,427 @@
+package mpeg2ts
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ARIB STD-B24 Vol.1 Part 2 Chapter 7: 8-unit code
+
+var ErrARIBStringTruncated = errors.New("ARIB string is truncated")
+
+// substitution for characters which can not be represented. (GETA MARK)
+const aribUndefinedChar = "〓"
+
+type aribCharset int
+
+const (
+	aribCharsetUnknown aribCharset = iota
+	aribCharsetKanji
+	aribCharsetAlphanumeric
+	aribCharsetHiragana
+	aribCharsetKatakana
+	aribCharsetMosaic
+	aribCharsetJISX0201Katakana
+	aribCharsetJISX0213Plane1
+	aribCharsetJISX0213Plane2
+	aribCharsetAdditionalSymbols
+	aribCharsetDRCS
+	aribCharsetMacro
+)
+
+type aribGraphicSet struct {
+	charset aribCharset
+	bytes   int
+}
+
+// ARIB STD-B24 Vol.1 Part 2 Table 7-3
+func aribGSetByFinal(f byte) aribGraphicSet {
+	switch f {
+	case 0x42:
+		return aribGraphicSet{aribCharsetKanji, 2}
+	case 0x39:
+		return aribGraphicSet{aribCharsetJISX0213Plane1, 2}
+	case 0x3A:
+		return aribGraphicSet{aribCharsetJISX0213Plane2, 2}
+	case 0x3B:
+		return aribGraphicSet{aribCharsetAdditionalSymbols, 2}
+	case 0x4A, 0x36:
+		return aribGraphicSet{aribCharsetAlphanumeric, 1}
+	case 0x30, 0x37:
+		return aribGraphicSet{aribCharsetHiragana, 1}
+	case 0x31, 0x38:
+		return aribGraphicSet{aribCharsetKatakana, 1}
+	case 0x32, 0x33, 0x34, 0x35:
+		return aribGraphicSet{aribCharsetMosaic, 1}
+	case 0x49:
+		return aribGraphicSet{aribCharsetJISX0201Katakana, 1}
+	}
+	return aribGraphicSet{aribCharsetUnknown, 1}
+}
+
+// ARIB STD-B24 Vol.1 Part 2 Table 7-4
+func aribDRCSByFinal(f byte) aribGraphicSet {
+	switch {
+	case f == 0x40:
+		return aribGraphicSet{aribCharsetDRCS, 2}
+	case f >= 0x41 && f <= 0x4F:
+		return aribGraphicSet{aribCharsetDRCS, 1}
+	case f == 0x70:
+		return aribGraphicSet{aribCharsetMacro, 1}
+	}
+	return aribGraphicSet{aribCharsetUnknown, 1}
+}
+
+// ARIB STD-B24 Vol.1 Part 2 Table 7-20
+var aribDefaultMacros = map[byte][]byte{
+	0x60: {0x1B, 0x24, 0x42, 0x1B, 0x29, 0x4A, 0x1B, 0x2A, 0x30, 0x1B, 0x2B, 0x20, 0x70, 0x0F, 0x1B, 0x7D},
+	0x61: {0x1B, 0x24, 0x42, 0x1B, 0x29, 0x31, 0x1B, 0x2A, 0x30, 0x1B, 0x2B, 0x20, 0x70, 0x0F, 0x1B, 0x7D},
+	0x62: {0x1B, 0x24, 0x42, 0x1B, 0x29, 0x20, 0x41, 0x1B, 0x2A, 0x30, 0x1B, 0x2B, 0x20, 0x70, 0x0F, 0x1B, 0x7D},
+	0x63: {0x1B, 0x28, 0x32, 0x1B, 0x29, 0x34, 0x1B, 0x2A, 0x35, 0x1B, 0x2B, 0x20, 0x70, 0x0F, 0x1B, 0x7D},
+	0x64: {0x1B, 0x28, 0x32, 0x1B, 0x29, 0x33, 0x1B, 0x2A, 0x35, 0x1B, 0x2B, 0x20, 0x70, 0x0F, 0x1B, 0x7D},
+	0x65: {0x1B, 0x28, 0x32, 0x1B, 0x29, 0x20, 0x41, 0x1B, 0x2A, 0x35, 0x1B, 0x2B, 0x20, 0x70, 0x0F, 0x1B, 0x7D},
+	0x66: {0x1B, 0x28, 0x20, 0x41, 0x1B, 0x29, 0x20, 0x42, 0x1B, 0x2A, 0x20, 0x43, 0x1B, 0x2B, 0x20, 0x70, 0x0F, 0x1B, 0x7D},
+	0x67: {0x1B, 0x28, 0x20, 0x44, 0x1B, 0x29, 0x20, 0x45, 0x1B, 0x2A, 0x20, 0x46, 0x1B, 0x2B, 0x20, 0x70, 0x0F, 0x1B, 0x7D},
+	0x68: {0x1B, 0x28, 0x20, 0x47, 0x1B, 0x29, 0x20, 0x48, 0x1B, 0x2A, 0x20, 0x49, 0x1B, 0x2B, 0x20, 0x70, 0x0F, 0x1B, 0x7D},
+	0x69: {0x1B, 0x28, 0x20, 0x4A, 0x1B, 0x29, 0x20, 0x4B, 0x1B, 0x2A, 0x20, 0x4C, 0x1B, 0x2B, 0x20, 0x70, 0x0F, 0x1B, 0x7D},
+	0x6A: {0x1B, 0x28, 0x20, 0x4D, 0x1B, 0x29, 0x20, 0x4E, 0x1B, 0x2A, 0x20, 0x4F, 0x1B, 0x2B, 0x20, 0x70, 0x0F, 0x1B, 0x7D},
+	0x6B: {0x1B, 0x24, 0x42, 0x1B, 0x29, 0x20, 0x42, 0x1B, 0x2A, 0x30, 0x1B, 0x2B, 0x20, 0x70, 0x0F, 0x1B, 0x7D},
+	0x6C: {0x1B, 0x24, 0x42, 0x1B, 0x29, 0x20, 0x43, 0x1B, 0x2A, 0x30, 0x1B, 0x2B, 0x20, 0x70, 0x0F, 0x1B, 0x7D},
+	0x6D: {0x1B, 0x24, 0x42, 0x1B, 0x29, 0x20, 0x44, 0x1B, 0x2A, 0x30, 0x1B, 0x2B, 0x20, 0x70, 0x0F, 0x1B, 0x7D},
+	0x6E: {0x1B, 0x28, 0x31, 0x1B, 0x29, 0x30, 0x1B, 0x2A, 0x4A, 0x1B, 0x2B, 0x20, 0x70, 0x0F, 0x1B, 0x7D},
+	0x6F: {0x1B, 0x28, 0x4A, 0x1B, 0x29, 0x32, 0x1B, 0x2A, 0x20, 0x41, 0x1B, 0x2B, 0x20, 0x70, 0x0F, 0x1B, 0x7D},
+}
+
+type aribDecoder struct {
+	g           [4]aribGraphicSet
+	gl          int
+	gr          int
+	singleShift int
+	middleSize  bool
+	out         strings.Builder
+}
+
+func newARIBDecoder() *aribDecoder {
+	d := &aribDecoder{}
+	d.reset()
+	return d
+}
+
+// initial state of SI text. ARIB STD-B24 Vol.1 Part 2 Table 7-1
+func (d *aribDecoder) reset() {
+	d.g[0] = aribGraphicSet{aribCharsetKanji, 2}
+	d.g[1] = aribGraphicSet{aribCharsetAlphanumeric, 1}
+	d.g[2] = aribGraphicSet{aribCharsetHiragana, 1}
+	d.g[3] = aribGraphicSet{aribCharsetKatakana, 1}
+	d.gl = 0
+	d.gr = 2
+	d.singleShift = -1
+	d.middleSize = false
+}
+
+// DecodeARIBString converts an ARIB STD-B24 8-unit coded string to UTF-8.
+// Characters which can not be represented in Unicode are replaced with U+3013.
+// On error, the string decoded so far is returned with the error.
+func DecodeARIBString(b []byte) (string, error) {
+	d := newARIBDecoder()
+	err := d.decode(b, 0)
+	return d.out.String(), err
+}
+
+func (d *aribDecoder) decode(b []byte, depth int) error {
+	for i := 0; i < len(b); {
+		c := b[i]
+		switch {
+		case c == 0x1B: // ESC
+			n, err := d.escape(b[i+1:])
+			if err != nil {
+				return err
+			}
+			i += 1 + n
+		case c == 0x0E: // LS1
+			d.gl = 1
+			i++
+		case c == 0x0F: // LS0
+			d.gl = 0
+			i++
+		case c == 0x19: // SS2
+			d.singleShift = 2
+			i++
+		case c == 0x1D: // SS3
+			d.singleShift = 3
+			i++
+		case c == 0x0D: // APR
+			d.out.WriteString("\n")
+			i++
+		case c == 0x16: // PAPF
+			i += 2
+		case c == 0x1C: // APS
+			i += 3
+		case c == 0x20: // SP
+			if d.middleSize {
+				d.out.WriteString(" ")
+			} else {
+				d.out.WriteString("　")
+			}
+			i++
+		case c < 0x20, c == 0x7F, c == 0xFF:
+			// other C0 controls and DEL
+			i++
+		case c >= 0x80 && c <= 0x9F:
+			n, err := d.control(b[i:])
+			if err != nil {
+				return err
+			}
+			i += n
+		case c == 0xA0:
+			i++
+		default:
+			var gs aribGraphicSet
+			if c < 0x80 {
+				if d.singleShift >= 0 {
+					gs = d.g[d.singleShift]
+					d.singleShift = -1
+				} else {
+					gs = d.g[d.gl]
+				}
+			} else {
+				gs = d.g[d.gr]
+			}
+			if i+gs.bytes > len(b) {
+				return ErrARIBStringTruncated
+			}
+			c1 := b[i] & 0x7F
+			var c2 byte
+			if gs.bytes == 2 {
+				c2 = b[i+1] & 0x7F
+			}
+			i += gs.bytes
+			if gs.charset == aribCharsetMacro {
+				m, ok := aribDefaultMacros[c1]
+				if ok && depth == 0 {
+					if err := d.decode(m, depth+1); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			d.writeChar(gs, c1, c2)
+		}
+	}
+	return nil
+}
+
+// escape handles designation and locking shift sequences following ESC.
+// It returns the number of bytes consumed after ESC.
+func (d *aribDecoder) escape(b []byte) (int, error) {
+	if len(b) < 1 {
+		return 0, ErrARIBStringTruncated
+	}
+	switch b[0] {
+	case 0x6E: // LS2
+		d.gl = 2
+		return 1, nil
+	case 0x6F: // LS3
+		d.gl = 3
+		return 1, nil
+	case 0x7E: // LS1R
+		d.gr = 1
+		return 1, nil
+	case 0x7D: // LS2R
+		d.gr = 2
+		return 1, nil
+	case 0x7C: // LS3R
+		d.gr = 3
+		return 1, nil
+	case 0x28, 0x29, 0x2A, 0x2B: // 1-byte G set or DRCS
+		if len(b) < 2 {
+			return 0, ErrARIBStringTruncated
+		}
+		g := int(b[0] - 0x28)
+		if b[1] == 0x20 {
+			if len(b) < 3 {
+				return 0, ErrARIBStringTruncated
+			}
+			d.g[g] = aribDRCSByFinal(b[2])
+			return 3, nil
+		}
+		d.g[g] = aribGSetByFinal(b[1])
+		return 2, nil
+	case 0x24: // 2-byte G set or DRCS
+		if len(b) < 2 {
+			return 0, ErrARIBStringTruncated
+		}
+		if b[1] >= 0x28 && b[1] <= 0x2B {
+			if len(b) < 3 {
+				return 0, ErrARIBStringTruncated
+			}
+			g := int(b[1] - 0x28)
+			if b[2] == 0x20 {
+				if len(b) < 4 {
+					return 0, ErrARIBStringTruncated
+				}
+				gs := aribDRCSByFinal(b[3])
+				gs.bytes = 2
+				d.g[g] = gs
+				return 4, nil
+			}
+			d.g[g] = aribGSetByFinal(b[2])
+			return 3, nil
+		}
+		d.g[0] = aribGSetByFinal(b[1])
+		return 2, nil
+	}
+	return 0, fmt.Errorf("unsupported escape sequence 0x1B 0x%02X", b[0])
+}
+
+// control skips C1 control codes with their parameters.
+// It returns the number of bytes consumed.
+func (d *aribDecoder) control(b []byte) (int, error) {
+	n := 1
+	switch b[0] {
+	case 0x89: // MSZ
+		d.middleSize = true
+	case 0x8A: // NSZ
+		d.middleSize = false
+	case 0x88: // SSZ
+		d.middleSize = false
+	case 0x8B, 0x91, 0x93, 0x94, 0x97, 0x98: // SZX, FLC, POL, WMM, HLC, RPC
+		n = 2
+	case 0x90, 0x92: // COL, CDC
+		n = 2
+		if len(b) > 1 && b[1] == 0x20 {
+			n = 3
+		}
+	case 0x9D: // TIME
+		n = 3
+	case 0x95: // MACRO. skip definition until MACRO 0x4F
+		for i := 1; i+1 < len(b); i++ {
+			if b[i] == 0x95 && b[i+1] == 0x4F {
+				return i + 2, nil
+			}
+		}
+		return 0, ErrARIBStringTruncated
+	case 0x9B: // CSI. skip until final byte
+		for i := 1; i < len(b); i++ {
+			if b[i] >= 0x40 && b[i] <= 0x6F {
+				return i + 1, nil
+			}
+		}
+		return 0, ErrARIBStringTruncated
+	}
+	if n > len(b) {
+		return 0, ErrARIBStringTruncated
+	}
+	return n, nil
+}
+
+func (d *aribDecoder) writeChar(gs aribGraphicSet, c1, c2 byte) {
+	if c1 < 0x21 || c1 > 0x7E {
+		return
+	}
+	switch gs.charset {
+	case aribCharsetAlphanumeric:
+		if d.middleSize {
+			switch c1 {
+			case 0x5C:
+				d.out.WriteString("¥")
+			case 0x7E:
+				d.out.WriteString("‾")
+			default:
+				d.out.WriteByte(c1)
+			}
+			return
+		}
+		switch c1 {
+		case 0x5C:
+			d.out.WriteString("￥")
+		case 0x7E:
+			d.out.WriteString("￣")
+		default:
+			d.out.WriteRune(rune(0xFF01 + int(c1) - 0x21))
+		}
+	case aribCharsetHiragana:
+		d.out.WriteString(aribKanaChar(c1, 0x3041))
+	case aribCharsetKatakana:
+		d.out.WriteString(aribKanaChar(c1, 0x30A1))
+	case aribCharsetJISX0201Katakana:
+		if c1 <= 0x5F {
+			d.out.WriteRune(rune(0xFF61 + int(c1) - 0x21))
+		} else {
+			d.out.WriteString(aribUndefinedChar)
+		}
+	case aribCharsetKanji, aribCharsetJISX0213Plane1, aribCharsetAdditionalSymbols:
+		if c2 < 0x21 || c2 > 0x7E {
+			d.out.WriteString(aribUndefinedChar)
+			return
+		}
+		code := uint16(c1)<<8 | uint16(c2)
+		if c1 >= 0x75 && gs.charset != aribCharsetJISX0213Plane1 {
+			if s, ok := aribAdditionalSymbols[code]; ok {
+				d.out.WriteString(s)
+			} else {
+				d.out.WriteString(aribUndefinedChar)
+			}
+			return
+		}
+		if s, ok := jisX0213Plane1Sequences[code]; ok {
+			d.out.WriteString(s)
+			return
+		}
+		d.out.WriteString(jisX0213Char(0, c1, c2))
+	case aribCharsetJISX0213Plane2:
+		if c2 < 0x21 || c2 > 0x7E {
+			d.out.WriteString(aribUndefinedChar)
+			return
+		}
+		d.out.WriteString(jisX0213Char(1, c1, c2))
+	default:
+		// mosaic, DRCS and unknown sets
+		d.out.WriteString(aribUndefinedChar)
+	}
+}
+
+// ARIB STD-B24 Vol.1 Part 2 Table 7-7, 7-8
+func aribKanaChar(c byte, base rune) string {
+	switch {
+	case base == 0x3041 && c <= 0x73, base == 0x30A1 && c <= 0x76:
+		return string(base + rune(c) - 0x21)
+	}
+	switch c {
+	case 0x77:
+		return string(base + 0x5C) // ゝ, ヽ
+	case 0x78:
+		return string(base + 0x5D) // ゞ, ヾ
+	case 0x79:
+		return "ー"
+	case 0x7A:
+		return "。"
+	case 0x7B:
+		return "「"
+	case 0x7C:
+		return "」"
+	case 0x7D:
+		return "、"
+	case 0x7E:
+		return "・"
+	}
+	return aribUndefinedChar
+}
+
+var jisX0213Runes [2][94][]rune
+
+func init() {
+	for i := range jisX0213Plane1Rows {
+		jisX0213Runes[0][i] = []rune(jisX0213Plane1Rows[i])
+		jisX0213Runes[1][i] = []rune(jisX0213Plane2Rows[i])
+	}
+}
+
+func jisX0213Char(plane int, c1, c2 byte) string {
+	r := jisX0213Runes[plane][c1-0x21]
+	if int(c2-0x21) >= len(r) || r[c2-0x21] == '\uFFFD' {
+		return aribUndefinedChar
+	}
+	return string(r[c2-0x21])
+}
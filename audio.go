@@ -0,0 +1,194 @@
+package mpeg2ts
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	errAudioNeedMoreData = errors.New("need more data")
+	errAudioNoSync       = errors.New("no sync word")
+)
+
+// AudioInfo is the properties of an audio frame.
+type AudioInfo struct {
+	Codec   string
+	Profile string
+	// in Hz
+	SampleRate int
+	Channels   int
+	// like "2.0" or "5.1". empty if the layout is not signaled in the frame header
+	ChannelLayout string
+	// codec specific channel mode like "Joint stereo" or "3/2"
+	ChannelMode     string
+	SamplesPerFrame int
+	// in bit/s. 0 if the frame header does not signal it
+	Bitrate int
+}
+
+// AudioFrame is a frame of an audio elementary stream.
+type AudioFrame struct {
+	PTS    uint64 // 33
+	HasPTS bool
+	// the frame bytes including the header
+	Data []byte
+	AudioInfo
+}
+
+// audioFrameReader finds the size and the properties of the frame at the head of b.
+// It returns errAudioNoSync if b does not start with a frame and errAudioNeedMoreData
+// if b is shorter than the frame.
+type audioFrameReader interface {
+	readFrame(b []byte) (int, AudioInfo, error)
+}
+
+// AudioSplitter splits an audio elementary stream into frames. A PTS of a PES packet
+// is assigned to the first frame which starts in the PES packet, and the following frames
+// get PTS interpolated from the number of samples.
+type AudioSplitter struct {
+	reader audioFrameReader
+	buffer []byte
+	// PTS of PES packets by the buffer offset where the PES packets start
+	marks   []audioPTSMark
+	lastPTS uint64
+	nextPTS uint64
+	hasNext bool
+	info    AudioInfo
+	hasInfo bool
+	// bytes skipped to find a sync word
+	SkippedBytes int
+	mutex        *sync.Mutex
+}
+
+type audioPTSMark struct {
+	offset int
+	pts    uint64
+}
+
+func newAudioSplitter(reader audioFrameReader) AudioSplitter {
+	as := AudioSplitter{reader: reader}
+	as.mutex = &sync.Mutex{}
+	return as
+}
+
+// EnqueuePES feeds the elementary stream of a PES packet and returns the complete frames.
+func (as *AudioSplitter) EnqueuePES(pes PES) []AudioFrame {
+	return as.Write(pes.ElementaryStream, pes.RawPTS(), pes.PTSFlag)
+}
+
+// Write feeds elementary stream bytes with the PTS of the PES packet which carries them.
+func (as *AudioSplitter) Write(es []byte, pts uint64, hasPTS bool) []AudioFrame {
+	as.mutex.Lock()
+	defer as.mutex.Unlock()
+
+	if hasPTS {
+		as.marks = append(as.marks, audioPTSMark{offset: len(as.buffer), pts: pts})
+	}
+	as.buffer = append(as.buffer, es...)
+
+	frames := []AudioFrame{}
+	offset := 0
+	for offset < len(as.buffer) {
+		size, info, err := as.reader.readFrame(as.buffer[offset:])
+		if err == errAudioNeedMoreData {
+			break
+		}
+		if err != nil {
+			offset++
+			as.SkippedBytes++
+			continue
+		}
+		frame := AudioFrame{Data: copyBytes(as.buffer[offset : offset+size]), AudioInfo: info}
+		frame.PTS, frame.HasPTS = as.framePTS(offset, info.SamplesPerFrame == 0)
+		if frame.HasPTS && info.SampleRate > 0 && info.SamplesPerFrame > 0 {
+			as.lastPTS = frame.PTS
+			as.nextPTS = (frame.PTS + uint64(info.SamplesPerFrame)*PTSClockFrequency/uint64(info.SampleRate)) & (ptsWrap - 1)
+			as.hasNext = true
+		}
+		if info.SamplesPerFrame > 0 || !as.hasInfo {
+			as.info, as.hasInfo = info, true
+		}
+		frames = append(frames, frame)
+		offset += size
+	}
+
+	as.buffer = append(as.buffer[:0], as.buffer[offset:]...)
+	marks := []audioPTSMark{}
+	for _, m := range as.marks {
+		m.offset -= offset
+		if m.offset < 0 {
+			// the PES packet started in the middle of the last frame
+			m.offset = 0
+		}
+		if n := len(marks); n > 0 && marks[n-1].offset == m.offset {
+			marks[n-1] = m
+			continue
+		}
+		marks = append(marks, m)
+	}
+	as.marks = marks
+	return frames
+}
+
+// framePTS consumes the PTS marks up to the frame at offset. A frame without samples
+// of its own, like an E-AC-3 dependent substream, shares the PTS of the previous frame.
+func (as *AudioSplitter) framePTS(offset int, untimed bool) (uint64, bool) {
+	i := 0
+	for i < len(as.marks) && as.marks[i].offset <= offset {
+		i++
+	}
+	if i > 0 {
+		pts := as.marks[i-1].pts
+		as.marks = as.marks[i:]
+		return pts, true
+	}
+	if untimed {
+		return as.lastPTS, as.hasNext
+	}
+	return as.nextPTS, as.hasNext
+}
+
+// Info returns the properties of the last frame.
+func (as *AudioSplitter) Info() (AudioInfo, bool) {
+	as.mutex.Lock()
+	defer as.mutex.Unlock()
+	return as.info, as.hasInfo
+}
+
+// channel layouts of channel_configuration
+// ISO/IEC 14496-3:2019 Table 1.19
+var aacChannelLayouts = []struct {
+	channels int
+	layout   string
+}{
+	{0, ""}, {1, "1.0"}, {2, "2.0"}, {3, "3.0"}, {4, "4.0"}, {5, "5.0"}, {6, "5.1"}, {8, "7.1"},
+}
+
+// NewAudioSplitterForStream returns the splitter for an audio stream of PMT. Streams of
+// StreamTypeISO13818_1_PES are detected by the AC-3 and enhanced AC-3 descriptors of
+// ETSI EN 300 468 or the registration descriptor.
+func NewAudioSplitterForStream(si StreamInfo) (AudioSplitter, bool) {
+	switch si.Type {
+	case StreamTypeISO11172_3_Audio, StreamTypeISO13818_3_Audio:
+		return NewMPEGAudioSplitter(), true
+	case StreamTypeISO13818_7_AudioWithADTS:
+		return NewADTSSplitter(), true
+	case StreamTypeISO14496_3_AudioWithLATM:
+		return NewLATMSplitter(), true
+	case StreamTypeATSC_AC3, StreamTypeATSC_EAC3:
+		return NewAC3Splitter(), true
+	case StreamTypeISO13818_1_PES:
+		for _, d := range si.Descriptors {
+			switch {
+			case d.Tag == 0x6A, d.Tag == 0x7A: // AC-3_descriptor, enhanced_AC-3_descriptor
+				return NewAC3Splitter(), true
+			case d.Tag == 5:
+				switch string(d.RegistrationDescriptor.FormatIdentifier) {
+				case "AC-3", "EAC3":
+					return NewAC3Splitter(), true
+				}
+			}
+		}
+	}
+	return AudioSplitter{}, false
+}
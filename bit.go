@@ -0,0 +1,84 @@
+package mpeg2ts
+
+import (
+	"fmt"
+)
+
+// Broadcaster Information Table
+// ARIB STD-B10 Part 2 pp.33-34
+type BIT struct {
+	TableID                byte   // 8
+	SectionSyntaxIndicator bool   // 1
+	SectionLength          uint16 // 12
+	OriginalNetworkID      uint16 // 16
+	Version                byte   // 5
+	CurrentNextIndicator   bool   // 1
+	SectionNumber          byte   // 8
+	LastSectionNumber      byte   // 8
+	BroadcastViewPropriety bool   // 1
+	FirstDescriptorsLength uint16 // 12
+	Descriptors            []ProgramElementDescriptor
+	Broadcasters           []BITBroadcaster
+	CRC32                  uint // 32
+}
+
+type BITBroadcaster struct {
+	BroadcasterID                uint8  // 8
+	BroadcasterDescriptorsLength uint16 // 12
+	Descriptors                  []ProgramElementDescriptor
+}
+
+func (s *Section) ParseBIT() (BIT, error) {
+	if s.TableID != TableID_BroadcasterInformationSection {
+		return BIT{}, fmt.Errorf("invalid TableID. expected: 0x%02x, actual: 0x%02x", TableID_BroadcasterInformationSection, s.TableID)
+	}
+	if err := s.CheckCRC(); err != nil {
+		return BIT{}, err
+	}
+	bit := BIT{}
+	bit.TableID = s.TableID
+	bit.SectionSyntaxIndicator = s.SectionSyntaxIndicator
+	bit.SectionLength = s.SectionLength
+	bit.OriginalNetworkID = s.TableIDExtension
+	bit.Version = s.Version
+	bit.CurrentNextIndicator = s.CurrentNextIndicator
+	bit.SectionNumber = s.SectionNumber
+	bit.LastSectionNumber = s.LastSectionNumber
+	bit.CRC32 = s.CRC32
+
+	data := s.Data
+	end := len(data) - 4
+	if end < 10 {
+		return BIT{}, ErrSectionTooShort
+	}
+	bit.BroadcastViewPropriety = ((data[8] >> 4) & 0x01) == 1              // 1
+	bit.FirstDescriptorsLength = uint16(data[8]&0x0f)<<8 | uint16(data[9]) // 12
+	index := 10
+	if index+int(bit.FirstDescriptorsLength) > end {
+		return BIT{}, ErrSectionTooShort
+	}
+	var err error
+	var diff int
+	bit.Descriptors, diff, err = readDescriptor(data, index, int(bit.FirstDescriptorsLength), s.SIOptions.orARIB())
+	if err != nil {
+		return BIT{}, err
+	}
+	index += diff
+
+	for index+3 <= end {
+		b := BITBroadcaster{}
+		b.BroadcasterID = data[index]                                                          // 8
+		b.BroadcasterDescriptorsLength = uint16(data[index+1]&0x0f)<<8 | uint16(data[index+2]) // 12
+		index += 3
+		if index+int(b.BroadcasterDescriptorsLength) > end {
+			return BIT{}, ErrSectionTooShort
+		}
+		b.Descriptors, diff, err = readDescriptor(data, index, int(b.BroadcasterDescriptorsLength), s.SIOptions.orARIB())
+		if err != nil {
+			return BIT{}, err
+		}
+		index += diff
+		bit.Broadcasters = append(bit.Broadcasters, b)
+	}
+	return bit, nil
+}
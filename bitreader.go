@@ -0,0 +1,124 @@
+package mpeg2ts
+
+import (
+	"errors"
+)
+
+var ErrBitReaderEOF = errors.New("not enough bits")
+
+// bitReader reads big endian bit fields and Exp-Golomb codes of video bitstreams.
+type bitReader struct {
+	data []byte
+	pos  int // in bits
+	err  error
+}
+
+func newBitReader(b []byte) *bitReader {
+	return &bitReader{data: b}
+}
+
+// u reads n (<= 64) bits. Once the data is exhausted every read returns 0 and err is set.
+func (br *bitReader) u(n int) uint64 {
+	if br.err != nil {
+		return 0
+	}
+	if br.pos+n > len(br.data)*8 {
+		br.err = ErrBitReaderEOF
+		br.pos = len(br.data) * 8
+		return 0
+	}
+	v := uint64(0)
+	for i := 0; i < n; i++ {
+		bit := (br.data[br.pos/8] >> (7 - br.pos%8)) & 0x01
+		v = v<<1 | uint64(bit)
+		br.pos++
+	}
+	return v
+}
+
+func (br *bitReader) flag() bool {
+	return br.u(1) == 1
+}
+
+func (br *bitReader) skip(n int) {
+	br.u(n)
+}
+
+// ue reads ue(v). Rec. ITU-T H.264 (08/2021) 9.1
+func (br *bitReader) ue() uint64 {
+	leadingZeroBits := 0
+	for !br.flag() {
+		if br.err != nil || leadingZeroBits > 32 {
+			if br.err == nil {
+				br.err = ErrBitReaderEOF
+			}
+			return 0
+		}
+		leadingZeroBits++
+	}
+	return (1<<leadingZeroBits - 1) + br.u(leadingZeroBits)
+}
+
+// se reads se(v). Rec. ITU-T H.264 (08/2021) 9.1.1
+func (br *bitReader) se() int64 {
+	k := br.ue()
+	if k%2 == 1 {
+		return int64((k + 1) / 2)
+	}
+	return -int64(k / 2)
+}
+
+func (br *bitReader) bitsLeft() int {
+	return len(br.data)*8 - br.pos
+}
+
+// splitAnnexB splits a byte stream into NAL units without start codes.
+// Rec. ITU-T H.264 (08/2021) Annex B
+func splitAnnexB(es []byte) [][]byte {
+	units := [][]byte{}
+	start := -1
+	for i := 0; i+2 < len(es); i++ {
+		if es[i] != 0x00 || es[i+1] != 0x00 || es[i+2] != 0x01 {
+			continue
+		}
+		if start >= 0 {
+			units = appendNALUnit(units, es[start:i])
+		}
+		start = i + 3
+		i += 2
+	}
+	if start >= 0 && start < len(es) {
+		units = appendNALUnit(units, es[start:])
+	}
+	return units
+}
+
+// appendNALUnit drops trailing_zero_8bits and the leading zero of a 4 byte start code.
+func appendNALUnit(units [][]byte, nal []byte) [][]byte {
+	for len(nal) > 0 && nal[len(nal)-1] == 0x00 {
+		nal = nal[:len(nal)-1]
+	}
+	if len(nal) > 0 {
+		units = append(units, nal)
+	}
+	return units
+}
+
+// unescapeRBSP removes emulation_prevention_three_byte.
+func unescapeRBSP(nal []byte) []byte {
+	rbsp := make([]byte, 0, len(nal))
+	zeros := 0
+	for _, b := range nal {
+		if zeros >= 2 && b == 0x03 {
+			zeros = 0
+			continue
+		}
+		if b == 0x00 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		rbsp = append(rbsp, b)
+	}
+	return rbsp
+}
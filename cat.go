@@ -0,0 +1,148 @@
+package mpeg2ts
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Conditional Access Table
+// Rec. ITU-T H.222.0 (06-2021) pp.56-57
+type CAT struct {
+	TableID                byte   // 8
+	SectionSyntaxIndicator bool   // 1
+	SectionLength          uint16 // 12
+	Version                byte   // 5
+	CurrentNextIndicator   bool   // 1
+	SectionNumber          byte   // 8
+	LastSectionNumber      byte   // 8
+	Descriptors            []ProgramElementDescriptor
+	CRC32                  uint // 32
+}
+
+// ProgramCA lists the CA systems which protect a program.
+type ProgramCA struct {
+	ProgramNumber uint16
+	Systems       []ProgramCASystem
+	// ECM and EMM PIDs which must be kept when extracting or remuxing the program
+	KeepPIDs []PID
+}
+
+type ProgramCASystem struct {
+	CASystemID uint16
+	ECMPID     PID
+	// EMM PIDs of the same CA_system_ID in CAT
+	EMMPIDs []PID
+	// elementary PIDs scrambled under ECMPID
+	ProtectedPIDs []PID
+}
+
+func (s *Section) ParseCAT() (CAT, error) {
+	if s.TableID != TableID_ConditionalAccessSection {
+		return CAT{}, fmt.Errorf("invalid TableID. expected: 0x%02x, actual: 0x%02x", TableID_ConditionalAccessSection, s.TableID)
+	}
+	if err := s.CheckCRC(); err != nil {
+		return CAT{}, err
+	}
+	cat := CAT{}
+	cat.TableID = s.TableID
+	cat.SectionSyntaxIndicator = s.SectionSyntaxIndicator
+	cat.SectionLength = s.SectionLength
+	cat.Version = s.Version
+	cat.CurrentNextIndicator = s.CurrentNextIndicator
+	cat.SectionNumber = s.SectionNumber
+	cat.LastSectionNumber = s.LastSectionNumber
+	cat.CRC32 = s.CRC32
+
+	data := s.Data
+	end := len(data) - 4
+	if end < 8 {
+		return CAT{}, ErrSectionTooShort
+	}
+	var err error
+	cat.Descriptors, _, err = readDescriptor(data, 8, end-8, s.SIOptions)
+	if err != nil {
+		return CAT{}, err
+	}
+	return cat, nil
+}
+
+// EMMPIDs returns EMM PIDs by CA_system_ID.
+func (cat *CAT) EMMPIDs() map[uint16][]PID {
+	emms := map[uint16][]PID{}
+	for _, d := range cat.Descriptors {
+		if d.Tag == 9 {
+			emms[d.CADescriptor.CASystemID] = append(emms[d.CADescriptor.CASystemID], d.CADescriptor.CAPID)
+		}
+	}
+	return emms
+}
+
+// ProgramCAInfo maps the CA_descriptors of pmt to the streams they protect.
+// A CA_descriptor in the program loop protects every elementary stream of the program.
+func ProgramCAInfo(pmt PMT, cats ...CAT) ProgramCA {
+	emms := map[uint16][]PID{}
+	for _, cat := range cats {
+		for id, pids := range cat.EMMPIDs() {
+			emms[id] = append(emms[id], pids...)
+		}
+	}
+
+	type systemKey struct {
+		caSystemID uint16
+		ecmPID     PID
+	}
+	pca := ProgramCA{ProgramNumber: pmt.ProgramNumber}
+	indexes := map[systemKey]int{}
+	add := func(d CADescriptor, pids []PID) {
+		key := systemKey{d.CASystemID, d.CAPID}
+		i, ok := indexes[key]
+		if !ok {
+			pca.Systems = append(pca.Systems, ProgramCASystem{
+				CASystemID: d.CASystemID,
+				ECMPID:     d.CAPID,
+				EMMPIDs:    uniquePIDs(emms[d.CASystemID]),
+			})
+			i = len(pca.Systems) - 1
+			indexes[key] = i
+		}
+		pca.Systems[i].ProtectedPIDs = uniquePIDs(append(pca.Systems[i].ProtectedPIDs, pids...))
+	}
+
+	allPIDs := []PID{}
+	for _, si := range pmt.Streams {
+		allPIDs = append(allPIDs, si.ElementaryPID)
+	}
+	for _, d := range pmt.Descriptors {
+		if d.Tag == 9 {
+			add(d.CADescriptor, allPIDs)
+		}
+	}
+	for _, si := range pmt.Streams {
+		for _, d := range si.Descriptors {
+			if d.Tag == 9 {
+				add(d.CADescriptor, []PID{si.ElementaryPID})
+			}
+		}
+	}
+
+	keep := []PID{}
+	for _, sys := range pca.Systems {
+		keep = append(keep, sys.ECMPID)
+		keep = append(keep, sys.EMMPIDs...)
+	}
+	pca.KeepPIDs = uniquePIDs(keep)
+	return pca
+}
+
+func uniquePIDs(pids []PID) []PID {
+	seen := map[PID]bool{}
+	r := []PID{}
+	for _, p := range pids {
+		if !seen[p] {
+			seen[p] = true
+			r = append(r, p)
+		}
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i] < r[j] })
+	return r
+}
@@ -0,0 +1,234 @@
+package mpeg2ts
+
+import (
+	"errors"
+)
+
+var ErrMuxerFailed = errors.New("muxer has stopped by a previous error")
+
+// cbrScheduler assigns packets to the slots of a constant bitrate stream. The system time clock
+// of a slot is derived from the byte position, and PCR is the time of its byte which carries the
+// last bit of program_clock_reference_base.
+type cbrScheduler struct {
+	// remainder of the clock of the next slot in 1/MuxRate of 27MHz
+	remainder int64
+	psi       [][]byte
+	streams   []*cbrStream
+	// packets of the current call. they are written after the T-STD model accepts them
+	out [][]byte
+	err error
+}
+
+type cbrStream struct {
+	pid     PID
+	packets []cbrPacket
+	model   tstdModel
+}
+
+type cbrPacket struct {
+	data []byte
+	// bytes which are not delivered to MB: the packet header, the adaptation field and the PES header
+	discard int
+	es      int
+	// decoding time of the access unit
+	clock int64
+}
+
+// writeAccessUnitCBR queues the packets of the access unit and fills the slots up to the system
+// time clock PCRDelay before its DTS. In each slot a due PCR, then a due PSI, then the packet of
+// the earliest decoding time which the T-STD buffers of its stream can receive is sent, and
+// null packets fill the rest. PSI is not modelled in the T-STD.
+func (mx *Muxer) writeAccessUnitCBR(s *muxerStream, au AccessUnit, pes []byte) error {
+	if mx.cbr != nil && mx.cbr.err != nil {
+		return ErrMuxerFailed
+	}
+	first := !mx.hasDTS
+	dts, ok := mx.extendDTS(au)
+	if first {
+		mx.clock = mx.clockTarget()
+	}
+	if !mx.started {
+		mx.started = true
+		mx.lastPSI = mx.clock
+	}
+	if mx.cbr == nil {
+		mx.cbr = &cbrScheduler{}
+	}
+	if !ok {
+		// decoded not later than the access units which have come
+		dts = mx.extendedDTS
+	}
+
+	cs := mx.cbr.stream(mx, s)
+	cs.model.addAccessUnit(dts*300, len(au.Data))
+	pesHeaderLength := 9 + int(pes[8])
+	af := muxerAdaptationField{randomAccess: au.RandomAccess}
+	for offset := 0; offset == 0 || offset < len(pes); {
+		// continuity_counter is given when the packet is sent
+		b, n := buildTSPacket(au.PID, offset == 0, 0, af, pes[offset:])
+		af = muxerAdaptationField{}
+		header := pesHeaderLength - offset
+		if header < 0 {
+			header = 0
+		} else if header > n {
+			header = n
+		}
+		cs.packets = append(cs.packets, cbrPacket{data: b, discard: PacketSizeDefault - n + header, es: n - header, clock: dts * 300})
+		offset += n
+	}
+	return mx.fillSlots(mx.clockTarget(), false)
+}
+
+// Close sends the packets queued in the constant bitrate mode.
+func (mx *Muxer) Close() error {
+	mx.mutex.Lock()
+	defer mx.mutex.Unlock()
+	if mx.cbr == nil {
+		return nil
+	}
+	if mx.cbr.err != nil {
+		return ErrMuxerFailed
+	}
+	return mx.fillSlots(0, true)
+}
+
+func (cs *cbrScheduler) stream(mx *Muxer, s *muxerStream) *cbrStream {
+	for _, st := range cs.streams {
+		if st.pid == s.PID {
+			return st
+		}
+	}
+	buffer := s.TSTD
+	if buffer == (TSTDBuffer{}) {
+		buffer = DefaultTSTDBuffer(s.Type, 0)
+	}
+	st := &cbrStream{pid: s.PID, model: newTSTDModel(s.PID, buffer, mx.clock)}
+	cs.streams = append(cs.streams, st)
+	return st
+}
+
+// fillSlots sends packets until the clock reaches target, or until all the queued packets are sent
+// if drain is set. Nothing is written if the T-STD model reports a violation.
+func (mx *Muxer) fillSlots(target int64, drain bool) error {
+	cs := mx.cbr
+	cs.out = cs.out[:0]
+	for (!drain && mx.clock < target) || (drain && cs.pending()) {
+		if err := mx.fillSlot(); err != nil {
+			cs.err = err
+			cs.out = nil
+			return err
+		}
+	}
+	for _, b := range cs.out {
+		if err := mx.write(b); err != nil {
+			cs.err = err
+			return err
+		}
+	}
+	cs.out = cs.out[:0]
+	return nil
+}
+
+func (cs *cbrScheduler) pending() bool {
+	if len(cs.psi) > 0 {
+		return true
+	}
+	for _, st := range cs.streams {
+		if len(st.packets) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// fillSlot sends a packet at the current clock and moves the clock to the next slot.
+func (mx *Muxer) fillSlot() error {
+	cs := mx.cbr
+	for _, st := range cs.streams {
+		if v := st.model.advance(mx.clock); v != nil {
+			return v
+		}
+	}
+	next, _ := mx.slotClock(PacketSizeDefault)
+
+	var packet []byte
+	var receiver *cbrStream
+	discard, es := PacketSizeDefault, 0
+	for _, prog := range mx.programs {
+		if prog.hasPCR && next-prog.lastPCR <= durationToClock(mx.PCRInterval) {
+			continue
+		}
+		// PCR is the time of the 11th byte
+		pcr, _ := mx.slotClock(11)
+		packet = mx.pcrOnlyPacket(prog, pcr)
+		prog.lastPCR, prog.hasPCR = pcr, true
+		if s, ok := mx.streams[prog.PCRPID]; ok {
+			receiver = cs.stream(mx, s)
+		}
+		break
+	}
+	if packet == nil && len(cs.psi) == 0 && (mx.psiDue || mx.clock-mx.lastPSI >= durationToClock(mx.PSIInterval)) {
+		cs.psi = mx.psiPackets()
+	}
+	if packet == nil && len(cs.psi) > 0 {
+		packet = cs.psi[0]
+		cs.psi = cs.psi[1:]
+	}
+	if packet == nil {
+		for _, st := range cs.streams {
+			if len(st.packets) == 0 || !st.model.canReceive(st.packets[0].discard+mx.pcrRoom(st, next), st.packets[0].es) {
+				continue
+			}
+			if receiver == nil || st.packets[0].clock < receiver.packets[0].clock {
+				receiver = st
+			}
+		}
+		if receiver != nil {
+			p := receiver.packets[0]
+			receiver.packets = receiver.packets[1:]
+			packet, discard, es = p.data, p.discard, p.es
+			packet[3] |= mx.nextCC(receiver.pid, true)
+		}
+	}
+	if packet == nil {
+		packet = nullPacket()
+	}
+	if receiver != nil {
+		if v := receiver.model.receive(discard, es); v != nil {
+			return v
+		}
+	}
+	cs.out = append(cs.out, packet)
+	mx.clock, cs.remainder = mx.slotClock(PacketSizeDefault)
+	return nil
+}
+
+// pcrRoom returns the bytes to be left in TB of the stream for the PCR only packet of its
+// program, which is due before TB can drain a packet.
+func (mx *Muxer) pcrRoom(st *cbrStream, next int64) int {
+	s, ok := mx.streams[st.pid]
+	if !ok || s.program.PCRPID != st.pid || !s.program.hasPCR || st.model.Rx <= 0 {
+		return 0
+	}
+	drain := int64(st.model.TBSize) * 8 * SystemClockFrequency / int64(st.model.Rx)
+	if s.program.lastPCR+durationToClock(mx.PCRInterval)-next > drain {
+		return 0
+	}
+	return PacketSizeDefault
+}
+
+// slotClock returns the clock n bytes after the start of the current slot and its remainder.
+func (mx *Muxer) slotClock(n int) (int64, int64) {
+	rate := int64(mx.MuxRate)
+	num := int64(n)*8*SystemClockFrequency + mx.cbr.remainder
+	return mx.clock + num/rate, num % rate
+}
+
+func nullPacket() []byte {
+	b := make([]byte, PacketSizeDefault)
+	for i := range b {
+		b[i] = 0xff
+	}
+	writeTSHeader(b, PID_NullPacket, false, AdaptationField_PayloadOnly, 0)
+	return b
+}
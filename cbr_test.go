@@ -0,0 +1,107 @@
+package mpeg2ts
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// cbrMuxer returns a constant bitrate muxer of a program of the stream.
+func cbrMuxer(t *testing.T, muxRate int, stream MuxerStream) (*Muxer, *bytes.Buffer) {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	mx := NewMuxer(buf)
+	mx.MuxRate = muxRate
+	err := mx.AddProgram(MuxerProgram{ProgramNumber: 1, PMTPID: fixturePMTPID, Streams: []MuxerStream{stream}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &mx, buf
+}
+
+// writeUntilViolation writes access units of size bytes every 1920 ticks of 90kHz until the muxer
+// fails, and checks that nothing of the failing access unit is written.
+func writeUntilViolation(t *testing.T, mx *Muxer, buf *bytes.Buffer, pid PID, size int) *TSTDViolation {
+	t.Helper()
+	for i := 0; i < 500; i++ {
+		written := buf.Len()
+		err := mx.WriteAccessUnit(AccessUnit{PID: pid, PTS: uint64(90000 + i*1920), HasPTS: true, Data: fixturePayload(size, byte(i))})
+		if err == nil {
+			continue
+		}
+		if buf.Len() != written {
+			t.Errorf("%d bytes are written by the failing access unit", buf.Len()-written)
+		}
+		v := &TSTDViolation{}
+		if !errors.As(err, &v) {
+			t.Fatalf("access unit %d: %v", i, err)
+		}
+		err = mx.WriteAccessUnit(AccessUnit{PID: pid, PTS: uint64(90000 + (i+1)*1920), HasPTS: true, Data: fixturePayload(size, 0)})
+		if !errors.Is(err, ErrMuxerFailed) {
+			t.Errorf("after the violation: %v", err)
+		}
+		return v
+	}
+	t.Fatal("no violation")
+	return nil
+}
+
+func TestMuxerCBRLowMuxRate(t *testing.T) {
+	// 375 kbit/s of audio does not fit in 200 kbit/s
+	mx, buf := cbrMuxer(t, 200000, MuxerStream{PID: fixtureAudioPID, Type: StreamTypeISO13818_7_AudioWithADTS})
+	v := writeUntilViolation(t, mx, buf, fixtureAudioPID, 1000)
+	if v.PID != fixtureAudioPID || v.Buffer != "B" || !v.Underflow || v.Size != 1000 {
+		t.Errorf("%+v", *v)
+	}
+}
+
+func TestMuxerCBRLargeAccessUnit(t *testing.T) {
+	tstd := DefaultTSTDBuffer(StreamTypeAVC, 2000000)
+	mx, buf := cbrMuxer(t, 20000000, MuxerStream{PID: fixtureVideoPID, Type: StreamTypeAVC, TSTD: tstd})
+	v := writeUntilViolation(t, mx, buf, fixtureVideoPID, tstd.EBSize+1000)
+	// the access unit can not be in EB as a whole at its decoding time
+	if v.PID != fixtureVideoPID || v.Buffer != "EB" || !v.Underflow || v.Size != tstd.EBSize+1000 || v.Level > tstd.EBSize {
+		t.Errorf("%+v", *v)
+	}
+}
+
+func TestMuxerCBRPCRInterval(t *testing.T) {
+	const muxRate = 1000000
+	mx, buf := cbrMuxer(t, muxRate, MuxerStream{PID: fixtureAudioPID, Type: StreamTypeISO13818_7_AudioWithADTS})
+	for i := 0; i < 100; i++ {
+		au := AccessUnit{PID: fixtureAudioPID, PTS: uint64(90000 + i*1920), HasPTS: true, Data: fixturePayload(300, byte(i))}
+		if err := mx.WriteAccessUnit(au); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mx.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	pl, _ := NewPacketList(PacketSizeDefault)
+	for b := buf.Bytes(); len(b) > 0; b = b[PacketSizeDefault:] {
+		if err := pl.AddBytes(b[:PacketSizeDefault], PacketSizeDefault); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var pcrs, positions []int64
+	for i, p := range pl.All() {
+		if p.HasAdaptationField() && p.AdaptationField.PCRFlag {
+			pcrs = append(pcrs, pcrValue(p.AdaptationField.ProgramClockReference))
+			positions = append(positions, int64(i))
+		}
+	}
+	if len(pcrs) < 50 {
+		t.Fatalf("%d PCRs", len(pcrs))
+	}
+	for i := 1; i < len(pcrs); i++ {
+		if d := pcrs[i] - pcrs[i-1]; d <= 0 || d > durationToClock(mx.PCRInterval) {
+			t.Errorf("PCR %d: interval %d", i, d)
+		}
+		// PCR follows MuxRate
+		want := (positions[i] - positions[i-1]) * PacketSizeDefault * 8 * SystemClockFrequency / muxRate
+		if d := pcrs[i] - pcrs[i-1] - want; d < -1 || d > 1 {
+			t.Errorf("PCR %d: %d off the mux rate", i, d)
+		}
+	}
+}
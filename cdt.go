@@ -0,0 +1,101 @@
+package mpeg2ts
+
+import (
+	"fmt"
+)
+
+const (
+	// ARIB STD-B21 p.148
+	CDTDataType_Logo = 0x01
+)
+
+// Common Data Table
+// ARIB STD-B10 Part 2 p.44
+type CDT struct {
+	TableID                byte   // 8
+	SectionSyntaxIndicator bool   // 1
+	SectionLength          uint16 // 12
+	DownloadDataID         uint16 // 16
+	Version                byte   // 5
+	CurrentNextIndicator   bool   // 1
+	SectionNumber          byte   // 8
+	LastSectionNumber      byte   // 8
+	OriginalNetworkID      uint16 // 16
+	DataType               uint8  // 8
+	DescriptorsLoopLength  uint16 // 12
+	Descriptors            []ProgramElementDescriptor
+	DataModuleByte         []byte
+	CRC32                  uint // 32
+}
+
+// logo data carried in data_module_byte when data_type is 0x01
+// ARIB STD-B21 p.148
+type CDTLogo struct {
+	LogoType    uint8  // 8
+	LogoID      uint16 // 9
+	LogoVersion uint16 // 12
+	DataSize    uint16 // 16
+	Data        []byte
+}
+
+func (s *Section) ParseCDT() (CDT, error) {
+	if s.TableID != TableID_CommonDataSection {
+		return CDT{}, fmt.Errorf("invalid TableID. expected: 0x%02x, actual: 0x%02x", TableID_CommonDataSection, s.TableID)
+	}
+	if err := s.CheckCRC(); err != nil {
+		return CDT{}, err
+	}
+	cdt := CDT{}
+	cdt.TableID = s.TableID
+	cdt.SectionSyntaxIndicator = s.SectionSyntaxIndicator
+	cdt.SectionLength = s.SectionLength
+	cdt.DownloadDataID = s.TableIDExtension
+	cdt.Version = s.Version
+	cdt.CurrentNextIndicator = s.CurrentNextIndicator
+	cdt.SectionNumber = s.SectionNumber
+	cdt.LastSectionNumber = s.LastSectionNumber
+	cdt.CRC32 = s.CRC32
+
+	data := s.Data
+	end := len(data) - 4
+	if end < 13 {
+		return CDT{}, ErrSectionTooShort
+	}
+	cdt.OriginalNetworkID = uint16(data[8])<<8 | uint16(data[9])
+	cdt.DataType = data[10]
+	cdt.DescriptorsLoopLength = uint16(data[11]&0x0f)<<8 | uint16(data[12])
+	index := 13
+	if index+int(cdt.DescriptorsLoopLength) > end {
+		return CDT{}, ErrSectionTooShort
+	}
+	var err error
+	var diff int
+	cdt.Descriptors, diff, err = readDescriptor(data, index, int(cdt.DescriptorsLoopLength), s.SIOptions.orARIB())
+	if err != nil {
+		return CDT{}, err
+	}
+	index += diff
+	cdt.DataModuleByte = copyBytes(data[index:end])
+	return cdt, nil
+}
+
+// Logo decodes DataModuleByte as a logo data module.
+func (cdt *CDT) Logo() (CDTLogo, error) {
+	if cdt.DataType != CDTDataType_Logo {
+		return CDTLogo{}, fmt.Errorf("data_type 0x%02x is not logo data", cdt.DataType)
+	}
+	b := cdt.DataModuleByte
+	if len(b) < 7 {
+		return CDTLogo{}, ErrSectionTooShort
+	}
+	logo := CDTLogo{}
+	logo.LogoType = b[0]
+	logo.LogoID = uint16(b[1]&0x01)<<8 | uint16(b[2])
+	logo.LogoVersion = uint16(b[3]&0x0f)<<8 | uint16(b[4])
+	logo.DataSize = uint16(b[5])<<8 | uint16(b[6])
+	if len(b) < 7+int(logo.DataSize) {
+		return CDTLogo{}, ErrSectionTooShort
+	}
+	logo.Data = copyBytes(b[7 : 7+int(logo.DataSize)])
+	return logo, nil
+}
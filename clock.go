@@ -0,0 +1,315 @@
+package mpeg2ts
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// system clock frequency 27MHz
+	// Rec. ITU-T H.222.0 (06/2021) p.30
+	SystemClockFrequency = 27000000
+	// PTS/DTS and PCR base resolution 90kHz
+	PTSClockFrequency = 90000
+
+	pcrWrap = (1 << 33) * 300
+	ptsWrap = 1 << 33
+	// PCR is sent at least every 100ms, but gaps of lost packets are tolerated up to 10s.
+	// a larger gap is handled as a discontinuity
+	pcrMaxGap = 10 * SystemClockFrequency
+)
+
+var (
+	ErrClockNotEnoughPCR = errors.New("at least 2 PCRs are required")
+	ErrClockNoWallClock  = errors.New("no TDT/TOT has been observed")
+)
+
+// ClockMap maps the wall clock of TDT/TOT, the 27MHz PCR timeline and 90kHz PTS to packet indexes.
+// PCR wrap-around and discontinuities are folded into a continuous clock.
+type ClockMap struct {
+	PCRPID PID
+	// time zone of TDT/TOT. JST for ARIBSIOptions
+	SIOptions SIOptions
+
+	samples []clockSample
+	refs    []clockRef
+	mutex   *sync.Mutex
+}
+
+type clockSample struct {
+	packetIndex int
+	// PCR value in 27MHz
+	raw int64
+	// continuous clock in 27MHz
+	clock int64
+}
+
+type clockRef struct {
+	packetIndex int
+	wallClock   time.Time
+}
+
+func NewClockMap(pcrPID PID, opts SIOptions) ClockMap {
+	cm := ClockMap{PCRPID: pcrPID, SIOptions: opts}
+	cm.mutex = &sync.Mutex{}
+	return cm
+}
+
+// NewClockMap builds a ClockMap from every packet of the stream.
+func (m *MPEG2TS) NewClockMap(pcrPID PID, opts SIOptions) ClockMap {
+	cm := NewClockMap(pcrPID, opts)
+	for _, p := range m.PacketList.All() {
+		cm.EnqueueTSPacket(p)
+	}
+	return cm
+}
+
+// EnqueueTSPacket observes PCR on PCRPID and TDT/TOT. Packets must be enqueued in order of Index.
+func (cm *ClockMap) EnqueueTSPacket(p Packet) {
+	if p.PID == cm.PCRPID && p.HasAdaptationField() && p.AdaptationField.PCRFlag {
+		pcr := p.AdaptationField.ProgramClockReference
+		cm.addPCR(p.Index, int64(pcr.Base*300+uint64(pcr.Extension)), p.AdaptationField.DiscontinuityIndicator)
+	}
+	if p.PID == PID_TDT_TOT && p.PayloadUnitStartIndicator {
+		payload, err := p.GetPayload()
+		if err != nil {
+			return
+		}
+		tdt, err := parseTDT(payload, true, cm.SIOptions)
+		if err != nil {
+			return
+		}
+		cm.mutex.Lock()
+		cm.refs = append(cm.refs, clockRef{packetIndex: p.Index, wallClock: tdt.Timestamp})
+		cm.mutex.Unlock()
+	}
+}
+
+func (cm *ClockMap) addPCR(packetIndex int, raw int64, discontinuity bool) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	n := len(cm.samples)
+	if n == 0 {
+		cm.samples = append(cm.samples, clockSample{packetIndex, raw, raw})
+		return
+	}
+	last := cm.samples[n-1]
+	delta := raw - last.raw
+	if delta < -pcrWrap/2 {
+		delta += pcrWrap
+	}
+	if discontinuity || delta < 0 || delta > pcrMaxGap {
+		// estimate the elapsed time from the packet distance
+		delta = 0
+		if n >= 2 {
+			prev := cm.samples[n-2]
+			if last.packetIndex > prev.packetIndex {
+				delta = (last.clock - prev.clock) * int64(packetIndex-last.packetIndex) / int64(last.packetIndex-prev.packetIndex)
+			}
+		}
+	}
+	cm.samples = append(cm.samples, clockSample{packetIndex, raw, last.clock + delta})
+}
+
+// clockAt returns the continuous clock at the packet index.
+func (cm *ClockMap) clockAt(packetIndex int) (int64, error) {
+	if len(cm.samples) < 2 {
+		return 0, ErrClockNotEnoughPCR
+	}
+	i := sort.Search(len(cm.samples), func(i int) bool { return cm.samples[i].packetIndex >= packetIndex })
+	if i < len(cm.samples) && cm.samples[i].packetIndex == packetIndex {
+		return cm.samples[i].clock, nil
+	}
+	if i == 0 {
+		i = 1
+	} else if i == len(cm.samples) {
+		i = len(cm.samples) - 1
+	}
+	s0, s1 := cm.samples[i-1], cm.samples[i]
+	if s1.packetIndex == s0.packetIndex {
+		return s0.clock, nil
+	}
+	return s0.clock + (s1.clock-s0.clock)*int64(packetIndex-s0.packetIndex)/int64(s1.packetIndex-s0.packetIndex), nil
+}
+
+// packetIndexAt returns the packet index at the continuous clock.
+func (cm *ClockMap) packetIndexAt(clock int64) (int, error) {
+	if len(cm.samples) < 2 {
+		return 0, ErrClockNotEnoughPCR
+	}
+	i := sort.Search(len(cm.samples), func(i int) bool { return cm.samples[i].clock >= clock })
+	if i < len(cm.samples) && cm.samples[i].clock == clock {
+		return cm.samples[i].packetIndex, nil
+	}
+	if i == 0 {
+		i = 1
+	} else if i == len(cm.samples) {
+		i = len(cm.samples) - 1
+	}
+	s0, s1 := cm.samples[i-1], cm.samples[i]
+	if s1.clock == s0.clock {
+		return s0.packetIndex, nil
+	}
+	index := s0.packetIndex + int((clock-s0.clock)*int64(s1.packetIndex-s0.packetIndex)/(s1.clock-s0.clock))
+	if index < 0 {
+		index = 0
+	}
+	return index, nil
+}
+
+// wallClockToClock uses the last TDT/TOT which is not later than t.
+func (cm *ClockMap) wallClockToClock(t time.Time) (int64, error) {
+	if len(cm.refs) == 0 {
+		return 0, ErrClockNoWallClock
+	}
+	ref := cm.refs[0]
+	for _, r := range cm.refs {
+		if r.wallClock.After(t) {
+			break
+		}
+		ref = r
+	}
+	refClock, err := cm.clockAt(ref.packetIndex)
+	if err != nil {
+		return 0, err
+	}
+	return refClock + durationToClock(t.Sub(ref.wallClock)), nil
+}
+
+// clockToWallClock uses the last TDT/TOT which is not later than clock.
+func (cm *ClockMap) clockToWallClock(clock int64) (time.Time, error) {
+	if len(cm.refs) == 0 {
+		return time.Time{}, ErrClockNoWallClock
+	}
+	var ref clockRef
+	var refClock int64
+	for i, r := range cm.refs {
+		c, err := cm.clockAt(r.packetIndex)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if i > 0 && c > clock {
+			break
+		}
+		ref, refClock = r, c
+	}
+	return ref.wallClock.Add(clockToDuration(clock - refClock)), nil
+}
+
+// PacketIndexAt returns the index of the packet which is transmitted at the wall clock time.
+func (cm *ClockMap) PacketIndexAt(t time.Time) (int, error) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+	clock, err := cm.wallClockToClock(t)
+	if err != nil {
+		return 0, err
+	}
+	return cm.packetIndexAt(clock)
+}
+
+// WallClockAt returns the wall clock time when the packet is transmitted.
+func (cm *ClockMap) WallClockAt(packetIndex int) (time.Time, error) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+	clock, err := cm.clockAt(packetIndex)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return cm.clockToWallClock(clock)
+}
+
+// PCRAt returns the 27MHz PCR value (not continuous) at the wall clock time.
+func (cm *ClockMap) PCRAt(t time.Time) (uint64, error) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+	clock, err := cm.wallClockToClock(t)
+	if err != nil {
+		return 0, err
+	}
+	s := cm.nearestSampleByClock(clock)
+	raw := (s.raw + clock - s.clock) % pcrWrap
+	if raw < 0 {
+		raw += pcrWrap
+	}
+	return uint64(raw), nil
+}
+
+// ClockAt returns the continuous 27MHz clock when the packet is transmitted, interpolated
+// between the PCRs around it.
+func (cm *ClockMap) ClockAt(packetIndex int) (int64, error) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+	return cm.clockAt(packetIndex)
+}
+
+// PCRAtPacket returns the 27MHz PCR value (not continuous) when the packet is transmitted,
+// interpolated between the PCRs around it.
+func (cm *ClockMap) PCRAtPacket(packetIndex int) (uint64, error) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+	clock, err := cm.clockAt(packetIndex)
+	if err != nil {
+		return 0, err
+	}
+	s := cm.nearestSampleByClock(clock)
+	raw := (s.raw + clock - s.clock) % pcrWrap
+	if raw < 0 {
+		raw += pcrWrap
+	}
+	return uint64(raw), nil
+}
+
+// PTSAt returns the 90kHz PTS value at the wall clock time.
+func (cm *ClockMap) PTSAt(t time.Time) (uint64, error) {
+	pcr, err := cm.PCRAt(t)
+	if err != nil {
+		return 0, err
+	}
+	return pcr / 300, nil
+}
+
+// WallClockOfPTS returns the wall clock time of the 90kHz PTS value carried in the packet.
+// The PTS is resolved against the PCR near the packet, because the same PTS value
+// appears again after a discontinuity.
+func (cm *ClockMap) WallClockOfPTS(pts uint64, packetIndex int) (time.Time, error) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+	if len(cm.samples) < 2 {
+		return time.Time{}, ErrClockNotEnoughPCR
+	}
+	// the last PCR which is not later than the packet
+	i := sort.Search(len(cm.samples), func(i int) bool { return cm.samples[i].packetIndex > packetIndex })
+	if i > 0 {
+		i--
+	}
+	s := cm.samples[i]
+	diff := (int64(pts%ptsWrap) - s.raw/300) % ptsWrap
+	if diff > ptsWrap/2 {
+		diff -= ptsWrap
+	} else if diff < -ptsWrap/2 {
+		diff += ptsWrap
+	}
+	return cm.clockToWallClock(s.clock - s.raw%300 + diff*300)
+}
+
+func (cm *ClockMap) nearestSampleByClock(clock int64) clockSample {
+	i := sort.Search(len(cm.samples), func(i int) bool { return cm.samples[i].clock >= clock })
+	if i == len(cm.samples) {
+		return cm.samples[i-1]
+	}
+	if i > 0 && clock-cm.samples[i-1].clock < cm.samples[i].clock-clock {
+		return cm.samples[i-1]
+	}
+	return cm.samples[i]
+}
+
+func durationToClock(d time.Duration) int64 {
+	return int64(d/time.Microsecond) * (SystemClockFrequency / 1000000)
+}
+
+func clockToDuration(clock int64) time.Duration {
+	return time.Duration(clock/(SystemClockFrequency/1000000)) * time.Microsecond
+}
@@ -0,0 +1,118 @@
+package mpeg2ts
+
+import (
+	"bytes"
+	"testing"
+)
+
+// corrupt returns a copy of b with f applied.
+func corrupt(b []byte, f func(b []byte)) []byte {
+	c := copyBytes(b)
+	f(c)
+	return c
+}
+
+func TestCorruptedInputs(t *testing.T) {
+	parsePAT := func(p Packet) error {
+		_, err := p.ParsePAT()
+		return err
+	}
+	parsePMT := func(p Packet) error {
+		_, err := p.ParsePMT(false)
+		return err
+	}
+	parseTOT := func(p Packet) error {
+		_, err := p.ParseTOT()
+		return err
+	}
+	assembleSection := func(p Packet) error {
+		sa := NewSectionAssembler()
+		_, err := sa.EnqueueTSPacket(p)
+		return err
+	}
+
+	pat := fixtureSectionPackets(PID_PAT, 0, fixturePAT(0, 0, 0, 1, uint16(fixturePMTPID)))
+	pmt := func(body []byte) []byte {
+		return fixtureSectionPackets(fixturePMTPID, 0, fixtureLongSection(TableID_ProgramMapSection, 1, 0, 0, 0, body))
+	}
+	tot := fixtureSectionPackets(PID_TDT_TOT, 0, fixtureTOT())
+	af := fixtureAdaptationFields()
+	afPacket := func(i int) []byte {
+		return af[i*PacketSizeDefault : (i+1)*PacketSizeDefault]
+	}
+
+	tests := []struct {
+		name  string
+		data  []byte
+		parse func(p Packet) error
+	}{
+		{"sync_byte", corrupt(pat, func(b []byte) { b[0] = 0x48 }), nil},
+		{"adaptation_field_only_length", corrupt(afPacket(0), func(b []byte) { b[4] = 100 }), nil},
+		{"adaptation_field_length", corrupt(afPacket(2), func(b []byte) { b[4] = 184 }), nil},
+		{"transport_private_data_length", corrupt(afPacket(5), func(b []byte) { b[6] = 0xf0 }), nil},
+		{"adaptation_field_extension_length", corrupt(afPacket(6), func(b []byte) { b[6] = 0xf0 }), nil},
+		{"adaptation_field_fields", corrupt(afPacket(2), func(b []byte) { b[4] = 3 }), nil},
+		{"stuffing_byte", corrupt(afPacket(0), func(b []byte) { b[100] = 0x00 }), nil},
+		{"pat_crc", corrupt(pat, func(b []byte) { b[14] ^= 0x01 }), parsePAT},
+		{"pat_section_length", corrupt(pat, func(b []byte) { b[6], b[7] = 0xbf, 0xff }), parsePAT},
+		{"pat_short_section_length", corrupt(pat, func(b []byte) { b[7] = 3 }), parsePAT},
+		{"pat_pointer_field", corrupt(pat, func(b []byte) { b[4] = 200 }), assembleSection},
+		{"pmt_es_info_length", pmt(append(append(fixturePID(fixtureVideoPID), fixtureLoop()...), byte(StreamTypeAVC), 0xe1, 0x01, 0xf0, 0x40, 0x28, 0x02, 0x00, 0x00)), parsePMT},
+		{"pmt_descriptor_length", pmt(append(append(fixturePID(fixtureVideoPID), 0xf0, 0x06, 0x05, 0xc8, 'H', 'D', 'M', 'V'), fixturePMTStream(StreamTypeAVC, fixtureVideoPID)...)), parsePMT},
+		{"pmt_section_length", corrupt(pmt(fixturePID(fixtureVideoPID)), func(b []byte) { b[6], b[7] = 0xbf, 0xff }), parsePMT},
+		{"tot_section_length", corrupt(tot, func(b []byte) { b[6], b[7] = 0x7f, 0xff }), parseTOT},
+		{"tot_crc", corrupt(tot, func(b []byte) { b[10] ^= 0x01 }), parseTOT},
+	}
+
+	type result struct {
+		Name  string
+		Error string
+	}
+	results := []result{}
+	for _, tt := range tests {
+		pl, _ := NewPacketList(PacketSizeDefault)
+		err := pl.AddBytes(tt.data[:PacketSizeDefault], PacketSizeDefault)
+		if err == nil && tt.parse != nil {
+			err = tt.parse(pl.All()[0])
+		}
+		if err == nil {
+			t.Errorf("%s: no error", tt.name)
+			continue
+		}
+		results = append(results, result{tt.name, err.Error()})
+	}
+	checkGolden(t, "corrupt", results)
+}
+
+func TestCorruptedPES(t *testing.T) {
+	m := loadFixture(t, "pes.ts", fixturePESStream)
+	packets := []Packet{}
+	for _, p := range m.FilterByPIDs(fixtureVideoPID).PacketList.All() {
+		p = p.DeepCopy()
+		payload, _ := p.GetPayload()
+		if p.PayloadUnitStartIndicator && payload[8] > 20 {
+			// invalid marker bits of the PES packet with every optional field
+			payload[6] = 0x00
+		}
+		packets = append(packets, p)
+	}
+	pes := parsePES(t, packets)
+	for _, p := range pes {
+		if p.ESCRFlag {
+			t.Errorf("broken PES header is parsed %+v", p)
+		}
+	}
+
+	// no start code prefix
+	garbage := []Packet{}
+	for i := 0; i < 3; i++ {
+		pl, _ := NewPacketList(PacketSizeDefault)
+		if err := pl.AddBytes(fixturePacket(fixtureVideoPID, true, byte(i), nil, bytes.Repeat([]byte{0x00}, 184)), PacketSizeDefault); err != nil {
+			t.Fatal(err)
+		}
+		garbage = append(garbage, pl.All()[0])
+	}
+	if pes := parsePES(t, garbage); len(pes) != 0 {
+		t.Errorf("%d PES packets from garbage", len(pes))
+	}
+}
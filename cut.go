@@ -0,0 +1,189 @@
+package mpeg2ts
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrCutStartNotFound = errors.New("no video PES at or after the start PTS")
+
+const (
+	cutStateSeeking = iota
+	cutStateCutting
+	cutStateDone
+)
+
+// Cutter extracts the packets of a program between two video PTS from a packet stream.
+// The output starts with PAT and PMT followed by the random access point before StartPTS,
+// and ends before the first video PES whose PTS is not earlier than EndPTS.
+// PAT is replaced with the one of only the program, and packets of the other programs and SI are dropped.
+type Cutter struct {
+	StartPTS uint64
+	EndPTS   uint64
+
+	tracker programTracker
+	// packets since the last random access point while seeking
+	buffer []Packet
+	state  int
+	output packetRewriter
+}
+
+// packetRewriter renumbers packets and continuity counters of an output stream.
+type packetRewriter struct {
+	index         int
+	cc            map[PID]byte
+	discontinuity map[PID]bool
+}
+
+func newPacketRewriter() packetRewriter {
+	return packetRewriter{cc: map[PID]byte{}, discontinuity: map[PID]bool{}}
+}
+
+// rewrite returns a copy of p with a continuous continuity_counter. The first adaptation field
+// of each PID gets discontinuity_indicator, because the timebase starts over in the output.
+func (pr *packetRewriter) rewrite(p Packet) Packet {
+	p = p.DeepCopy()
+	p.Index = pr.index
+	pr.index++
+
+	hasPayload := p.AdaptationFieldControl == AdaptationField_PayloadOnly || p.AdaptationFieldControl == AdaptationField_AdaptationFieldFollowed
+	cc, ok := pr.cc[p.PID]
+	if !ok {
+		cc = p.ContinuityCheckIndex
+	} else if hasPayload {
+		cc = (cc + 1) & 0x0f
+	}
+	pr.cc[p.PID] = cc
+	p.ContinuityCheckIndex = cc
+	p.Data[3] = p.Data[3]&0xf0 | cc
+
+	if !pr.discontinuity[p.PID] && p.HasAdaptationField() && p.AdaptationField.Length > 0 {
+		pr.discontinuity[p.PID] = true
+		p.AdaptationField.DiscontinuityIndicator = true
+		p.Data[5] |= 0x80
+	}
+	return p
+}
+
+func NewCutter(startPTS, endPTS uint64) Cutter {
+	c := Cutter{StartPTS: startPTS, EndPTS: endPTS}
+	c.output = newPacketRewriter()
+	return c
+}
+
+// SetProgramNumber selects the program to cut. The first program in PAT is used by default.
+func (c *Cutter) SetProgramNumber(programNumber uint16) {
+	c.tracker.programNumber = programNumber
+}
+
+// Done reports whether EndPTS has been reached.
+func (c *Cutter) Done() bool {
+	return c.state == cutStateDone
+}
+
+// EnqueueTSPacket feeds a packet and returns the packets to output.
+func (c *Cutter) EnqueueTSPacket(p Packet) []Packet {
+	if c.state == cutStateDone {
+		return nil
+	}
+	isPSI := c.tracker.observe(p)
+	if !c.tracker.isProgramPID(p.PID) {
+		return nil
+	}
+	if p.PID == PID_PAT {
+		if c.state != cutStateCutting || !c.tracker.hasPAT || !isCompleteSectionPacket(p) {
+			return nil
+		}
+		return []Packet{c.output.rewrite(c.tracker.pat)}
+	}
+
+	if !c.tracker.hasVideo() || p.PID != c.tracker.videoPID || !p.PayloadUnitStartIndicator {
+		if c.state == cutStateCutting {
+			return []Packet{c.output.rewrite(p)}
+		}
+		if len(c.buffer) > 0 && !isPSI {
+			c.buffer = append(c.buffer, p)
+		}
+		return nil
+	}
+
+	ts, err := p.ReadPESTimestamps()
+	if c.state == cutStateCutting {
+		if err == nil && ts.HasPTS && ptsDiff(ts.PTS, c.EndPTS) >= 0 {
+			c.state = cutStateDone
+			return nil
+		}
+		return []Packet{c.output.rewrite(p)}
+	}
+
+	if p.IsRandomAccessPoint(c.tracker.videoType) {
+		c.buffer = c.buffer[:0]
+		c.buffer = append(c.buffer, p)
+	} else if len(c.buffer) > 0 {
+		c.buffer = append(c.buffer, p)
+	}
+	if err != nil || !ts.HasPTS || ptsDiff(ts.PTS, c.StartPTS) < 0 || len(c.buffer) == 0 {
+		return nil
+	}
+
+	c.state = cutStateCutting
+	out := make([]Packet, 0, len(c.buffer)+2)
+	out = append(out, c.output.rewrite(c.tracker.pat), c.output.rewrite(c.tracker.pmt))
+	for _, bp := range c.buffer {
+		out = append(out, c.output.rewrite(bp))
+	}
+	c.buffer = nil
+	return out
+}
+
+// CutPTS returns the packets of the first program between startPTS and endPTS of the video stream.
+func (m *MPEG2TS) CutPTS(startPTS, endPTS uint64) (*MPEG2TS, error) {
+	mx := New(m.chunkSize)
+	c := NewCutter(startPTS, endPTS)
+	for _, p := range m.PacketList.All() {
+		for _, op := range c.EnqueueTSPacket(p) {
+			mx.AddPacket(op)
+		}
+		if c.Done() {
+			break
+		}
+	}
+	if c.state == cutStateSeeking {
+		if !c.tracker.hasVideo() {
+			return nil, ErrNoVideoStream
+		}
+		return nil, ErrCutStartNotFound
+	}
+	return mx, nil
+}
+
+// Cut returns the packets of the first program between start and end,
+// which are offsets from the first PTS of the video stream.
+func (m *MPEG2TS) Cut(start, end time.Duration) (*MPEG2TS, error) {
+	first, err := m.firstVideoPTS()
+	if err != nil {
+		return nil, err
+	}
+	startPTS := (first + uint64(durationToPTS(start))) & (ptsWrap - 1)
+	endPTS := (first + uint64(durationToPTS(end))) & (ptsWrap - 1)
+	return m.CutPTS(startPTS, endPTS)
+}
+
+func (m *MPEG2TS) firstVideoPTS() (uint64, error) {
+	pt := programTracker{}
+	for _, p := range m.PacketList.All() {
+		pt.observe(p)
+		if !pt.hasVideo() || p.PID != pt.videoPID || !p.PayloadUnitStartIndicator {
+			continue
+		}
+		ts, err := p.ReadPESTimestamps()
+		if err == nil && ts.HasPTS {
+			return ts.PTS, nil
+		}
+	}
+	return 0, ErrNoVideoStream
+}
+
+func durationToPTS(d time.Duration) int64 {
+	return int64(d / time.Microsecond * PTSClockFrequency / 1000000)
+}
@@ -0,0 +1,53 @@
+package mpeg2ts
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCutProgram(t *testing.T) {
+	m, _ := fixtureMux(t, 3*time.Second, 25, fixtureProgram(), MuxerProgram{
+		ProgramNumber: 2,
+		PMTPID:        0x0200,
+		Streams: []MuxerStream{
+			{PID: 0x0201, Type: StreamTypeAVC},
+			{PID: 0x0202, Type: StreamTypeISO13818_7_AudioWithADTS},
+		},
+	})
+
+	cut, err := m.CutPTS(fixtureStartPTS+PTSClockFrequency, fixtureStartPTS+2*PTSClockFrequency)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pids := map[PID]int{}
+	for _, p := range cut.PacketList.All() {
+		pids[p.PID]++
+	}
+	for _, pid := range []PID{PID_PAT, fixturePMTPID, fixtureVideoPID, fixtureAudioPID} {
+		if pids[pid] == 0 {
+			t.Errorf("no packet of PID 0x%04x", pid)
+		}
+		delete(pids, pid)
+	}
+	if len(pids) != 0 {
+		t.Errorf("packets of the other PIDs %v", pids)
+	}
+
+	// PAT lists only the program
+	pats := cut.FilterByPIDs(PID_PAT).PacketList.All()
+	for _, p := range pats {
+		pat, err := p.ParsePAT()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pat.Programs) != 1 || pat.Programs[0].ProgramNumber != 1 || pat.Programs[0].ProgramMapPID != fixturePMTPID {
+			t.Fatalf("packet %d: PAT of programs %+v", p.Index, pat.Programs)
+		}
+	}
+	if len(pats) < 2 {
+		t.Errorf("%d PAT packets", len(pats))
+	}
+	if n := continuityErrors(cut); n != 0 {
+		t.Errorf("%d continuity_counter errors", n)
+	}
+}
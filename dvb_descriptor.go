@@ -0,0 +1,401 @@
+package mpeg2ts
+
+import (
+	"time"
+)
+
+// ETSI EN 300 468 descriptors. ARIB STD-B10 uses the same syntax for these tags
+
+type NetworkNameDescriptor struct {
+	// ETSI EN 300 468 V1.17.1 p.79
+	NetworkNameChar []byte
+
+	NetworkName string
+}
+
+type ServiceListDescriptor struct {
+	// ETSI EN 300 468 V1.17.1 p.87
+	Services []ServiceListEntry
+}
+
+type ServiceListEntry struct {
+	ServiceID   uint16 // 16
+	ServiceType uint8  // 8
+}
+
+type SatelliteDeliverySystemDescriptor struct {
+	// ETSI EN 300 468 V1.17.1 pp.47-49, ARIB STD-B10 Part 2 p.54
+	Frequency        uint32 // 32 BCD, 10kHz
+	OrbitalPosition  uint16 // 16 BCD, 0.1 degree
+	WestEastFlag     bool   // 1 (true: east)
+	Polarization     uint8  // 2
+	Modulation       uint8  // 5 (ARIB)
+	RollOff          uint8  // 2 (DVB)
+	ModulationSystem uint8  // 1 (DVB)
+	ModulationType   uint8  // 2 (DVB)
+	SymbolRate       uint32 // 28 BCD, 100symbol/s
+	FECInner         uint8  // 4
+
+	FrequencyHz            uint64
+	OrbitalPositionDegree  float64
+	SymbolRateSymbolPerSec uint64
+}
+
+type CableDeliverySystemDescriptor struct {
+	// ETSI EN 300 468 V1.17.1 pp.41-42
+	Frequency  uint32 // 32 BCD, 100Hz
+	FECOuter   uint8  // 4
+	Modulation uint8  // 8
+	SymbolRate uint32 // 28 BCD, 100symbol/s
+	FECInner   uint8  // 4
+
+	FrequencyHz            uint64
+	SymbolRateSymbolPerSec uint64
+}
+
+type TerrestrialDeliverySystemDescriptor struct {
+	// ETSI EN 300 468 V1.17.1 pp.103-105
+	CentreFrequency      uint32 // 32, 10Hz
+	Bandwidth            uint8  // 3
+	Priority             bool   // 1
+	TimeSlicingIndicator bool   // 1
+	MPEFECIndicator      bool   // 1
+	Constellation        uint8  // 2
+	HierarchyInformation uint8  // 3
+	CodeRateHPStream     uint8  // 3
+	CodeRateLPStream     uint8  // 3
+	GuardInterval        uint8  // 2
+	TransmissionMode     uint8  // 2
+	OtherFrequencyFlag   bool   // 1
+
+	FrequencyHz uint64
+}
+
+type LinkageDescriptor struct {
+	// ETSI EN 300 468 V1.17.1 pp.63-64
+	TransportStreamID uint16 // 16
+	OriginalNetworkID uint16 // 16
+	ServiceID         uint16 // 16
+	LinkageType       uint8  // 8
+	PrivateDataByte   []byte
+}
+
+type LocalTimeOffsetDescriptor struct {
+	// ETSI EN 300 468 V1.17.1 pp.64-65
+	Offsets []LocalTimeOffset
+}
+
+type LocalTimeOffset struct {
+	CountryCode             int    // 24
+	CountryRegionID         uint8  // 6
+	LocalTimeOffsetPolarity bool   // 1 (true: negative)
+	RAWLocalTimeOffset      uint16 // 16 BCD hhmm
+	RAWTimeOfChange         uint64 // 40
+	RAWNextTimeOffset       uint16 // 16 BCD hhmm
+
+	// signed by local_time_offset_polarity
+	LocalTimeOffset time.Duration
+	TimeOfChange    time.Time
+	NextTimeOffset  time.Duration
+}
+
+type ServiceDescriptor struct {
+	// ETSI EN 300 468 V1.17.1 p.86
+	ServiceType               uint8 // 8
+	ServiceProviderNameLength uint8 // 8
+	ServiceProviderNameChar   []byte
+	ServiceNameLength         uint8 // 8
+	ServiceNameChar           []byte
+
+	ServiceProviderName string
+	ServiceName         string
+}
+
+type ShortEventDescriptor struct {
+	// ETSI EN 300 468 V1.17.1 p.90
+	ISO639LanguageCode int   // 24
+	EventNameLength    uint8 // 8
+	EventNameChar      []byte
+	TextLength         uint8 // 8
+	TextChar           []byte
+
+	EventName string
+	Text      string
+}
+
+type ExtendedEventDescriptor struct {
+	// ETSI EN 300 468 V1.17.1 pp.61-62
+	DescriptorNumber     uint8 // 4
+	LastDescriptorNumber uint8 // 4
+	ISO639LanguageCode   int   // 24
+	LengthOfItems        uint8 // 8
+	Items                []ExtendedEventItem
+	TextLength           uint8 // 8
+	TextChar             []byte
+
+	Text string
+}
+
+type ExtendedEventItem struct {
+	ItemDescriptionLength uint8 // 8
+	ItemDescriptionChar   []byte
+	ItemLength            uint8 // 8
+	ItemChar              []byte
+
+	ItemDescription string
+	Item            string
+}
+
+type ComponentDescriptor struct {
+	// ETSI EN 300 468 V1.17.1 pp.51-52
+	StreamContentExt   uint8 // 4
+	StreamContent      uint8 // 4
+	ComponentType      uint8 // 8
+	ComponentTag       uint8 // 8
+	ISO639LanguageCode int   // 24
+	TextChar           []byte
+
+	Text string
+}
+
+type ContentDescriptor struct {
+	// ETSI EN 300 468 V1.17.1 pp.54-58
+	Contents []ContentNibble
+}
+
+type ContentNibble struct {
+	ContentNibbleLevel1 uint8 // 4
+	ContentNibbleLevel2 uint8 // 4
+	UserByte            uint8 // 8
+}
+
+// readDVBDescriptor decodes the descriptor body d. It returns false when the tag is not supported.
+func readDVBDescriptor(ped *ProgramElementDescriptor, d []byte, opts SIOptions) (bool, error) {
+	switch ped.Tag {
+	case 0x40: // network_name_descriptor
+		ped.NetworkNameDescriptor.NetworkNameChar = copyBytes(d)
+		ped.NetworkNameDescriptor.NetworkName = opts.decodeText(d)
+
+	case 0x41: // service_list_descriptor
+		sl := &ped.ServiceListDescriptor
+		for i := 0; i+3 <= len(d); i += 3 {
+			sl.Services = append(sl.Services, ServiceListEntry{
+				ServiceID:   uint16(d[i])<<8 | uint16(d[i+1]),
+				ServiceType: d[i+2],
+			})
+		}
+
+	case 0x43: // satellite_delivery_system_descriptor
+		if len(d) < 11 {
+			return false, errDescriptorTooShort(ped.Tag)
+		}
+		sd := &ped.SatelliteDeliverySystemDescriptor
+		sd.Frequency = uint32(d[0])<<24 | uint32(d[1])<<16 | uint32(d[2])<<8 | uint32(d[3]) // 32
+		sd.OrbitalPosition = uint16(d[4])<<8 | uint16(d[5])                                 // 16
+		sd.WestEastFlag = ((d[6] >> 7) & 0x01) == 1                                         // 1
+		sd.Polarization = (d[6] >> 5) & 0x03                                                // 2
+		sd.Modulation = d[6] & 0x1f                                                         // 5
+		sd.RollOff = (d[6] >> 3) & 0x03
+		sd.ModulationSystem = (d[6] >> 2) & 0x01
+		sd.ModulationType = d[6] & 0x03
+		sd.SymbolRate = uint32(d[7])<<20 | uint32(d[8])<<12 | uint32(d[9])<<4 | uint32(d[10]>>4) // 28
+		sd.FECInner = d[10] & 0x0f                                                               // 4
+		sd.FrequencyHz = bcdToUint64(uint64(sd.Frequency), 8) * 10000
+		sd.OrbitalPositionDegree = float64(bcdToUint64(uint64(sd.OrbitalPosition), 4)) / 10
+		sd.SymbolRateSymbolPerSec = bcdToUint64(uint64(sd.SymbolRate), 7) * 100
+
+	case 0x44: // cable_delivery_system_descriptor
+		if len(d) < 11 {
+			return false, errDescriptorTooShort(ped.Tag)
+		}
+		cd := &ped.CableDeliverySystemDescriptor
+		cd.Frequency = uint32(d[0])<<24 | uint32(d[1])<<16 | uint32(d[2])<<8 | uint32(d[3]) // 32
+		// reserved_future_use 12
+		cd.FECOuter = d[5] & 0x0f                                                                // 4
+		cd.Modulation = d[6]                                                                     // 8
+		cd.SymbolRate = uint32(d[7])<<20 | uint32(d[8])<<12 | uint32(d[9])<<4 | uint32(d[10]>>4) // 28
+		cd.FECInner = d[10] & 0x0f                                                               // 4
+		cd.FrequencyHz = bcdToUint64(uint64(cd.Frequency), 8) * 100
+		cd.SymbolRateSymbolPerSec = bcdToUint64(uint64(cd.SymbolRate), 7) * 100
+
+	case 0x4A: // linkage_descriptor
+		if len(d) < 7 {
+			return false, errDescriptorTooShort(ped.Tag)
+		}
+		ld := &ped.LinkageDescriptor
+		ld.TransportStreamID = uint16(d[0])<<8 | uint16(d[1])
+		ld.OriginalNetworkID = uint16(d[2])<<8 | uint16(d[3])
+		ld.ServiceID = uint16(d[4])<<8 | uint16(d[5])
+		ld.LinkageType = d[6]
+		ld.PrivateDataByte = copyBytes(d[7:])
+
+	case 0x5A: // terrestrial_delivery_system_descriptor
+		if len(d) < 7 {
+			return false, errDescriptorTooShort(ped.Tag)
+		}
+		td := &ped.TerrestrialDeliverySystemDescriptor
+		td.CentreFrequency = uint32(d[0])<<24 | uint32(d[1])<<16 | uint32(d[2])<<8 | uint32(d[3]) // 32
+		td.Bandwidth = (d[4] >> 5) & 0x07                                                         // 3
+		td.Priority = ((d[4] >> 4) & 0x01) == 1                                                   // 1
+		td.TimeSlicingIndicator = ((d[4] >> 3) & 0x01) == 1                                       // 1
+		td.MPEFECIndicator = ((d[4] >> 2) & 0x01) == 1                                            // 1
+		td.Constellation = (d[5] >> 6) & 0x03                                                     // 2
+		td.HierarchyInformation = (d[5] >> 3) & 0x07                                              // 3
+		td.CodeRateHPStream = d[5] & 0x07                                                         // 3
+		td.CodeRateLPStream = (d[6] >> 5) & 0x07                                                  // 3
+		td.GuardInterval = (d[6] >> 3) & 0x03                                                     // 2
+		td.TransmissionMode = (d[6] >> 1) & 0x03                                                  // 2
+		td.OtherFrequencyFlag = (d[6] & 0x01) == 1                                                // 1
+		td.FrequencyHz = uint64(td.CentreFrequency) * 10
+
+	case 0x48: // service_descriptor
+		if len(d) < 2 {
+			return false, errDescriptorTooShort(ped.Tag)
+		}
+		sd := &ped.ServiceDescriptor
+		sd.ServiceType = d[0]
+		sd.ServiceProviderNameLength = d[1]
+		i := 2
+		if len(d) < i+int(sd.ServiceProviderNameLength)+1 {
+			return false, errDescriptorTooShort(ped.Tag)
+		}
+		sd.ServiceProviderNameChar = copyBytes(d[i : i+int(sd.ServiceProviderNameLength)])
+		i += int(sd.ServiceProviderNameLength)
+		sd.ServiceNameLength = d[i]
+		i++
+		if len(d) < i+int(sd.ServiceNameLength) {
+			return false, errDescriptorTooShort(ped.Tag)
+		}
+		sd.ServiceNameChar = copyBytes(d[i : i+int(sd.ServiceNameLength)])
+		sd.ServiceProviderName = opts.decodeText(sd.ServiceProviderNameChar)
+		sd.ServiceName = opts.decodeText(sd.ServiceNameChar)
+
+	case 0x58: // local_time_offset_descriptor
+		lt := &ped.LocalTimeOffsetDescriptor
+		for i := 0; i+13 <= len(d); i += 13 {
+			o := LocalTimeOffset{}
+			o.CountryCode = int(d[i])<<16 | int(d[i+1])<<8 | int(d[i+2]) // 24
+			o.CountryRegionID = (d[i+3] >> 2) & 0x3f                     // 6
+			o.LocalTimeOffsetPolarity = (d[i+3] & 0x01) == 1             // 1
+			o.RAWLocalTimeOffset = uint16(d[i+4])<<8 | uint16(d[i+5])    // 16
+			o.RAWTimeOfChange = uint64(d[i+6])<<32 | uint64(d[i+7])<<24 | uint64(d[i+8])<<16 | uint64(d[i+9])<<8 | uint64(d[i+10])
+			o.RAWNextTimeOffset = uint16(d[i+11])<<8 | uint16(d[i+12]) // 16
+			o.LocalTimeOffset = getOffsetByBCD(o.RAWLocalTimeOffset, o.LocalTimeOffsetPolarity)
+			o.NextTimeOffset = getOffsetByBCD(o.RAWNextTimeOffset, o.LocalTimeOffsetPolarity)
+			// ARIB sets all bits when time_of_change is not used
+			if o.RAWTimeOfChange != 0xFFFFFFFFFF {
+				o.TimeOfChange = getTimestampByMJD(o.RAWTimeOfChange, opts.location())
+			}
+			lt.Offsets = append(lt.Offsets, o)
+		}
+
+	case 0x4D: // short_event_descriptor
+		if len(d) < 4 {
+			return false, errDescriptorTooShort(ped.Tag)
+		}
+		se := &ped.ShortEventDescriptor
+		se.ISO639LanguageCode = int(d[0])<<16 | int(d[1])<<8 | int(d[2])
+		se.EventNameLength = d[3]
+		i := 4
+		if len(d) < i+int(se.EventNameLength)+1 {
+			return false, errDescriptorTooShort(ped.Tag)
+		}
+		se.EventNameChar = copyBytes(d[i : i+int(se.EventNameLength)])
+		i += int(se.EventNameLength)
+		se.TextLength = d[i]
+		i++
+		if len(d) < i+int(se.TextLength) {
+			return false, errDescriptorTooShort(ped.Tag)
+		}
+		se.TextChar = copyBytes(d[i : i+int(se.TextLength)])
+		se.EventName = opts.decodeText(se.EventNameChar)
+		se.Text = opts.decodeText(se.TextChar)
+
+	case 0x4E: // extended_event_descriptor
+		if len(d) < 5 {
+			return false, errDescriptorTooShort(ped.Tag)
+		}
+		ee := &ped.ExtendedEventDescriptor
+		ee.DescriptorNumber = (d[0] >> 4) & 0x0f // 4
+		ee.LastDescriptorNumber = d[0] & 0x0f    // 4
+		ee.ISO639LanguageCode = int(d[1])<<16 | int(d[2])<<8 | int(d[3])
+		ee.LengthOfItems = d[4]
+		i := 5
+		end := i + int(ee.LengthOfItems)
+		if len(d) < end+1 {
+			return false, errDescriptorTooShort(ped.Tag)
+		}
+		for i < end {
+			item := ExtendedEventItem{}
+			item.ItemDescriptionLength = d[i]
+			i++
+			if end < i+int(item.ItemDescriptionLength)+1 {
+				return false, errDescriptorTooShort(ped.Tag)
+			}
+			item.ItemDescriptionChar = copyBytes(d[i : i+int(item.ItemDescriptionLength)])
+			i += int(item.ItemDescriptionLength)
+			item.ItemLength = d[i]
+			i++
+			if end < i+int(item.ItemLength) {
+				return false, errDescriptorTooShort(ped.Tag)
+			}
+			item.ItemChar = copyBytes(d[i : i+int(item.ItemLength)])
+			i += int(item.ItemLength)
+			item.ItemDescription = opts.decodeText(item.ItemDescriptionChar)
+			item.Item = opts.decodeText(item.ItemChar)
+			ee.Items = append(ee.Items, item)
+		}
+		ee.TextLength = d[i]
+		i++
+		if len(d) < i+int(ee.TextLength) {
+			return false, errDescriptorTooShort(ped.Tag)
+		}
+		ee.TextChar = copyBytes(d[i : i+int(ee.TextLength)])
+		ee.Text = opts.decodeText(ee.TextChar)
+
+	case 0x50: // component_descriptor
+		if len(d) < 6 {
+			return false, errDescriptorTooShort(ped.Tag)
+		}
+		c := &ped.ComponentDescriptor
+		c.StreamContentExt = (d[0] >> 4) & 0x0f // 4
+		c.StreamContent = d[0] & 0x0f           // 4
+		c.ComponentType = d[1]                  // 8
+		c.ComponentTag = d[2]                   // 8
+		c.ISO639LanguageCode = int(d[3])<<16 | int(d[4])<<8 | int(d[5])
+		c.TextChar = copyBytes(d[6:])
+		c.Text = opts.decodeText(c.TextChar)
+
+	case 0x54: // content_descriptor
+		c := &ped.ContentDescriptor
+		for i := 0; i+2 <= len(d); i += 2 {
+			c.Contents = append(c.Contents, ContentNibble{
+				ContentNibbleLevel1: (d[i] >> 4) & 0x0f,
+				ContentNibbleLevel2: d[i] & 0x0f,
+				UserByte:            d[i+1],
+			})
+		}
+
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// bcdToUint64 converts the lowest digits of 4-bit BCD v.
+func bcdToUint64(v uint64, digits int) uint64 {
+	r := uint64(0)
+	for i := digits - 1; i >= 0; i-- {
+		r = r*10 + (v>>(uint(i)*4))&0x0f
+	}
+	return r
+}
+
+// 16bit BCD coded hhmm
+func getOffsetByBCD(bcd uint16, negative bool) time.Duration {
+	d := time.Duration(bcdToDec(byte(bcd>>8)))*time.Hour + time.Duration(bcdToDec(byte(bcd)))*time.Minute
+	if negative {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,205 @@
+package mpeg2ts
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ETSI EN 300 468 V1.17.1 Annex A: Coding of text characters
+
+var ErrDVBStringTruncated = errors.New("DVB string is truncated")
+
+// ISO/IEC 6937 upper half (0xA0-0xFF) without non-spacing diacritical marks (0xC1-0xCF)
+// ETSI EN 300 468 V1.17.1 p.130 Figure A.1
+const iso6937UpperHalf = "\u00a0¡¢£$¥#§¤‘“«←↑→↓°±²³×µ¶·÷’”»¼½¾¿" +
+	"����������������" +
+	"―¹®©™♪¬¦����⅛⅜⅝⅞" +
+	"ΩÆĐªĦ�ĲĿŁØŒºÞŦŊŉ" +
+	"ĸæđðħıĳŀłøœßþŧŋ\u00ad"
+
+var iso6937Runes = []rune(iso6937UpperHalf)
+
+var iso8859Runes = map[int][]rune{}
+
+var gb2312Runes [87][]rune
+
+func init() {
+	for part, s := range iso8859UpperHalf {
+		iso8859Runes[part] = []rune(s)
+	}
+	for i, s := range gb2312Rows {
+		gb2312Runes[i] = []rune(s)
+	}
+}
+
+// DecodeDVBString converts a DVB SI text field to UTF-8.
+// The leading character table selector is interpreted as defined in ETSI EN 300 468 Annex A.
+// Emphasis control codes (0x86, 0x87) are removed and 0x8A is converted to a line feed.
+func DecodeDVBString(b []byte) (string, error) {
+	if len(b) == 0 {
+		return "", nil
+	}
+	sel := b[0]
+	switch {
+	case sel >= 0x20:
+		return decodeISO6937(b), nil
+	case sel >= 0x01 && sel <= 0x0B:
+		// ISO/IEC 8859-5 to 8859-15
+		return decodeISO8859(b[1:], int(sel)+4)
+	case sel == 0x10:
+		if len(b) < 3 {
+			return "", ErrDVBStringTruncated
+		}
+		part := int(b[1])<<8 | int(b[2])
+		return decodeISO8859(b[3:], part)
+	case sel == 0x11:
+		// ISO/IEC 10646 BMP
+		return decodeUCS2(b[1:])
+	case sel == 0x13:
+		return decodeGB2312(b[1:])
+	case sel == 0x15:
+		return decodeDVBUTF8(b[1:])
+	case sel == 0x1F:
+		// encoding_type_id of ETSI TS 101 162 follows. the preprocessed text is not decoded
+		if len(b) < 2 {
+			return "", ErrDVBStringTruncated
+		}
+		return "", fmt.Errorf("unsupported encoding_type_id 0x%02X", b[1])
+	}
+	// 0x12 KS X 1001-2004 and 0x14 Big5 are not supported
+	return "", fmt.Errorf("unsupported character table 0x%02X", sel)
+}
+
+// isDVBCharacterTableSelector reports whether b is a character table selector of EN 300 468.
+// 0x1F is not, because it is followed by preprocessed text instead of characters.
+func isDVBCharacterTableSelector(b byte) bool {
+	return (b >= 0x01 && b <= 0x0B) || (b >= 0x10 && b <= 0x15)
+}
+
+// writeDVBControl handles the single byte control codes. ETSI EN 300 468 V1.17.1 p.129 Table A.1
+// It returns false when c is not a control code.
+func writeDVBControl(sb *strings.Builder, c rune) bool {
+	switch {
+	case c == 0x8A:
+		sb.WriteString("\n")
+	case c >= 0x80 && c <= 0x9F:
+		// 0x86, 0x87: character emphasis on/off. others are reserved or user defined
+	case c < 0x20 && c != 0x0A:
+		// C0 control codes are not used in SI text
+	default:
+		return false
+	}
+	return true
+}
+
+func decodeISO6937(b []byte) string {
+	sb := strings.Builder{}
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		if writeDVBControl(&sb, rune(c)) {
+			continue
+		}
+		switch {
+		case c < 0x80:
+			sb.WriteByte(c)
+		case c >= 0xC1 && c <= 0xCF:
+			// non-spacing diacritical mark precedes the base character
+			if i+1 >= len(b) {
+				break
+			}
+			if r, ok := iso6937Compositions[uint16(c)<<8|uint16(b[i+1])]; ok {
+				sb.WriteRune(r)
+			} else if b[i+1] < 0x80 {
+				sb.WriteByte(b[i+1])
+			}
+			i++
+		case c >= 0xA0:
+			if r := iso6937Runes[c-0xA0]; r != utf8.RuneError {
+				sb.WriteRune(r)
+			}
+		}
+	}
+	return sb.String()
+}
+
+func decodeISO8859(b []byte, part int) (string, error) {
+	table, ok := iso8859Runes[part]
+	if !ok {
+		return "", fmt.Errorf("unsupported ISO/IEC 8859 part %d", part)
+	}
+	sb := strings.Builder{}
+	for _, c := range b {
+		if writeDVBControl(&sb, rune(c)) {
+			continue
+		}
+		if c < 0x80 {
+			sb.WriteByte(c)
+		} else if c >= 0xA0 {
+			sb.WriteRune(table[c-0xA0])
+		}
+	}
+	return sb.String(), nil
+}
+
+func decodeUCS2(b []byte) (string, error) {
+	sb := strings.Builder{}
+	for i := 0; i+1 < len(b); i += 2 {
+		r := rune(b[i])<<8 | rune(b[i+1])
+		if r >= 0xE080 && r <= 0xE09F {
+			// control codes are mapped to the private use area
+			writeDVBControl(&sb, r-0xE000)
+			continue
+		}
+		if writeDVBControl(&sb, r) {
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	if len(b)%2 != 0 {
+		return sb.String(), ErrDVBStringTruncated
+	}
+	return sb.String(), nil
+}
+
+func decodeGB2312(b []byte) (string, error) {
+	sb := strings.Builder{}
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		if c < 0xA1 {
+			if !writeDVBControl(&sb, rune(c)) && c < 0x80 {
+				sb.WriteByte(c)
+			}
+			continue
+		}
+		if i+1 >= len(b) {
+			return sb.String(), ErrDVBStringTruncated
+		}
+		c2 := b[i+1]
+		i++
+		if c > 0xF7 || c2 < 0xA1 || c2 > 0xFE {
+			sb.WriteRune(utf8.RuneError)
+			continue
+		}
+		sb.WriteRune(gb2312Runes[c-0xA1][c2-0xA1])
+	}
+	return sb.String(), nil
+}
+
+func decodeDVBUTF8(b []byte) (string, error) {
+	sb := strings.Builder{}
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		b = b[size:]
+		if r >= 0xE080 && r <= 0xE09F {
+			writeDVBControl(&sb, r-0xE000)
+			continue
+		}
+		if r < 0x80 && writeDVBControl(&sb, r) {
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String(), nil
+}
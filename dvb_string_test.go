@@ -0,0 +1,26 @@
+package mpeg2ts
+
+import "testing"
+
+func TestDecodeDVBString(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+		err  bool
+	}{
+		{"ISO/IEC 6937", "Caf\xc2e", "Café", false},
+		{"ISO/IEC 8859-5", "\x01\xb0\xb1", "АБ", false},
+		{"ISO/IEC 10646", "\x11\x4e\x00\x00A", "一A", false},
+		{"UTF-8", "\x15Caf\xc3\xa9", "Café", false},
+		{"KS X 1001", "\x12\xb0\xa1", "", true},
+		{"Big5", "\x14\xa4\x40", "", true},
+		{"encoding_type_id", "\x1f\x01\x00", "", true},
+	}
+	for _, tt := range tests {
+		s, err := DecodeDVBString([]byte(tt.in))
+		if (err != nil) != tt.err || s != tt.want {
+			t.Errorf("%s: %q, %v, want %q", tt.name, s, err, tt.want)
+		}
+	}
+}
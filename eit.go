@@ -1,31 +1,128 @@
 package mpeg2ts
 
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// ETSI EN 300 468 V1.17.1 p.32 Table 6
+	RunningStatus_Undefined          = 0
+	RunningStatus_NotRunning         = 1
+	RunningStatus_StartsInAFewSecond = 2
+	RunningStatus_Pausing            = 3
+	RunningStatus_Running            = 4
+	RunningStatus_ServiceOffAir      = 5
+)
+
+// Event Information Table
+// ETSI EN 300 468 V1.17.1 pp.31-32
 type EITTable struct {
-	TableID                  byte
-	SectionSyntaxIndicator   bool
-	SectionLength            uint16
-	ServiceID                uint16
-	Version                  byte
-	CurrentNextIndicator     bool
-	SectionNumber            byte
-	LastSectionNumber        byte
-	SegmentLastSectionNumber byte
-	TransportStreamID        byte
-	OriginalNetworkID        byte
-	LastTableID              byte
-	CRC32                    uint
+	TableID                  byte   // 8
+	SectionSyntaxIndicator   bool   // 1
+	SectionLength            uint16 // 12
+	ServiceID                uint16 // 16
+	Version                  byte   // 5
+	CurrentNextIndicator     bool   // 1
+	SectionNumber            byte   // 8
+	LastSectionNumber        byte   // 8
+	TransportStreamID        uint16 // 16
+	OriginalNetworkID        uint16 // 16
+	SegmentLastSectionNumber byte   // 8
+	LastTableID              byte   // 8
+	CRC32                    uint   // 32
 	Events                   []EITEvent
+
+	// of the section, which the extended events are joined with
+	siOptions SIOptions
 }
 
 type EITEvent struct {
-	EventID           int
-	StartTime         int
-	Duration          int
-	RunningStatus     int
-	FreeCAMode        int
-	DescriptorsLength int
+	EventID           uint16 // 16
+	RAWStartTime      uint64 // 40
+	RAWDuration       uint32 // 24
+	RunningStatus     byte   // 3
+	FreeCAMode        bool   // 1
+	DescriptorsLength uint16 // 12
 	Descriptors       []EITDescriptor
+
+	// zero value when start_time is undefined (all bits set)
+	StartTime time.Time
+	// zero value when duration is undefined (all bits set)
+	Duration time.Duration
+}
+
+type EITDescriptor = ProgramElementDescriptor
+
+// IsEITTableID reports whether tableID is an EIT present/following or schedule table_id.
+func IsEITTableID(tableID byte) bool {
+	return tableID >= TableID_EventInformationSection_ActualDVBTransportStreamPresentFollowing &&
+		tableID <= TableID_EventInformationSection_OtherDVBTransportStreamScheduleMax
+}
+
+// IsPresentFollowing reports whether the table is EIT present/following.
+func (eit *EITTable) IsPresentFollowing() bool {
+	return eit.TableID == TableID_EventInformationSection_ActualDVBTransportStreamPresentFollowing ||
+		eit.TableID == TableID_EventInformationSection_OtherDVBTransportStreamPresentFollowing
 }
 
-type EITDescriptor struct {
+func (s *Section) ParseEIT() (EITTable, error) {
+	if !IsEITTableID(s.TableID) {
+		return EITTable{}, fmt.Errorf("invalid TableID. expected: 0x4e-0x6f, actual: 0x%02x", s.TableID)
+	}
+	if err := s.CheckCRC(); err != nil {
+		return EITTable{}, err
+	}
+	eit := EITTable{}
+	eit.TableID = s.TableID
+	eit.SectionSyntaxIndicator = s.SectionSyntaxIndicator
+	eit.SectionLength = s.SectionLength
+	eit.ServiceID = s.TableIDExtension
+	eit.Version = s.Version
+	eit.CurrentNextIndicator = s.CurrentNextIndicator
+	eit.SectionNumber = s.SectionNumber
+	eit.LastSectionNumber = s.LastSectionNumber
+	eit.CRC32 = s.CRC32
+	eit.siOptions = s.SIOptions
+
+	data := s.Data
+	end := len(data) - 4
+	if end < 14 {
+		return EITTable{}, ErrSectionTooShort
+	}
+	eit.TransportStreamID = uint16(data[8])<<8 | uint16(data[9])
+	eit.OriginalNetworkID = uint16(data[10])<<8 | uint16(data[11])
+	eit.SegmentLastSectionNumber = data[12]
+	eit.LastTableID = data[13]
+	index := 14
+
+	for index+12 <= end {
+		e := EITEvent{}
+		e.EventID = uint16(data[index])<<8 | uint16(data[index+1])                                                                                            // 16
+		e.RAWStartTime = uint64(data[index+2])<<32 | uint64(data[index+3])<<24 | uint64(data[index+4])<<16 | uint64(data[index+5])<<8 | uint64(data[index+6]) // 40
+		e.RAWDuration = uint32(data[index+7])<<16 | uint32(data[index+8])<<8 | uint32(data[index+9])                                                          // 24
+		e.RunningStatus = (data[index+10] >> 5) & 0x07                                                                                                        // 3
+		e.FreeCAMode = ((data[index+10] >> 4) & 0x01) == 1                                                                                                    // 1
+		e.DescriptorsLength = uint16(data[index+10]&0x0f)<<8 | uint16(data[index+11])                                                                         // 12
+		index += 12
+		if e.RAWStartTime != 0xFFFFFFFFFF {
+			e.StartTime = getTimestampByMJD(e.RAWStartTime, s.SIOptions.location())
+		}
+		if e.RAWDuration != 0xFFFFFF {
+			e.Duration = getDurationByBCD(e.RAWDuration)
+		}
+
+		if index+int(e.DescriptorsLength) > end {
+			return EITTable{}, ErrSectionTooShort
+		}
+		var err error
+		var diff int
+		e.Descriptors, diff, err = readDescriptor(data, index, int(e.DescriptorsLength), s.SIOptions)
+		if err != nil {
+			return EITTable{}, err
+		}
+		index += diff
+		eit.Events = append(eit.Events, e)
+	}
+	return eit, nil
 }
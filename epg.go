@@ -0,0 +1,540 @@
+package mpeg2ts
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// content_nibble_level_1 names used by WriteXMLTV
+// ARIB STD-B10 Part 2 Annex H
+var ARIBContentGenres = map[uint8]string{
+	0x0: "News/Report",
+	0x1: "Sports",
+	0x2: "Information/Tabloid show",
+	0x3: "Drama",
+	0x4: "Music",
+	0x5: "Variety",
+	0x6: "Movies",
+	0x7: "Animation/Special effects",
+	0x8: "Documentary/Culture",
+	0x9: "Theatre/Public performance",
+	0xA: "Hobby/Education",
+	0xB: "Welfare",
+	0xF: "Others",
+}
+
+// ETSI EN 300 468 V1.17.1 pp.55-57 Table 29
+var DVBContentGenres = map[uint8]string{
+	0x1: "Movie/Drama",
+	0x2: "News/Current affairs",
+	0x3: "Show/Game show",
+	0x4: "Sports",
+	0x5: "Children's/Youth programmes",
+	0x6: "Music/Ballet/Dance",
+	0x7: "Arts/Culture",
+	0x8: "Social/Political issues/Economics",
+	0x9: "Education/Science/Factual topics",
+	0xA: "Leisure hobbies",
+	0xB: "Special characteristics",
+}
+
+type EPGServiceKey struct {
+	OriginalNetworkID uint16
+	TransportStreamID uint16
+	ServiceID         uint16
+}
+
+// EPG collects EIT sections of every service and builds a program guide.
+type EPG struct {
+	// names of content_nibble_level_1 written to XMLTV category. nil for ContentGenres of SIOptions
+	GenreNames map[uint8]string
+	// decodes the sections fed by EnqueueTSPacket
+	SIOptions SIOptions
+
+	services        map[EPGServiceKey]*epgService
+	sectionAssembly SectionAssembler
+	mutex           *sync.Mutex
+}
+
+type epgService struct {
+	name   string
+	tables map[byte]*epgTable
+}
+
+type epgTable struct {
+	version                   byte
+	lastSectionNumber         byte
+	lastTableID               byte
+	segmentLastSectionNumbers map[byte]byte
+	sections                  map[byte]EITTable
+}
+
+type EPGEvent struct {
+	EPGServiceKey
+	EventID       uint16
+	StartTime     time.Time
+	Duration      time.Duration
+	RunningStatus byte
+	FreeCAMode    bool
+	Language      string
+	Title         string
+	Description   string
+	ExtendedItems []EPGExtendedItem
+	ExtendedText  string
+	Genres        []ContentNibble
+	Components    []ComponentDescriptor
+}
+
+type EPGExtendedItem struct {
+	Description string
+	Text        string
+}
+
+// EndTime returns StartTime + Duration.
+func (e *EPGEvent) EndTime() time.Time {
+	return e.StartTime.Add(e.Duration)
+}
+
+func NewEPG() EPG {
+	epg := EPG{}
+	epg.services = map[EPGServiceKey]*epgService{}
+	epg.sectionAssembly = NewSectionAssembler()
+	epg.mutex = &sync.Mutex{}
+	return epg
+}
+
+// EnqueueTSPacket feeds a TS packet carrying EIT. (PID_EIT, PID_EIT_Terrestrial*)
+func (epg *EPG) EnqueueTSPacket(p Packet) error {
+	epg.mutex.Lock()
+	epg.sectionAssembly.SIOptions = epg.SIOptions
+	sections, err := epg.sectionAssembly.EnqueueTSPacket(p)
+	epg.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	for _, s := range sections {
+		if !IsEITTableID(s.TableID) {
+			continue
+		}
+		if err := epg.AddSection(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddSection parses an EIT section and merges it into the guide.
+func (epg *EPG) AddSection(s Section) error {
+	eit, err := s.ParseEIT()
+	if err != nil {
+		return err
+	}
+	epg.AddEIT(eit)
+	return nil
+}
+
+// AddEIT merges a parsed EIT section into the guide.
+// A section with a new version number replaces every section of the same table.
+func (epg *EPG) AddEIT(eit EITTable) {
+	if !eit.CurrentNextIndicator {
+		return
+	}
+	epg.mutex.Lock()
+	defer epg.mutex.Unlock()
+
+	key := EPGServiceKey{eit.OriginalNetworkID, eit.TransportStreamID, eit.ServiceID}
+	svc, ok := epg.services[key]
+	if !ok {
+		svc = &epgService{tables: map[byte]*epgTable{}}
+		epg.services[key] = svc
+	}
+	t, ok := svc.tables[eit.TableID]
+	if !ok || t.version != eit.Version {
+		t = &epgTable{
+			version:                   eit.Version,
+			segmentLastSectionNumbers: map[byte]byte{},
+			sections:                  map[byte]EITTable{},
+		}
+		svc.tables[eit.TableID] = t
+	}
+	t.lastSectionNumber = eit.LastSectionNumber
+	t.lastTableID = eit.LastTableID
+	t.segmentLastSectionNumbers[eit.SectionNumber/8] = eit.SegmentLastSectionNumber
+	t.sections[eit.SectionNumber] = eit
+}
+
+// SetServiceName sets the name written to XMLTV display-name.
+func (epg *EPG) SetServiceName(key EPGServiceKey, name string) {
+	epg.mutex.Lock()
+	defer epg.mutex.Unlock()
+	svc, ok := epg.services[key]
+	if !ok {
+		svc = &epgService{tables: map[byte]*epgTable{}}
+		epg.services[key] = svc
+	}
+	svc.name = name
+}
+
+// Services returns the services which have at least one EIT section, sorted by service ID.
+func (epg *EPG) Services() []EPGServiceKey {
+	epg.mutex.Lock()
+	defer epg.mutex.Unlock()
+	keys := make([]EPGServiceKey, 0, len(epg.services))
+	for k, svc := range epg.services {
+		if len(svc.tables) > 0 {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].ServiceID != keys[j].ServiceID {
+			return keys[i].ServiceID < keys[j].ServiceID
+		}
+		if keys[i].OriginalNetworkID != keys[j].OriginalNetworkID {
+			return keys[i].OriginalNetworkID < keys[j].OriginalNetworkID
+		}
+		return keys[i].TransportStreamID < keys[j].TransportStreamID
+	})
+	return keys
+}
+
+// IsComplete reports whether every announced section of the present/following and
+// schedule tables of the service has been received.
+func (epg *EPG) IsComplete(serviceID uint16) bool {
+	epg.mutex.Lock()
+	defer epg.mutex.Unlock()
+	found := false
+	for k, svc := range epg.services {
+		if k.ServiceID != serviceID || len(svc.tables) == 0 {
+			continue
+		}
+		found = true
+		if !svc.isComplete() {
+			return false
+		}
+	}
+	return found
+}
+
+func (svc *epgService) isComplete() bool {
+	for _, first := range []byte{
+		TableID_EventInformationSection_ActualDVBTransportStreamScheduleMin,
+		TableID_EventInformationSection_OtherDVBTransportStreamScheduleMin,
+	} {
+		t, ok := svc.tables[first]
+		if !ok {
+			continue
+		}
+		for tid := first; tid <= t.lastTableID && tid < first+0x10; tid++ {
+			st, ok := svc.tables[tid]
+			if !ok || !st.isComplete() {
+				return false
+			}
+		}
+	}
+	for _, tid := range []byte{
+		TableID_EventInformationSection_ActualDVBTransportStreamPresentFollowing,
+		TableID_EventInformationSection_OtherDVBTransportStreamPresentFollowing,
+	} {
+		if t, ok := svc.tables[tid]; ok && !t.isComplete() {
+			return false
+		}
+	}
+	return true
+}
+
+// a table consists of 32 segments of 8 sections
+// ETSI TS 101 211 V1.13.1 4.1.4
+func (t *epgTable) isComplete() bool {
+	for seg := byte(0); seg <= t.lastSectionNumber/8; seg++ {
+		segLast, ok := t.segmentLastSectionNumbers[seg]
+		if !ok {
+			return false
+		}
+		if segLast > t.lastSectionNumber || segLast/8 != seg {
+			// broken segment_last_section_number. expect the whole segment
+			segLast = seg*8 + 7
+			if segLast > t.lastSectionNumber {
+				segLast = t.lastSectionNumber
+			}
+		}
+		for n := seg * 8; n <= segLast; n++ {
+			if _, ok := t.sections[n]; !ok {
+				return false
+			}
+			if n == 0xff {
+				break
+			}
+		}
+	}
+	return true
+}
+
+// Events returns the events of the service sorted by start time.
+// present/following information takes precedence over schedule information.
+func (epg *EPG) Events(serviceID uint16) []EPGEvent {
+	epg.mutex.Lock()
+	defer epg.mutex.Unlock()
+	events := []EPGEvent{}
+	for k, svc := range epg.services {
+		if k.ServiceID != serviceID {
+			continue
+		}
+		events = append(events, svc.events(k)...)
+	}
+	sortEPGEvents(events)
+	return events
+}
+
+// EventsBetween returns the events of the service which overlap with [start, end).
+func (epg *EPG) EventsBetween(serviceID uint16, start, end time.Time) []EPGEvent {
+	events := []EPGEvent{}
+	for _, e := range epg.Events(serviceID) {
+		if e.StartTime.Before(end) && e.EndTime().After(start) {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// Event looks up an event by service ID and event ID.
+func (epg *EPG) Event(serviceID, eventID uint16) (EPGEvent, bool) {
+	for _, e := range epg.Events(serviceID) {
+		if e.EventID == eventID {
+			return e, true
+		}
+	}
+	return EPGEvent{}, false
+}
+
+func (svc *epgService) events(key EPGServiceKey) []EPGEvent {
+	tids := make([]int, 0, len(svc.tables))
+	for tid := range svc.tables {
+		tids = append(tids, int(tid))
+	}
+	// schedule tables first, present/following last to overwrite them
+	sort.Slice(tids, func(i, j int) bool {
+		pi := tids[i] <= TableID_EventInformationSection_OtherDVBTransportStreamPresentFollowing
+		pj := tids[j] <= TableID_EventInformationSection_OtherDVBTransportStreamPresentFollowing
+		if pi != pj {
+			return pj
+		}
+		return tids[i] < tids[j]
+	})
+
+	byID := map[uint16]EPGEvent{}
+	for _, tid := range tids {
+		t := svc.tables[byte(tid)]
+		for _, s := range t.sections {
+			for _, e := range s.Events {
+				byID[e.EventID] = newEPGEvent(key, e, s.siOptions)
+			}
+		}
+	}
+	events := make([]EPGEvent, 0, len(byID))
+	for _, e := range byID {
+		events = append(events, e)
+	}
+	return events
+}
+
+func sortEPGEvents(events []EPGEvent) {
+	sort.Slice(events, func(i, j int) bool {
+		if !events[i].StartTime.Equal(events[j].StartTime) {
+			return events[i].StartTime.Before(events[j].StartTime)
+		}
+		return events[i].ServiceID < events[j].ServiceID
+	})
+}
+
+func newEPGEvent(key EPGServiceKey, e EITEvent, opts SIOptions) EPGEvent {
+	ev := EPGEvent{
+		EPGServiceKey: key,
+		EventID:       e.EventID,
+		StartTime:     e.StartTime,
+		Duration:      e.Duration,
+		RunningStatus: e.RunningStatus,
+		FreeCAMode:    e.FreeCAMode,
+	}
+
+	extended := []ExtendedEventDescriptor{}
+	for _, d := range e.Descriptors {
+		switch d.Tag {
+		case 0x4D: // short_event_descriptor
+			ev.Language = iso639String(d.ShortEventDescriptor.ISO639LanguageCode)
+			ev.Title = d.ShortEventDescriptor.EventName
+			ev.Description = d.ShortEventDescriptor.Text
+		case 0x4E: // extended_event_descriptor
+			extended = append(extended, d.ExtendedEventDescriptor)
+		case 0x50: // component_descriptor
+			ev.Components = append(ev.Components, d.ComponentDescriptor)
+		case 0x54: // content_descriptor
+			ev.Genres = append(ev.Genres, d.ContentDescriptor.Contents...)
+		}
+	}
+	ev.ExtendedItems, ev.ExtendedText = joinExtendedEvent(extended, opts)
+	return ev
+}
+
+// joinExtendedEvent concatenates extended_event_descriptors in descriptor_number order.
+// An item with empty item_description continues the previous item, so the raw bytes are
+// joined before decoding because a character may be split across descriptors.
+func joinExtendedEvent(descs []ExtendedEventDescriptor, opts SIOptions) ([]EPGExtendedItem, string) {
+	if len(descs) == 0 {
+		return nil, ""
+	}
+	lang := descs[0].ISO639LanguageCode
+	sort.SliceStable(descs, func(i, j int) bool {
+		return descs[i].DescriptorNumber < descs[j].DescriptorNumber
+	})
+
+	type rawItem struct {
+		description []byte
+		text        []byte
+	}
+	rawItems := []rawItem{}
+	text := []byte{}
+	for _, d := range descs {
+		if d.ISO639LanguageCode != lang {
+			continue
+		}
+		for _, item := range d.Items {
+			if len(item.ItemDescriptionChar) == 0 && len(rawItems) > 0 {
+				last := &rawItems[len(rawItems)-1]
+				last.text = append(last.text, item.ItemChar...)
+				continue
+			}
+			rawItems = append(rawItems, rawItem{
+				description: copyBytes(item.ItemDescriptionChar),
+				text:        copyBytes(item.ItemChar),
+			})
+		}
+		text = append(text, d.TextChar...)
+	}
+
+	items := make([]EPGExtendedItem, 0, len(rawItems))
+	for _, ri := range rawItems {
+		items = append(items, EPGExtendedItem{
+			Description: opts.decodeText(ri.description),
+			Text:        opts.decodeText(ri.text),
+		})
+	}
+	return items, opts.decodeText(text)
+}
+
+func iso639String(code int) string {
+	if code == 0 {
+		return ""
+	}
+	return string([]byte{byte(code >> 16), byte(code >> 8), byte(code)})
+}
+
+// XMLTV
+// https://github.com/XMLTV/xmltv/blob/master/xmltv.dtd
+
+type xmltvDocument struct {
+	XMLName       xml.Name         `xml:"tv"`
+	GeneratorName string           `xml:"generator-info-name,attr"`
+	Channels      []xmltvChannel   `xml:"channel"`
+	Programmes    []xmltvProgramme `xml:"programme"`
+}
+
+type xmltvChannel struct {
+	ID           string   `xml:"id,attr"`
+	DisplayNames []string `xml:"display-name"`
+}
+
+type xmltvProgramme struct {
+	Start      string      `xml:"start,attr"`
+	Stop       string      `xml:"stop,attr"`
+	Channel    string      `xml:"channel,attr"`
+	Titles     []xmltvText `xml:"title"`
+	Descs      []xmltvText `xml:"desc"`
+	Categories []xmltvText `xml:"category"`
+}
+
+type xmltvText struct {
+	Lang  string `xml:"lang,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+const xmltvTimeLayout = "20060102150405 -0700"
+
+// XMLTVChannelID returns the channel id used in WriteXMLTV.
+func XMLTVChannelID(key EPGServiceKey) string {
+	return fmt.Sprintf("%d.%d.%d", key.OriginalNetworkID, key.TransportStreamID, key.ServiceID)
+}
+
+// WriteXMLTV writes the guide in XMLTV format.
+func (epg *EPG) WriteXMLTV(w io.Writer) error {
+	doc := xmltvDocument{GeneratorName: "go-mpeg2-ts"}
+	genres := epg.GenreNames
+	if genres == nil {
+		genres = epg.SIOptions.contentGenres()
+	}
+	for _, key := range epg.Services() {
+		epg.mutex.Lock()
+		svc := epg.services[key]
+		name := svc.name
+		events := svc.events(key)
+		epg.mutex.Unlock()
+		sortEPGEvents(events)
+
+		if name == "" {
+			name = fmt.Sprintf("%d", key.ServiceID)
+		}
+		id := XMLTVChannelID(key)
+		doc.Channels = append(doc.Channels, xmltvChannel{ID: id, DisplayNames: []string{name}})
+		for _, e := range events {
+			if e.StartTime.IsZero() {
+				continue
+			}
+			p := xmltvProgramme{
+				Start:   e.StartTime.Format(xmltvTimeLayout),
+				Stop:    e.EndTime().Format(xmltvTimeLayout),
+				Channel: id,
+				Titles:  []xmltvText{{Lang: e.Language, Value: e.Title}},
+			}
+			desc := e.Description
+			for _, item := range e.ExtendedItems {
+				desc += "\n\n" + item.Description + "\n" + item.Text
+			}
+			if e.ExtendedText != "" {
+				desc += "\n\n" + e.ExtendedText
+			}
+			if desc != "" {
+				p.Descs = []xmltvText{{Lang: e.Language, Value: desc}}
+			}
+			for _, g := range e.Genres {
+				if name, ok := genres[g.ContentNibbleLevel1]; ok {
+					p.Categories = append(p.Categories, xmltvText{Lang: "en", Value: name})
+				}
+			}
+			doc.Programmes = append(doc.Programmes, p)
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header+"<!DOCTYPE tv SYSTEM \"xmltv.dtd\">\n"); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// AddSDT sets the service names of the SDT services written to XMLTV display-name.
+func (epg *EPG) AddSDT(sdt SDT) {
+	for _, svc := range sdt.Services {
+		if svc.ServiceName == "" {
+			continue
+		}
+		epg.SetServiceName(EPGServiceKey{sdt.OriginalNetworkID, sdt.TransportStreamID, svc.ServiceID}, svc.ServiceName)
+	}
+}
@@ -0,0 +1,43 @@
+package mpeg2ts
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEPGGenreNames(t *testing.T) {
+	eit := EITTable{
+		TableID:              TableID_EventInformationSection_ActualDVBTransportStreamPresentFollowing,
+		ServiceID:            1,
+		CurrentNextIndicator: true,
+		Events: []EITEvent{{
+			EventID:   1,
+			StartTime: fixtureTime,
+			Duration:  time.Hour,
+			Descriptors: []EITDescriptor{
+				{Tag: 0x54, ContentDescriptor: ContentDescriptor{Contents: []ContentNibble{{ContentNibbleLevel1: 0x1}}}},
+			},
+		}},
+	}
+	for _, tc := range []struct {
+		name string
+		opts SIOptions
+		want string
+	}{
+		{"DVB", SIOptions{}, "Movie/Drama"},
+		{"ARIB", ARIBSIOptions(), "Sports"},
+	} {
+		epg := NewEPG()
+		epg.SIOptions = tc.opts
+		epg.AddEIT(eit)
+		buf := &bytes.Buffer{}
+		if err := epg.WriteXMLTV(buf); err != nil {
+			t.Fatal(err)
+		}
+		if want := `<category lang="en">` + tc.want + `</category>`; !strings.Contains(buf.String(), want) {
+			t.Errorf("%s: no %s in\n%s", tc.name, want, buf.String())
+		}
+	}
+}
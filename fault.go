@@ -0,0 +1,381 @@
+package mpeg2ts
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// FaultType is the kind of impairment which FaultInjector injected.
+type FaultType int
+
+const (
+	FaultPacketLoss FaultType = iota
+	FaultBitError
+	FaultContinuityError
+	FaultReorder
+	FaultPCRJitter
+	FaultDuplicate
+	FaultTransportError
+	FaultCRCError
+	FaultSyncLoss
+	FaultPCRJump
+)
+
+func (t FaultType) String() string {
+	switch t {
+	case FaultPacketLoss:
+		return "packet loss"
+	case FaultBitError:
+		return "bit error"
+	case FaultContinuityError:
+		return "continuity error"
+	case FaultReorder:
+		return "reorder"
+	case FaultPCRJitter:
+		return "PCR jitter"
+	case FaultDuplicate:
+		return "duplicate"
+	case FaultTransportError:
+		return "transport error"
+	case FaultCRCError:
+		return "CRC error"
+	case FaultSyncLoss:
+		return "sync loss"
+	case FaultPCRJump:
+		return "PCR jump"
+	}
+	return fmt.Sprintf("unknown fault %d", int(t))
+}
+
+// PacketFaults are the impairments of the packets of a PID. Probabilities are per packet, from 0 to 1.
+type PacketFaults struct {
+	// drops a packet
+	Loss float64
+	// drops BurstLength packets of the PID in a row. 0 packets for 1
+	BurstLoss   float64
+	BurstLength int
+	// flips BitFlips bits of the payload and sets transport_error_indicator, as a demodulator
+	// does for a packet it cannot correct. 0 bits for 1
+	BitError float64
+	BitFlips int
+	// replaces continuity_counter of a packet with payload, other than a null packet, with another value
+	ContinuityError float64
+	// sets transport_error_indicator without bit errors
+	TransportError float64
+	// flips a bit of the last byte of the first section in a packet which starts a section, which is
+	// CRC_32 if the section fits in the packet. for the PIDs of sections
+	CRCError float64
+	// breaks sync_byte of SyncLossPackets packets of any PID in a row. 0 packets for 1
+	SyncLoss        float64
+	SyncLossPackets int
+	// sends a packet behind the next ReorderDistance input packets of any PID. 0 packets for 1
+	Reorder         float64
+	ReorderDistance int
+	// moves every PCR by a random offset up to PCRJitter either way
+	PCRJitter time.Duration
+	// adds PCRJump to PCR of the PID every PCRJumpInterval of its PCR, without discontinuity_indicator
+	PCRJump         time.Duration
+	PCRJumpInterval time.Duration
+	// sends a packet twice
+	Duplicate float64
+}
+
+// FaultEvent is an impairment which FaultInjector injected into a packet.
+type FaultEvent struct {
+	Type FaultType
+	// of the packet in the input of FaultInjector, counted from 0
+	Position int
+	// Packet.Index and PID of the packet
+	Index       int
+	PID         PID
+	Description string
+}
+
+// FaultInjector impairs the packets between TransportStreamEngine and PacketWriter, for testing
+// the error resilience of decoders. Faults are drawn from a random source of the seed, so the same
+// input gets the same faults in every run, and every fault is logged as a FaultEvent.
+type FaultInjector struct {
+	// faults of the PIDs which are not in PIDFaults
+	Faults    PacketFaults
+	PIDFaults map[PID]PacketFaults
+
+	rng      *rand.Rand
+	position int
+	// packets left in the burst loss of the PIDs
+	bursts  map[PID]faultBurst
+	delayed []faultDelayedPacket
+	// packets left without sync_byte
+	syncLoss int
+	pcrJumps map[PID]faultPCRJump
+	events   []FaultEvent
+	mutex    *sync.Mutex
+}
+
+type faultBurst struct {
+	lost   int
+	length int
+}
+
+type faultPCRJump struct {
+	// PCR of the input when it jumped last
+	last   int64
+	offset int64
+}
+
+type faultDelayedPacket struct {
+	packet   Packet
+	position int
+	// input packets until it is sent
+	wait int
+}
+
+func NewFaultInjector(seed int64) FaultInjector {
+	fi := FaultInjector{PIDFaults: map[PID]PacketFaults{}}
+	fi.rng = rand.New(rand.NewSource(seed))
+	fi.bursts = map[PID]faultBurst{}
+	fi.pcrJumps = map[PID]faultPCRJump{}
+	fi.mutex = &sync.Mutex{}
+	return fi
+}
+
+// EnqueueTSPacket returns the packets to send after the packet: none if it is lost, the packet,
+// the packet and its duplicate, and the delayed packets which it has overtaken.
+func (fi *FaultInjector) EnqueueTSPacket(p Packet) []Packet {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+
+	position := fi.position
+	fi.position++
+	f, ok := fi.PIDFaults[p.PID]
+	if !ok {
+		f = fi.Faults
+	}
+
+	out := []Packet{}
+	if !fi.lose(position, p, &f) {
+		p = fi.impair(position, p, &f)
+		if fi.chance(f.Reorder) {
+			wait := f.ReorderDistance
+			if wait <= 0 {
+				wait = 1
+			}
+			fi.delayed = append(fi.delayed, faultDelayedPacket{packet: p, position: position, wait: wait})
+			fi.log(FaultReorder, position, p, "delayed by %d packets", wait)
+		} else {
+			out = append(out, p)
+			if fi.chance(f.Duplicate) {
+				out = append(out, p.DeepCopy())
+				fi.log(FaultDuplicate, position, p, "sent twice")
+			}
+		}
+	}
+
+	delayed := fi.delayed[:0]
+	for _, d := range fi.delayed {
+		if d.position != position {
+			d.wait--
+		}
+		if d.wait <= 0 {
+			out = append(out, d.packet)
+		} else {
+			delayed = append(delayed, d)
+		}
+	}
+	fi.delayed = delayed
+	return out
+}
+
+// Flush returns the delayed packets which have not been sent at the end of the input.
+func (fi *FaultInjector) Flush() []Packet {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+	out := []Packet{}
+	for _, d := range fi.delayed {
+		out = append(out, d.packet)
+	}
+	fi.delayed = nil
+	return out
+}
+
+// Events returns the faults which have been injected, in the order of the input packets.
+func (fi *FaultInjector) Events() []FaultEvent {
+	fi.mutex.Lock()
+	defer fi.mutex.Unlock()
+	events := make([]FaultEvent, len(fi.events))
+	copy(events, fi.events)
+	return events
+}
+
+// StartFaultInjectLoop impairs the packets from the channel, for example the output of
+// TransportStreamEngine.StartPacketReadLoop, and sends them to the returned channel, which
+// PacketWriter.Run can write. The channel is closed when the input is closed or ctx is done.
+func (fi *FaultInjector) StartFaultInjectLoop(ctx context.Context, packets <-chan Packet) <-chan Packet {
+	cp := make(chan Packet)
+	go func(packetOutChan chan Packet) {
+		defer close(packetOutChan)
+		send := func(out []Packet) bool {
+			for _, p := range out {
+				select {
+				case <-ctx.Done():
+					return false
+				case packetOutChan <- p:
+				}
+			}
+			return true
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case p, ok := <-packets:
+				if !ok {
+					send(fi.Flush())
+					return
+				}
+				if !send(fi.EnqueueTSPacket(p)) {
+					return
+				}
+			}
+		}
+	}(cp)
+	return cp
+}
+
+// lose reports whether the packet is dropped by the random or the burst loss.
+func (fi *FaultInjector) lose(position int, p Packet, f *PacketFaults) bool {
+	if b, ok := fi.bursts[p.PID]; ok {
+		b.lost++
+		if b.lost < b.length {
+			fi.bursts[p.PID] = b
+		} else {
+			delete(fi.bursts, p.PID)
+		}
+		fi.log(FaultPacketLoss, position, p, "burst loss %d of %d", b.lost, b.length)
+		return true
+	}
+	if fi.chance(f.BurstLoss) {
+		b := faultBurst{lost: 1, length: f.BurstLength}
+		if b.length <= 0 {
+			b.length = 1
+		}
+		if b.length > 1 {
+			fi.bursts[p.PID] = b
+		}
+		fi.log(FaultPacketLoss, position, p, "burst loss %d of %d", b.lost, b.length)
+		return true
+	}
+	if fi.chance(f.Loss) {
+		fi.log(FaultPacketLoss, position, p, "random loss")
+		return true
+	}
+	return false
+}
+
+// impair returns a copy of the packet with the bit error, the transport error, the continuity error,
+// the CRC error, the PCR jump and jitter, and the sync loss.
+func (fi *FaultInjector) impair(position int, p Packet, f *PacketFaults) Packet {
+	p = p.DeepCopy()
+	if len(p.Data) < PacketSizeDefault {
+		return p
+	}
+
+	if fi.chance(f.BitError) {
+		start := 4
+		if p.HasAdaptationField() {
+			start = 5 + int(p.AdaptationField.Length)
+		}
+		flips := f.BitFlips
+		if flips <= 0 {
+			flips = 1
+		}
+		bits := []int{}
+		for i := 0; i < flips && start < PacketSizeDefault; i++ {
+			bit := start*8 + fi.rng.Intn((PacketSizeDefault-start)*8)
+			p.Data[bit/8] ^= 0x80 >> (bit % 8)
+			bits = append(bits, bit)
+		}
+		p.Data[1] |= 0x80
+		p.TransportErrorIndicator = true
+		fi.log(FaultBitError, position, p, "flipped bits %v and set transport_error_indicator", bits)
+	}
+	if fi.chance(f.TransportError) {
+		p.Data[1] |= 0x80
+		p.TransportErrorIndicator = true
+		fi.log(FaultTransportError, position, p, "set transport_error_indicator")
+	}
+
+	if p.PID != PID_NullPacket && p.AdaptationFieldControl&0x01 != 0 && fi.chance(f.ContinuityError) {
+		cc := (p.ContinuityCheckIndex + 1 + byte(fi.rng.Intn(15))) & 0x0f
+		fi.log(FaultContinuityError, position, p, "continuity_counter %d to %d", p.ContinuityCheckIndex, cc)
+		p.Data[3] = p.Data[3]&0xf0 | cc
+		p.ContinuityCheckIndex = cc
+	}
+
+	if p.PayloadUnitStartIndicator && p.AdaptationFieldControl&0x01 != 0 && fi.chance(f.CRCError) {
+		start := 4
+		if p.HasAdaptationField() {
+			start = 5 + int(p.AdaptationField.Length)
+		}
+		if start < PacketSizeDefault {
+			start += 1 + int(p.Data[start]) // pointer_field
+		}
+		if start+3 <= PacketSizeDefault {
+			end := start + 3 + (int(p.Data[start+1]&0x0f)<<8 | int(p.Data[start+2]))
+			if end > PacketSizeDefault {
+				end = PacketSizeDefault
+			}
+			p.Data[end-1] ^= 0x01
+			fi.log(FaultCRCError, position, p, "flipped the last bit of byte %d", end-1)
+		}
+	}
+
+	af := &p.AdaptationField
+	if p.HasAdaptationField() && af.Length > 0 && af.PCRFlag {
+		pcr := pcrValue(af.ProgramClockReference)
+		if jump := durationToClock(f.PCRJump); jump != 0 && f.PCRJumpInterval > 0 {
+			j, ok := fi.pcrJumps[p.PID]
+			if !ok {
+				j.last = pcr
+			} else if d := ((pcr-j.last)%pcrWrap + pcrWrap) % pcrWrap; d >= durationToClock(f.PCRJumpInterval) {
+				j.last = pcr
+				j.offset += jump
+				fi.log(FaultPCRJump, position, p, "PCR jumped by %d to the offset %d", jump, j.offset)
+			}
+			fi.pcrJumps[p.PID] = j
+			pcr += j.offset
+		}
+		if jitter := durationToClock(f.PCRJitter); jitter > 0 {
+			offset := fi.rng.Int63n(2*jitter+1) - jitter
+			pcr += offset
+			fi.log(FaultPCRJitter, position, p, "PCR moved by %d", offset)
+		}
+		if pcr != pcrValue(af.ProgramClockReference) {
+			af.ProgramClockReference = setPCR(p.Data[6:], pcr)
+		}
+	}
+
+	if fi.syncLoss == 0 && fi.chance(f.SyncLoss) {
+		fi.syncLoss = f.SyncLossPackets
+		if fi.syncLoss <= 0 {
+			fi.syncLoss = 1
+		}
+	}
+	if fi.syncLoss > 0 {
+		fi.syncLoss--
+		p.Data[0] ^= 0xff
+		p.SyncByte = p.Data[0]
+		fi.log(FaultSyncLoss, position, p, "sync_byte %02X", p.SyncByte)
+	}
+	return p
+}
+
+func (fi *FaultInjector) chance(p float64) bool {
+	return p > 0 && fi.rng.Float64() < p
+}
+
+func (fi *FaultInjector) log(t FaultType, position int, p Packet, format string, a ...interface{}) {
+	fi.events = append(fi.events, FaultEvent{Type: t, Position: position, Index: p.Index, PID: p.PID, Description: fmt.Sprintf(format, a...)})
+}
@@ -0,0 +1,250 @@
+package mpeg2ts
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func injectFaults(fi *FaultInjector, packets []Packet) []Packet {
+	out := []Packet{}
+	for _, p := range packets {
+		out = append(out, fi.EnqueueTSPacket(p)...)
+	}
+	return append(out, fi.Flush()...)
+}
+
+func muxedPackets(t *testing.T) []Packet {
+	t.Helper()
+	m, _ := fixtureMux(t, time.Second, 25, fixtureProgram())
+	return m.PacketList.All()
+}
+
+func TestFaultInjectorSeed(t *testing.T) {
+	packets := muxedPackets(t)
+	faults := PacketFaults{Loss: 0.01, BurstLoss: 0.005, BurstLength: 4, BitError: 0.01, BitFlips: 3, ContinuityError: 0.01, Reorder: 0.01, ReorderDistance: 3, PCRJitter: time.Millisecond, Duplicate: 0.01}
+	run := func(seed int64) ([]byte, []FaultEvent) {
+		fi := NewFaultInjector(seed)
+		fi.Faults = faults
+		b := []byte{}
+		for _, p := range injectFaults(&fi, packets) {
+			b = append(b, p.Data...)
+		}
+		return b, fi.Events()
+	}
+
+	b, events := run(1)
+	again, eventsAgain := run(1)
+	if !bytes.Equal(b, again) || len(events) != len(eventsAgain) {
+		t.Fatal("faults are not reproduced by the seed")
+	}
+	for i := range events {
+		if events[i] != eventsAgain[i] {
+			t.Errorf("event %d: %+v, want %+v", i, eventsAgain[i], events[i])
+		}
+	}
+	if other, _ := run(2); bytes.Equal(b, other) {
+		t.Error("faults of another seed are the same")
+	}
+
+	types := map[FaultType]int{}
+	for _, e := range events {
+		types[e.Type]++
+	}
+	for _, ft := range []FaultType{FaultPacketLoss, FaultBitError, FaultContinuityError, FaultReorder, FaultPCRJitter, FaultDuplicate} {
+		if types[ft] == 0 {
+			t.Errorf("no %s", ft)
+		}
+	}
+	// the input is not modified
+	for _, p := range packets {
+		if p.TransportErrorIndicator || p.Data[1]&0x80 != 0 {
+			t.Fatalf("packet %d of the input is impaired", p.Index)
+		}
+	}
+}
+
+func TestFaultInjectorPID(t *testing.T) {
+	packets := muxedPackets(t)
+	fi := NewFaultInjector(0)
+	fi.PIDFaults[fixtureAudioPID] = PacketFaults{Loss: 1}
+	fi.PIDFaults[fixtureVideoPID] = PacketFaults{BitError: 1, ContinuityError: 1}
+	out := injectFaults(&fi, packets)
+
+	audio, video := 0, 0
+	for _, p := range packets {
+		switch p.PID {
+		case fixtureAudioPID:
+			audio++
+		case fixtureVideoPID:
+			video++
+		}
+	}
+	if len(out) != len(packets)-audio {
+		t.Fatalf("%d packets, want %d", len(out), len(packets)-audio)
+	}
+	in := map[int]Packet{}
+	for _, p := range packets {
+		in[p.Index] = p
+	}
+	for _, p := range out {
+		switch p.PID {
+		case fixtureAudioPID:
+			t.Fatalf("packet %d is not lost", p.Index)
+		case fixtureVideoPID:
+			if !p.TransportErrorIndicator || p.Data[1]&0x80 == 0 {
+				t.Errorf("packet %d: transport_error_indicator is not set", p.Index)
+			}
+			if p.ContinuityCheckIndex == in[p.Index].ContinuityCheckIndex && p.AdaptationFieldControl&0x01 != 0 {
+				t.Errorf("packet %d: continuity_counter is not changed", p.Index)
+			}
+		default:
+			if !bytes.Equal(p.Data, in[p.Index].Data) {
+				t.Errorf("packet %d of PID 0x%04x is impaired", p.Index, p.PID)
+			}
+		}
+	}
+	if events := fi.Events(); len(events) != audio+2*video {
+		t.Errorf("%d events, want %d", len(events), audio+2*video)
+	}
+}
+
+func TestFaultInjectorBurstLoss(t *testing.T) {
+	packets := muxedPackets(t)
+	fi := NewFaultInjector(3)
+	fi.PIDFaults[fixtureVideoPID] = PacketFaults{BurstLoss: 0.05, BurstLength: 5}
+	injectFaults(&fi, packets)
+
+	events := fi.Events()
+	if len(events) == 0 {
+		t.Fatal("no burst loss")
+	}
+	// the packets of a burst are lost in a row on the PID
+	for i, e := range events {
+		if e.Type != FaultPacketLoss || e.PID != fixtureVideoPID || e.Description != fmt.Sprintf("burst loss %d of 5", i%5+1) {
+			t.Fatalf("event %d: %+v", i, e)
+		}
+	}
+	if len(events)%5 != 0 {
+		t.Errorf("%d packets are lost in bursts of 5", len(events))
+	}
+}
+
+func TestFaultInjectorReorder(t *testing.T) {
+	packets := []Packet{}
+	for i := 0; i < 6; i++ {
+		pid := fixtureAudioPID
+		if i == 1 {
+			pid = fixtureVideoPID
+		}
+		pl, _ := NewPacketList(PacketSizeDefault)
+		if err := pl.AddBytes(fixturePacket(pid, false, byte(i), nil, fixturePayload(184, byte(i))), PacketSizeDefault); err != nil {
+			t.Fatal(err)
+		}
+		p := pl.All()[0]
+		p.Index = i
+		packets = append(packets, p)
+	}
+
+	fi := NewFaultInjector(0)
+	fi.PIDFaults[fixtureVideoPID] = PacketFaults{Reorder: 1, ReorderDistance: 2}
+	out := injectFaults(&fi, packets)
+	order := []int{}
+	for _, p := range out {
+		order = append(order, p.Index)
+	}
+	// packet 1 is sent behind packets 2 and 3
+	if want := []int{0, 2, 3, 1, 4, 5}; fmt.Sprint(order) != fmt.Sprint(want) {
+		t.Errorf("order %v, want %v", order, want)
+	}
+
+	// delayed packets are sent at the end of the input
+	fi = NewFaultInjector(0)
+	fi.PIDFaults[fixtureVideoPID] = PacketFaults{Reorder: 1, ReorderDistance: 10}
+	out = injectFaults(&fi, packets)
+	if len(out) != len(packets) || out[len(out)-1].Index != 1 {
+		t.Errorf("packet 1 is not flushed")
+	}
+}
+
+func TestFaultInjectLoop(t *testing.T) {
+	packets := muxedPackets(t)
+	in := make(chan Packet)
+	go func() {
+		for _, p := range packets {
+			in <- p
+		}
+		close(in)
+	}()
+
+	fi := NewFaultInjector(0)
+	buf := &bytes.Buffer{}
+	pw, err := NewPacketWriter(buf, PacketSizeDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pw.Run(context.Background(), fi.StartFaultInjectLoop(context.Background(), in)); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{}
+	for _, p := range packets {
+		want = append(want, p.Data...)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Error("packets without faults are not written as they are")
+	}
+}
+
+func TestFaultInjectorStreamFaults(t *testing.T) {
+	packets := muxedPackets(t)
+	fi := NewFaultInjector(0)
+	fi.PIDFaults[PID_PAT] = PacketFaults{CRCError: 1, TransportError: 1}
+	fi.PIDFaults[fixtureVideoPID] = PacketFaults{PCRJump: time.Second, PCRJumpInterval: 200 * time.Millisecond}
+	fi.PIDFaults[fixtureAudioPID] = PacketFaults{SyncLoss: 0.05, SyncLossPackets: 2}
+	out := injectFaults(&fi, packets)
+	if len(out) != len(packets) {
+		t.Fatalf("%d packets, want %d", len(out), len(packets))
+	}
+
+	in := map[int]Packet{}
+	for _, p := range packets {
+		in[p.Index] = p
+	}
+	var jump int64
+	syncLoss := 0
+	for _, p := range out {
+		if p.Data[0] != 0x47 {
+			syncLoss++
+			continue
+		}
+		switch {
+		case p.PID == PID_PAT:
+			if _, err := p.ParsePAT(); err == nil || !p.TransportErrorIndicator {
+				t.Errorf("packet %d: PAT is not impaired", p.Index)
+			}
+		case p.PID == fixtureVideoPID && p.HasAdaptationField() && p.AdaptationField.PCRFlag:
+			d := pcrValue(p.AdaptationField.ProgramClockReference) - pcrValue(in[p.Index].AdaptationField.ProgramClockReference)
+			if d < jump || d%durationToClock(time.Second) != 0 {
+				t.Errorf("packet %d: PCR moved by %d after %d", p.Index, d, jump)
+			}
+			jump = d
+		}
+	}
+	// 1s of the stream
+	if jump < 4*durationToClock(time.Second) {
+		t.Errorf("PCR jumped by %d", jump)
+	}
+
+	types := map[FaultType]int{}
+	for _, e := range fi.Events() {
+		types[e.Type]++
+	}
+	if types[FaultSyncLoss] == 0 || types[FaultSyncLoss] != syncLoss {
+		t.Errorf("%d sync loss events for %d packets", types[FaultSyncLoss], syncLoss)
+	}
+	if types[FaultCRCError] == 0 || types[FaultCRCError] != types[FaultTransportError] {
+		t.Errorf("%d CRC errors and %d transport errors of PAT", types[FaultCRCError], types[FaultTransportError])
+	}
+}
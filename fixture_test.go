@@ -0,0 +1,500 @@
+package mpeg2ts
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// go test -run . -update rewrites the fixtures in testdata and the golden dumps in testdata/golden.
+var update = flag.Bool("update", false, "rewrite testdata from the fixture builders")
+
+const (
+	fixturePMTPID      PID = 0x0100
+	fixtureVideoPID    PID = 0x0101
+	fixtureAudioPID    PID = 0x0102
+	fixtureLargePMTPID PID = 0x0200
+)
+
+// loadFixture builds the stream of a fixture, checks that the checked-in file is the same and
+// loads the file.
+func loadFixture(t *testing.T, name string, build func() []byte) *MPEG2TS {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	want := build()
+	if *update {
+		if err := os.WriteFile(path, want, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("%s is not built by the fixture builder. run go test -update", path)
+	}
+	m, err := LoadStandardTS(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// checkGolden compares the JSON dump of v with testdata/golden/name.json. Zero values are
+// omitted from the dump, so that the descriptors which are not used do not fill it.
+func checkGolden(t *testing.T, name string, v interface{}) {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tree interface{}
+	if err := json.Unmarshal(b, &tree); err != nil {
+		t.Fatal(err)
+	}
+	got, err := json.MarshalIndent(pruneZero(tree), "", "\t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, '\n')
+
+	path := filepath.Join("testdata", "golden", name+".json")
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s does not match:\n%s", path, got)
+	}
+}
+
+func pruneZero(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, e := range x {
+			e = pruneZero(e)
+			if isZeroJSON(e) {
+				delete(x, k)
+				continue
+			}
+			x[k] = e
+		}
+		return x
+	case []interface{}:
+		for i, e := range x {
+			x[i] = pruneZero(e)
+		}
+		return x
+	}
+	return v
+}
+
+func isZeroJSON(v interface{}) bool {
+	switch x := v.(type) {
+	case nil:
+		return true
+	case bool:
+		return !x
+	case float64:
+		return x == 0
+	case string:
+		return x == "" || x == "0001-01-01T00:00:00Z"
+	case map[string]interface{}:
+		return len(x) == 0
+	case []interface{}:
+		return len(x) == 0
+	}
+	return false
+}
+
+// fixturePacket returns a TS packet. af is the adaptation field following
+// adaptation_field_length, and stuffing bytes are appended to fill the packet. The packet has
+// only the adaptation field if payload is nil.
+// Rec. ITU-T H.222.0 (06/2021) pp.22-29
+func fixturePacket(pid PID, pusi bool, cc byte, af []byte, payload []byte) []byte {
+	b := []byte{0x47, byte(pid>>8) & 0x1f, byte(pid), cc & 0x0f}
+	if pusi {
+		b[1] |= 0x40
+	}
+	if af == nil && len(payload) == PacketSizeDefault-4 {
+		b[3] |= AdaptationField_PayloadOnly << 4
+		return append(b, payload...)
+	}
+	if payload == nil {
+		b[3] |= AdaptationField_AdaptationFieldOnly << 4
+	} else {
+		b[3] |= AdaptationField_AdaptationFieldFollowed << 4
+	}
+	length := PacketSizeDefault - 5 - len(payload)
+	if af == nil && length > 0 {
+		af = []byte{0x00}
+	}
+	b = append(b, byte(length))
+	b = append(b, af...)
+	for len(b) < 5+length {
+		b = append(b, 0xff)
+	}
+	return append(b, payload...)
+}
+
+// fixtureSectionPackets puts the sections back to back into packets, starting with
+// pointer_field 0 and padded by 0xff.
+func fixtureSectionPackets(pid PID, cc byte, sections ...[]byte) []byte {
+	data := []byte{0x00}
+	for _, s := range sections {
+		data = append(data, s...)
+	}
+	b := []byte{}
+	for offset := 0; offset < len(data); offset += PacketSizeDefault - 4 {
+		payload := make([]byte, PacketSizeDefault-4)
+		for i := range payload {
+			payload[i] = 0xff
+		}
+		copy(payload, data[offset:])
+		b = append(b, fixturePacket(pid, offset == 0, cc, nil, payload)...)
+		cc++
+	}
+	return b
+}
+
+// fixtureLongSection returns a section with section_syntax_indicator and CRC_32.
+func fixtureLongSection(tableID byte, extension uint16, version, number, last byte, body []byte) []byte {
+	length := 5 + len(body) + 4
+	b := []byte{tableID, 0xb0 | byte(length>>8)&0x0f, byte(length), byte(extension >> 8), byte(extension), 0xc1 | version<<1, number, last}
+	b = append(b, body...)
+	crc := calculateCRC(b)
+	return append(b, byte(crc>>24), byte(crc>>16), byte(crc>>8), byte(crc))
+}
+
+func fixtureDescriptor(tag byte, data ...byte) []byte {
+	return append([]byte{tag, byte(len(data))}, data...)
+}
+
+// fixtureLoop returns a 12bit loop length with 4 reserved bits and the loop.
+func fixtureLoop(items ...[]byte) []byte {
+	loop := []byte{}
+	for _, item := range items {
+		loop = append(loop, item...)
+	}
+	return append([]byte{0xf0 | byte(len(loop)>>8), byte(len(loop))}, loop...)
+}
+
+func fixturePMTStream(st StreamType, pid PID, descriptors ...[]byte) []byte {
+	return append(append([]byte{byte(st)}, fixturePID(pid)...), fixtureLoop(descriptors...)...)
+}
+
+// fixturePID returns a PID with 3 reserved bits.
+func fixturePID(pid PID) []byte {
+	return []byte{0xe0 | byte(pid>>8), byte(pid)}
+}
+
+func fixturePAT(version, number, last byte, programs ...uint16) []byte {
+	body := []byte{}
+	for i := 0; i+1 < len(programs); i += 2 {
+		body = append(body, byte(programs[i]>>8), byte(programs[i]))
+		body = append(body, fixturePID(PID(programs[i+1]))...)
+	}
+	return fixtureLongSection(TableID_ProgramAssociationSection, 0x0001, version, number, last, body)
+}
+
+// PMT of program 1 with a registration descriptor, an AVC stream and an AAC stream
+func fixturePMT() []byte {
+	body := fixturePID(fixtureVideoPID)
+	body = append(body, fixtureLoop(fixtureDescriptor(0x05, 'H', 'D', 'M', 'V'))...)
+	// profile_idc 100, constraint_set flags, level_idc 40, AVC_still_present, AVC_24_hour_picture_flag
+	body = append(body, fixturePMTStream(StreamTypeAVC, fixtureVideoPID, fixtureDescriptor(0x28, 100, 0x0c, 40, 0x3f))...)
+	body = append(body, fixturePMTStream(StreamTypeISO13818_7_AudioWithADTS, fixtureAudioPID, fixtureDescriptor(0x0a, 'j', 'p', 'n', 0x00))...)
+	return fixtureLongSection(TableID_ProgramMapSection, 1, 2, 0, 0, body)
+}
+
+// PMT of program 2 which spans two packets
+func fixtureLargePMT() []byte {
+	body := fixturePID(fixtureLargePMTPID + 1)
+	body = append(body, fixtureLoop()...)
+	for i := 0; i < 4; i++ {
+		info := []byte{'T', 'E', 'S', 'T'}
+		for j := 0; j < 46; j++ {
+			info = append(info, byte(i*46+j))
+		}
+		body = append(body, fixturePMTStream(StreamTypeISO13818_2_Video+StreamType(i%2), fixtureLargePMTPID+1+PID(i), fixtureDescriptor(0x05, info...))...)
+	}
+	return fixtureLongSection(TableID_ProgramMapSection, 2, 0, 0, 0, body)
+}
+
+// psi.ts: PAT in a packet, PAT of two sections in a packet, PMT in a packet and PMT in two packets
+func fixturePSI() []byte {
+	b := fixtureSectionPackets(PID_PAT, 0, fixturePAT(3, 0, 0, 0, 0x0010, 1, uint16(fixturePMTPID), 2, uint16(fixtureLargePMTPID)))
+	b = append(b, fixtureSectionPackets(PID_PAT, 1, fixturePAT(4, 0, 1, 0, 0x0010, 1, uint16(fixturePMTPID)), fixturePAT(4, 1, 1, 2, uint16(fixtureLargePMTPID)))...)
+	b = append(b, fixtureSectionPackets(fixturePMTPID, 0, fixturePMT())...)
+	b = append(b, fixtureSectionPackets(fixtureLargePMTPID, 0, fixtureLargePMT())...)
+	return b
+}
+
+// fixturePCR returns program_clock_reference_base, 6 reserved bits and the extension.
+func fixturePCR(base uint64, extension uint16) []byte {
+	return []byte{byte(base >> 25), byte(base >> 17), byte(base >> 9), byte(base >> 1), byte(base<<7)&0x80 | 0x7e | byte(extension>>8)&0x01, byte(extension)}
+}
+
+func fixturePayload(n int, seed byte) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = seed + byte(i)
+	}
+	return b
+}
+
+// adaptation.ts: a packet for each variant of the adaptation field
+func fixtureAdaptationFields() []byte {
+	packets := [][]byte{
+		// adaptation field only, with stuffing
+		fixturePacket(fixtureVideoPID, false, 0, []byte{0x00}, nil),
+		// adaptation_field_length 0 and 183 bytes of payload
+		fixturePacket(fixtureVideoPID, false, 0, []byte{}, fixturePayload(183, 0x10)),
+		// discontinuity_indicator, random_access_indicator, elementary_stream_priority_indicator and PCR
+		fixturePacket(fixtureVideoPID, false, 1, append([]byte{0xf0}, fixturePCR(0x1_2345_6789, 299)...), fixturePayload(100, 0x20)),
+		// PCR and OPCR
+		fixturePacket(fixtureVideoPID, false, 2, append(append([]byte{0x18}, fixturePCR(900000, 1)...), fixturePCR(450000, 2)...), fixturePayload(160, 0x30)),
+		// splice_countdown -2
+		fixturePacket(fixtureVideoPID, false, 3, []byte{0x04, 0xfe}, fixturePayload(170, 0x40)),
+		// transport_private_data
+		fixturePacket(fixtureVideoPID, false, 4, []byte{0x02, 0x04, 'P', 'R', 'I', 'V'}, fixturePayload(150, 0x50)),
+		// adaptation_field_extension with ltw_flag and ltw_offset
+		fixturePacket(fixtureVideoPID, false, 5, []byte{0x01, 0x03, 0x9f, 0x80, 0x10}, fixturePayload(170, 0x60)),
+		// every field
+		fixturePacket(fixtureVideoPID, false, 6, append(append(append([]byte{0x5f}, fixturePCR(27000000, 0)...), fixturePCR(0, 0)...), 0x03, 0x01, 0xaa, 0x01, 0x1f), nil),
+	}
+	return bytes.Join(packets, nil)
+}
+
+// fixturePESTimestamp returns a 33bit timestamp with the 4bit prefix and marker bits.
+func fixturePESTimestamp(prefix byte, ts uint64) []byte {
+	return []byte{prefix<<4 | byte(ts>>29)&0x0e | 0x01, byte(ts >> 22), byte(ts>>14)&0xfe | 0x01, byte(ts >> 7), byte(ts<<1) | 0x01}
+}
+
+// fixturePES returns a PES packet. flags are the 2 bytes before PES_header_data_length and
+// fields are the optional fields including stuffing bytes.
+func fixturePES(streamID byte, flags []byte, fields []byte, data []byte) []byte {
+	b := []byte{0x00, 0x00, 0x01, streamID, 0x00, 0x00}
+	b = append(b, flags...)
+	b = append(b, byte(len(fields)))
+	b = append(b, fields...)
+	b = append(b, data...)
+	if streamID&0xf0 != 0xe0 {
+		// PES_packet_length 0 is allowed only for video
+		length := len(b) - 6
+		b[4], b[5] = byte(length>>8), byte(length)
+	}
+	return b
+}
+
+// fixturePESPackets splits a PES packet into packets. The adaptation field of the last packet
+// is stuffed.
+func fixturePESPackets(pid PID, cc byte, pes []byte) []byte {
+	b := []byte{}
+	for offset := 0; offset < len(pes); offset += PacketSizeDefault - 4 {
+		end := offset + PacketSizeDefault - 4
+		if end > len(pes) {
+			end = len(pes)
+		}
+		b = append(b, fixturePacket(pid, offset == 0, cc, nil, pes[offset:end])...)
+		cc++
+	}
+	return b
+}
+
+type fixturePESEntry struct {
+	pid PID
+	pes []byte
+}
+
+// every optional field of the PES header
+func fixturePESAllFields() []byte {
+	fields := fixturePESTimestamp(0x03, 0x1_0000_0000+183000)
+	fields = append(fields, fixturePESTimestamp(0x01, 180000)...)
+	// ESCR base 123456789 and extension 257 with reserved bits and marker bits
+	base, ext := uint64(123456789), uint16(257)
+	fields = append(fields, 0xc0|byte(base>>27)&0x38|0x04|byte(base>>28)&0x03, byte(base>>20), byte(base>>12)&0xf8|0x04|byte(base>>13)&0x03, byte(base>>5), byte(base<<3)|0x04|byte(ext>>7)&0x03, byte(ext<<1)|0x01)
+	// ES_rate 50000 in 50 bytes/s
+	rate := uint32(50000)
+	fields = append(fields, 0x80|byte(rate>>15)&0x7f, byte(rate>>7), byte(rate<<1)|0x01)
+	// trick_mode_control fast_forward, additional_copy_info, previous_PES_packet_CRC
+	fields = append(fields, 0x1f, 0x80|0x2a, 0x12, 0x34)
+	// PES_extension with no fields and 2 stuffing bytes
+	fields = append(fields, 0x0e, 0xff, 0xff)
+	return fixturePES(streamIDVideo, []byte{0x8f, 0xff}, fields, fixturePayload(300, 0x70))
+}
+
+func fixturePESEntries() []fixturePESEntry {
+	return []fixturePESEntry{
+		// PTS only
+		{fixtureVideoPID, fixturePES(streamIDVideo, []byte{0x84, 0x80}, fixturePESTimestamp(0x02, 90000), fixturePayload(50, 0x00))},
+		{fixtureAudioPID, fixturePES(streamIDAudio, []byte{0x84, 0x80}, fixturePESTimestamp(0x02, 93000), fixturePayload(200, 0x80))},
+		// PTS and DTS in two packets
+		{fixtureVideoPID, fixturePES(streamIDVideo, []byte{0x80, 0xc0}, append(fixturePESTimestamp(0x03, 99000), fixturePESTimestamp(0x01, 93000)...), fixturePayload(250, 0x40))},
+		{fixtureVideoPID, fixturePESAllFields()},
+		{fixtureAudioPID, fixturePES(streamIDAudio, []byte{0x84, 0x80}, fixturePESTimestamp(0x02, 96000), fixturePayload(20, 0x90))},
+		// the last PES packets are not emitted by PESParser, they end the previous ones
+		{fixtureVideoPID, fixturePES(streamIDVideo, []byte{0x80, 0x80}, fixturePESTimestamp(0x02, 108000), fixturePayload(10, 0xa0))},
+		{fixtureAudioPID, fixturePES(streamIDAudio, []byte{0x80, 0x80}, fixturePESTimestamp(0x02, 99000), fixturePayload(10, 0xb0))},
+	}
+}
+
+// pes.ts: PES packets of a video stream and an audio stream
+func fixturePESStream() []byte {
+	b := []byte{}
+	cc := map[PID]byte{}
+	for _, e := range fixturePESEntries() {
+		packets := fixturePESPackets(e.pid, cc[e.pid], e.pes)
+		cc[e.pid] += byte(len(packets) / PacketSizeDefault)
+		b = append(b, packets...)
+	}
+	return b
+}
+
+// fixtureMJD returns 16bit MJD and 24bit BCD coded time.
+// ETSI EN 300 468 V1.17.1 Annex C
+func fixtureMJD(t time.Time) []byte {
+	mjd := int(t.Sub(time.Date(1858, 11, 17, 0, 0, 0, 0, time.UTC)) / (24 * time.Hour))
+	bcd := func(v int) byte { return byte(v/10<<4 | v%10) }
+	return []byte{byte(mjd >> 8), byte(mjd), bcd(t.Hour()), bcd(t.Minute()), bcd(t.Second())}
+}
+
+var fixtureTime = time.Date(2024, 3, 10, 12, 34, 56, 0, time.UTC)
+
+func fixtureTDT() []byte {
+	return append([]byte{TableID_TimeDateSection, 0x70, 0x05}, fixtureMJD(fixtureTime)...)
+}
+
+// TOT with local_time_offset_descriptor of two countries
+func fixtureTOT() []byte {
+	// JPN, country_region_id 0, polarity 0, +09:00, no time_of_change, +09:00
+	lto := []byte{'J', 'P', 'N', 0x02, 0x09, 0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0x09, 0x00}
+	// DEU, +01:00 changing to +02:00 at 2024-03-31 01:00:00
+	lto = append(lto, 'D', 'E', 'U', 0x02, 0x01, 0x00)
+	lto = append(lto, fixtureMJD(time.Date(2024, 3, 31, 1, 0, 0, 0, time.UTC))...)
+	lto = append(lto, 0x02, 0x00)
+	tot := append([]byte{TableID_TimeOffsetSection, 0x70, 0x00}, fixtureMJD(fixtureTime)...)
+	tot = append(tot, fixtureLoop(fixtureDescriptor(0x58, lto...))...)
+	length := len(tot) - 3 + 4
+	tot[1], tot[2] = 0x70|byte(length>>8), byte(length)
+	crc := calculateCRC(tot)
+	return append(tot, byte(crc>>24), byte(crc>>16), byte(crc>>8), byte(crc))
+}
+
+// time.ts: TDT and TOT
+func fixtureTimeStream() []byte {
+	b := fixtureSectionPackets(PID_TDT_TOT, 0, fixtureTDT())
+	return append(b, fixtureSectionPackets(PID_TDT_TOT, 1, fixtureTOT())...)
+}
+
+// PTS of the first access units of fixtureMux. it leaves PCRDelay of the muxer above 0
+const fixtureStartPTS = 90000
+
+// fixtureProgram is the program of an AVC and an AAC stream.
+func fixtureProgram() MuxerProgram {
+	return MuxerProgram{
+		ProgramNumber: 1,
+		PMTPID:        fixturePMTPID,
+		Streams: []MuxerStream{
+			{PID: fixtureVideoPID, Type: StreamTypeAVC},
+			{PID: fixtureAudioPID, Type: StreamTypeISO13818_7_AudioWithADTS},
+		},
+	}
+}
+
+// fixtureMux muxes duration of the programs. Video streams are 2Mbit/s of 25 frames/s with an IDR
+// picture every gopSize frames, and audio streams are 128kbit/s of 1024 samples at 48kHz.
+func fixtureMux(t *testing.T, duration time.Duration, gopSize int, programs ...MuxerProgram) (*MPEG2TS, []byte) {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	mx := NewMuxer(buf)
+	type stream struct {
+		MuxerStream
+		count int
+	}
+	streams := []*stream{}
+	for _, prog := range programs {
+		if err := mx.AddProgram(prog); err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range prog.Streams {
+			streams = append(streams, &stream{MuxerStream: s})
+		}
+	}
+	ptsAt := func(s *stream, n int) int64 {
+		if s.Type.IsVideo() {
+			return fixtureStartPTS + int64(n)*PTSClockFrequency/25
+		}
+		return fixtureStartPTS + int64(n)*1024*PTSClockFrequency/48000
+	}
+	end := fixtureStartPTS + durationToPTS(duration)
+	for {
+		// the access unit of the earliest PTS
+		var next *stream
+		for _, s := range streams {
+			if next == nil || ptsAt(s, s.count) < ptsAt(next, next.count) {
+				next = s
+			}
+		}
+		if next == nil || ptsAt(next, next.count) >= end {
+			break
+		}
+		au := AccessUnit{PID: next.PID, PTS: uint64(ptsAt(next, next.count)), HasPTS: true}
+		if next.Type.IsVideo() {
+			au.RandomAccess = next.count%gopSize == 0
+			au.Data = fixturePayload(10000, byte(next.count))
+			// access_unit_delimiter and the NAL unit header of an IDR or non-IDR slice
+			copy(au.Data, []byte{0x00, 0x00, 0x00, 0x01, 0x09, 0xf0, 0x00, 0x00, 0x00, 0x01, 0x41})
+			if au.RandomAccess {
+				au.Data[10] = 0x65
+			}
+		} else {
+			au.Data = fixturePayload(333, byte(next.count))
+		}
+		if err := mx.WriteAccessUnit(au); err != nil {
+			t.Fatal(err)
+		}
+		next.count++
+	}
+
+	m := New(PacketSizeDefault)
+	for b := buf.Bytes(); len(b) > 0; b = b[PacketSizeDefault:] {
+		if err := m.PacketList.AddBytes(b[:PacketSizeDefault], PacketSizeDefault); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return m, buf.Bytes()
+}
+
+// continuityErrors counts continuity_counter which does not follow the
+// previous packet. Packets without payload repeat the counter.
+func continuityErrors(m *MPEG2TS) int {
+	n := 0
+	last := map[PID]byte{}
+	for _, p := range m.PacketList.All() {
+		if p.PID == PID_NullPacket {
+			continue
+		}
+		if cc, ok := last[p.PID]; ok {
+			want := cc
+			if p.AdaptationFieldControl&0x01 != 0 {
+				want = (cc + 1) & 0x0f
+			}
+			if p.ContinuityCheckIndex != want {
+				n++
+			}
+		}
+		last[p.PID] = p.ContinuityCheckIndex
+	}
+	return n
+}
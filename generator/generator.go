@@ -0,0 +1,526 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	mpeg2ts "github.com/misodengaku/go-mpeg2-ts"
+)
+
+var (
+	ErrNoProgram = errors.New("generator has no program")
+	ErrNoBitrate = errors.New("bitrate of the stream must be positive")
+	ErrNoSIRoom  = errors.New("null packets are too few to send SDT and TDT at the mux rate")
+)
+
+const (
+	// PTS of the first access units. it leaves PCRDelay of the muxer above 0
+	startPTS  = 90000
+	frameRate = 25
+	// of the audio streams
+	sampleRate = 48000
+	// sampling_frequency_index of 48kHz. ISO/IEC 14496-3:2019 p.67
+	adtsFrequencyIndex = 3
+	// of the 33bit PTS and the 27MHz PCR
+	ptsWrap = 1 << 33
+	pcrWrap = ptsWrap * 300
+)
+
+// MPEG-2 frame_rate_code. Rec. ITU-T H.262 (02/2012) p.40
+var frameRateCodes = map[float64]byte{24000.0 / 1001: 1, 24: 2, 25: 3, 30000.0 / 1001: 4, 30: 5, 50: 6, 60000.0 / 1001: 7, 60: 8}
+
+// Stream is an elementary stream of dummy access units at a constant bitrate. Video access
+// units start with the headers of their stream type and AAC frames have ADTS headers, so that
+// keyframes and frames are found in them, but they are not decodable.
+type Stream struct {
+	PID  mpeg2ts.PID
+	Type mpeg2ts.StreamType
+	// in bit/s of the elementary stream
+	Bitrate int
+	// access units per second of a video stream. 0 for 25
+	FrameRate float64
+	// access units from a keyframe to the next. 0 for a second
+	GOPSize int
+}
+
+// Program is a program of PAT, PMT and SDT.
+type Program struct {
+	ProgramNumber uint16
+	PMTPID        mpeg2ts.PID
+	// 0 to carry PCR on the first video stream, or the first stream
+	PCRPID mpeg2ts.PID
+	// service_descriptor of SDT
+	ServiceType         uint8
+	ServiceProviderName string
+	ServiceName         string
+	Streams             []Stream
+}
+
+// Faults are the impairments which Generator injects into the packets it writes by
+// mpeg2ts.FaultInjector. Probabilities are per packet, from 0 to 1.
+type Faults struct {
+	// replaces continuity_counter of a packet with payload
+	ContinuityError float64
+	// sets transport_error_indicator
+	TransportError float64
+	// flips a bit of the last section byte in a packet of PAT, PMT or SDT
+	CRCError float64
+	// breaks sync_byte of SyncLossPackets packets in a row. 0 packets for 1
+	SyncLoss        float64
+	SyncLossPackets int
+	// adds PCRJump to PCR every PCRJumpInterval without discontinuity_indicator
+	PCRJump         time.Duration
+	PCRJumpInterval time.Duration
+}
+
+// Generator writes a transport stream of dummy elementary streams with PAT, PMT, SDT and TDT,
+// for testing and load generation.
+type Generator struct {
+	TransportStreamID uint16
+	OriginalNetworkID uint16
+	Programs          []Program
+	// in bit/s. 0 for a variable bitrate stream. SDT and TDT take the place of null packets in
+	// a constant bitrate stream, and Generate fails with ErrNoSIRoom if a table is due
+	// again before the null packets have carried the last one
+	MuxRate     int
+	PCRInterval time.Duration
+	// of PAT and PMT
+	PSIInterval time.Duration
+	// 0 to send no SDT or TDT
+	SDTInterval time.Duration
+	TDTInterval time.Duration
+	// UTC_time of TDT at the first PCR
+	StartTime time.Time
+	// time zone of TDT. UTC for the zero value, JST for mpeg2ts.ARIBSIOptions
+	SIOptions mpeg2ts.SIOptions
+	// writes the packets in the pace of PCR
+	RealTime bool
+	Faults   Faults
+	// of the random faults
+	Seed int64
+
+	faults *mpeg2ts.FaultInjector
+}
+
+func New(programs ...Program) Generator {
+	return Generator{
+		TransportStreamID: 1,
+		OriginalNetworkID: 1,
+		Programs:          programs,
+		PCRInterval:       40 * time.Millisecond,
+		PSIInterval:       100 * time.Millisecond,
+		// maximum intervals. ETSI TS 101 211 V1.13.1 p.36
+		SDTInterval: 2 * time.Second,
+		TDTInterval: 30 * time.Second,
+		StartTime:   time.Now().UTC().Truncate(time.Second),
+	}
+}
+
+// injector returns FaultInjector of the faults. CRC errors are injected into the section PIDs.
+func (f Faults) injector(seed int64, sections []mpeg2ts.PID) mpeg2ts.FaultInjector {
+	fi := mpeg2ts.NewFaultInjector(seed)
+	fi.Faults = mpeg2ts.PacketFaults{
+		ContinuityError: f.ContinuityError,
+		TransportError:  f.TransportError,
+		SyncLoss:        f.SyncLoss,
+		SyncLossPackets: f.SyncLossPackets,
+		PCRJump:         f.PCRJump,
+		PCRJumpInterval: f.PCRJumpInterval,
+	}
+	for _, pid := range sections {
+		pf := fi.Faults
+		pf.CRCError = f.CRCError
+		fi.PIDFaults[pid] = pf
+	}
+	return fi
+}
+
+// FaultEvents returns the faults which the last Generate injected.
+func (g *Generator) FaultEvents() []mpeg2ts.FaultEvent {
+	if g.faults == nil {
+		return nil
+	}
+	return g.faults.Events()
+}
+
+// Generate writes duration of the stream to w, or writes until ctx is done if duration is 0.
+func (g *Generator) Generate(ctx context.Context, w io.Writer, duration time.Duration) error {
+	if len(g.Programs) == 0 {
+		return ErrNoProgram
+	}
+	gw := newGeneratorWriter(ctx, g, w)
+	mx := mpeg2ts.NewMuxer(gw)
+	mx.TransportStreamID = g.TransportStreamID
+	mx.MuxRate = g.MuxRate
+	if g.PCRInterval > 0 {
+		mx.PCRInterval = g.PCRInterval
+	}
+	if g.PSIInterval > 0 {
+		mx.PSIInterval = g.PSIInterval
+	}
+
+	streams := []*generatorStream{}
+	sections := []mpeg2ts.PID{mpeg2ts.PID_PAT, mpeg2ts.PID_SDT}
+	for _, prog := range g.Programs {
+		mp := mpeg2ts.MuxerProgram{ProgramNumber: prog.ProgramNumber, PMTPID: prog.PMTPID, PCRPID: prog.PCRPID}
+		for _, s := range prog.Streams {
+			if s.Bitrate <= 0 {
+				return fmt.Errorf("PID 0x%04x: %w", s.PID, ErrNoBitrate)
+			}
+			mp.Streams = append(mp.Streams, mpeg2ts.MuxerStream{PID: s.PID, Type: s.Type})
+			streams = append(streams, newGeneratorStream(s))
+		}
+		if err := mx.AddProgram(mp); err != nil {
+			return err
+		}
+		sections = append(sections, prog.PMTPID)
+	}
+	fi := g.Faults.injector(g.Seed, sections)
+	g.faults = &fi
+	gw.faults = g.faults
+
+	end := startPTS + durationToPTS(duration)
+	for {
+		// the access unit of the earliest PTS
+		var next *generatorStream
+		for _, s := range streams {
+			if next == nil || s.pts < next.pts {
+				next = s
+			}
+		}
+		if next == nil || (duration > 0 && next.pts >= end) {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := mx.WriteAccessUnit(next.accessUnit()); err != nil {
+			return err
+		}
+	}
+	return mx.Close()
+}
+
+type generatorStream struct {
+	Stream
+	// access units which have been made
+	count int
+	// PTS of the next access unit in 90kHz, extended beyond 33bit
+	pts int64
+}
+
+func newGeneratorStream(s Stream) *generatorStream {
+	if s.FrameRate <= 0 {
+		s.FrameRate = frameRate
+	}
+	if s.GOPSize <= 0 {
+		s.GOPSize = int(s.FrameRate + 0.5)
+	}
+	return &generatorStream{Stream: s, pts: startPTS}
+}
+
+// ptsAt returns PTS of the nth access unit.
+func (s *generatorStream) ptsAt(n int) int64 {
+	if s.Type.IsVideo() {
+		return startPTS + int64(float64(n)*mpeg2ts.PTSClockFrequency/s.FrameRate)
+	}
+	return startPTS + int64(n)*s.samplesPerFrame()*mpeg2ts.PTSClockFrequency/sampleRate
+}
+
+func (s *generatorStream) samplesPerFrame() int64 {
+	switch s.Type {
+	case mpeg2ts.StreamTypeISO13818_7_AudioWithADTS:
+		return 1024
+	case mpeg2ts.StreamTypeATSC_AC3, mpeg2ts.StreamTypeATSC_EAC3:
+		return 1536
+	}
+	return 1152
+}
+
+// accessUnit returns the next access unit. Its size keeps the bitrate from the first one.
+func (s *generatorStream) accessUnit() mpeg2ts.AccessUnit {
+	n := s.count
+	s.count++
+	s.pts = s.ptsAt(s.count)
+	start := int64(s.Bitrate) * (s.ptsAt(n) - startPTS) / mpeg2ts.PTSClockFrequency / 8
+	end := int64(s.Bitrate) * (s.pts - startPTS) / mpeg2ts.PTSClockFrequency / 8
+	keyframe := s.Type.IsVideo() && n%s.GOPSize == 0
+	return mpeg2ts.AccessUnit{
+		PID:          s.PID,
+		PTS:          uint64(s.ptsAt(n) % ptsWrap),
+		HasPTS:       true,
+		RandomAccess: keyframe,
+		Data:         s.payload(n, keyframe, int(end-start)),
+	}
+}
+
+// payload returns the dummy access unit of the size. It is longer if the headers do not fit.
+func (s *generatorStream) payload(n int, keyframe bool, size int) []byte {
+	header := []byte{}
+	switch s.Type {
+	case mpeg2ts.StreamTypeAVC:
+		// access_unit_delimiter and the NAL unit header of an IDR or non-IDR slice
+		header = []byte{0x00, 0x00, 0x00, 0x01, 0x09, 0xf0, 0x00, 0x00, 0x00, 0x01, 0x41}
+		if keyframe {
+			header[10] = 0x65
+		}
+	case mpeg2ts.StreamTypeHEVC:
+		// access_unit_delimiter and the NAL unit header of IDR_W_RADL or TRAIL_R
+		header = []byte{0x00, 0x00, 0x00, 0x01, 0x46, 0x01, 0x50, 0x00, 0x00, 0x00, 0x01, 0x02, 0x01}
+		if keyframe {
+			header[11] = 0x26
+		}
+	case mpeg2ts.StreamTypeISO11172_2_Video, mpeg2ts.StreamTypeISO13818_2_Video:
+		if keyframe {
+			header = append(header, s.mpeg2SequenceHeader()...)
+			// group_of_pictures_header with time_code 0 and closed_gop
+			header = append(header, 0x00, 0x00, 0x01, 0xb8, 0x00, 0x08, 0x00, 0x40)
+		}
+		// picture_header of an I or P picture with vbv_delay 0xffff
+		tr := n % s.GOPSize
+		if keyframe {
+			header = append(header, 0x00, 0x00, 0x01, 0x00, byte(tr>>2), byte(tr<<6)|0x08|0x07, 0xff, 0xf8)
+		} else {
+			// full_pel_forward_vector 0 and forward_f_code 7
+			header = append(header, 0x00, 0x00, 0x01, 0x00, byte(tr>>2), byte(tr<<6)|0x10|0x07, 0xff, 0xfb, 0x80)
+		}
+	case mpeg2ts.StreamTypeISO13818_7_AudioWithADTS:
+		header = adtsHeader(size)
+	}
+	if size < len(header) {
+		size = len(header)
+		if s.Type == mpeg2ts.StreamTypeISO13818_7_AudioWithADTS {
+			header = adtsHeader(size)
+		}
+	}
+	b := make([]byte, size)
+	copy(b, header)
+	for i := len(header); i < len(b); i++ {
+		b[i] = 0xff
+	}
+	return b
+}
+
+// mpeg2SequenceHeader returns a sequence_header of 720x576 4:3 at the bitrate.
+// Rec. ITU-T H.262 (02/2012) pp.37-38
+func (s *generatorStream) mpeg2SequenceHeader() []byte {
+	code, ok := frameRateCodes[s.FrameRate]
+	if !ok {
+		code = frameRateCodes[frameRate]
+	}
+	// in 400bit/s, rounded upwards
+	br := (s.Bitrate + 399) / 400
+	vbv := 112
+	return []byte{
+		0x00, 0x00, 0x01, 0xb3,
+		720 >> 4, 720&0x0f<<4 | 576>>8, 576 & 0xff,
+		// aspect_ratio_information 2 (4:3)
+		0x20 | code,
+		byte(br >> 10), byte(br >> 2), byte(br&0x03)<<6 | 0x20 | byte(vbv>>5),
+		// constrained_parameters_flag, load_intra_quantiser_matrix and load_non_intra_quantiser_matrix 0
+		byte(vbv&0x1f) << 3,
+	}
+}
+
+// adtsHeader returns an ADTS header of AAC LC 48kHz stereo without CRC for a frame of the size.
+// ISO/IEC 14496-3:2019 pp.1166-1168
+func adtsHeader(size int) []byte {
+	profile := byte(1) // AAC LC
+	channels := byte(2)
+	return []byte{
+		0xff, 0xf1,
+		profile<<6 | adtsFrequencyIndex<<2 | channels>>2,
+		channels<<6 | byte(size>>11)&0x03,
+		byte(size >> 3),
+		// adts_buffer_fullness 0x7ff (variable bitrate) and 1 raw_data_block
+		byte(size)<<5 | 0x1f,
+		0xfc,
+	}
+}
+
+// generatorWriter receives the packets of the muxer. It inserts SDT and TDT, injects the
+// faults and paces the packets.
+type generatorWriter struct {
+	ctx    context.Context
+	g      *Generator
+	w      io.Writer
+	faults *mpeg2ts.FaultInjector
+	cc     map[mpeg2ts.PID]byte
+	// packets which have been written
+	count int
+
+	// system time clock of the last PCR in 27MHz, extended beyond 33bit
+	clock     int64
+	lastPCR   int64
+	firstPCR  int64
+	started   bool
+	wallStart time.Time
+	lastSDT   int64
+	lastTDT   int64
+	hasSDT    bool
+	hasTDT    bool
+	queue     [][]byte
+}
+
+func newGeneratorWriter(ctx context.Context, g *Generator, w io.Writer) *generatorWriter {
+	gw := &generatorWriter{ctx: ctx, g: g, w: w}
+	gw.cc = map[mpeg2ts.PID]byte{}
+	return gw
+}
+
+// Write receives the packets of the muxer.
+func (gw *generatorWriter) Write(p []byte) (int, error) {
+	for i := 0; i+mpeg2ts.PacketSizeDefault <= len(p); i += mpeg2ts.PacketSizeDefault {
+		if err := gw.writePacket(p[i : i+mpeg2ts.PacketSizeDefault]); err != nil {
+			return i, err
+		}
+	}
+	return len(p), nil
+}
+
+func (gw *generatorWriter) writePacket(b []byte) error {
+	if pcr, ok := packetPCR(b); ok {
+		if err := gw.observePCR(pcr); err != nil {
+			return err
+		}
+	}
+	pid := mpeg2ts.PID(b[1]&0x1f)<<8 | mpeg2ts.PID(b[2])
+	if gw.g.MuxRate > 0 {
+		// SI in the place of a null packet keeps the bitrate
+		if pid == mpeg2ts.PID_NullPacket && len(gw.queue) > 0 {
+			b = gw.queue[0]
+			gw.queue = gw.queue[1:]
+		}
+	} else {
+		for _, q := range gw.queue {
+			if err := gw.emit(q); err != nil {
+				return err
+			}
+		}
+		gw.queue = gw.queue[:0]
+	}
+	return gw.emit(b)
+}
+
+// observePCR follows the clock and queues SDT and TDT which are due.
+func (gw *generatorWriter) observePCR(pcr int64) error {
+	if !gw.started {
+		gw.started = true
+		gw.clock = pcr
+		gw.firstPCR = pcr
+		gw.wallStart = time.Now()
+	} else {
+		diff := (pcr - gw.lastPCR) % pcrWrap
+		if diff < 0 {
+			diff += pcrWrap
+		}
+		if diff > pcrWrap/2 {
+			// PCR of another program behind this one
+			return nil
+		}
+		gw.clock += diff
+	}
+	gw.lastPCR = pcr
+	g := gw.g
+	elapsed := time.Duration((gw.clock - gw.firstPCR) * 1000 / (mpeg2ts.SystemClockFrequency / 1000000))
+	if g.SDTInterval > 0 && (!gw.hasSDT || gw.clock-gw.lastSDT >= durationToClock(g.SDTInterval)) {
+		if gw.queued(mpeg2ts.PID_SDT) {
+			return ErrNoSIRoom
+		}
+		gw.lastSDT, gw.hasSDT = gw.clock, true
+		sdt := gw.sdt()
+		gw.queue = append(gw.queue, gw.sectionPackets(mpeg2ts.PID_SDT, sdt.Encode())...)
+	}
+	if g.TDTInterval > 0 && (!gw.hasTDT || gw.clock-gw.lastTDT >= durationToClock(g.TDTInterval)) {
+		if gw.queued(mpeg2ts.PID_TDT_TOT) {
+			return ErrNoSIRoom
+		}
+		gw.lastTDT, gw.hasTDT = gw.clock, true
+		tdt := mpeg2ts.TDT{Timestamp: g.StartTime.Add(elapsed)}
+		gw.queue = append(gw.queue, gw.sectionPackets(mpeg2ts.PID_TDT_TOT, tdt.Encode(gw.g.SIOptions))...)
+	}
+	if !g.RealTime {
+		return nil
+	}
+	wait := time.Until(gw.wallStart.Add(elapsed))
+	if wait <= 0 {
+		return nil
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-gw.ctx.Done():
+		return gw.ctx.Err()
+	case <-t.C:
+	}
+	return nil
+}
+
+// queued reports whether packets of the PID are waiting for null packets, which keeps the queue
+// from growing when the mux rate leaves no room for SI.
+func (gw *generatorWriter) queued(pid mpeg2ts.PID) bool {
+	for _, b := range gw.queue {
+		if mpeg2ts.PID(b[1]&0x1f)<<8|mpeg2ts.PID(b[2]) == pid {
+			return true
+		}
+	}
+	return false
+}
+
+func (gw *generatorWriter) sdt() mpeg2ts.SDT {
+	sdt := mpeg2ts.SDT{TableID: mpeg2ts.TableID_ServiceDescriptionSection_ActualDVBTransportStream, TransportStreamID: gw.g.TransportStreamID, CurrentNextIndicator: true, OriginalNetworkID: gw.g.OriginalNetworkID}
+	for _, prog := range gw.g.Programs {
+		sdt.Services = append(sdt.Services, mpeg2ts.SDTService{
+			ServiceID:           prog.ProgramNumber,
+			RunningStatus:       4, // running
+			ServiceType:         prog.ServiceType,
+			ServiceProviderName: prog.ServiceProviderName,
+			ServiceName:         prog.ServiceName,
+		})
+	}
+	return sdt
+}
+
+func (gw *generatorWriter) sectionPackets(pid mpeg2ts.PID, section []byte) [][]byte {
+	return mpeg2ts.PacketizeSection(pid, section, func() byte {
+		cc := gw.cc[pid]
+		gw.cc[pid] = (cc + 1) & 0x0f
+		return cc
+	})
+}
+
+// emit injects the faults into a copy of the packet and writes it.
+func (gw *generatorWriter) emit(b []byte) error {
+	p, err := mpeg2ts.ParsePacket(b, gw.count)
+	if err != nil {
+		return err
+	}
+	gw.count++
+	for _, fp := range gw.faults.EnqueueTSPacket(p) {
+		if _, err := gw.w.Write(fp.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// packetPCR returns PCR of a 188 bytes packet in 27MHz.
+func packetPCR(b []byte) (int64, bool) {
+	if b[3]&0x20 == 0 || b[4] == 0 || b[5]&0x10 == 0 {
+		return 0, false
+	}
+	base := int64(b[6])<<25 | int64(b[7])<<17 | int64(b[8])<<9 | int64(b[9])<<1 | int64(b[10]>>7)
+	ext := int64(b[10]&0x01)<<8 | int64(b[11])
+	return base*300 + ext, true
+}
+
+func durationToPTS(d time.Duration) int64 {
+	return int64(d / time.Microsecond * mpeg2ts.PTSClockFrequency / 1000000)
+}
+
+func durationToClock(d time.Duration) int64 {
+	return int64(d/time.Microsecond) * (mpeg2ts.SystemClockFrequency / 1000000)
+}
@@ -0,0 +1,250 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	mpeg2ts "github.com/misodengaku/go-mpeg2-ts"
+)
+
+const (
+	testPMTPID   mpeg2ts.PID = 0x0100
+	testVideoPID mpeg2ts.PID = 0x0101
+	testAudioPID mpeg2ts.PID = 0x0102
+)
+
+var testTime = time.Date(2024, 3, 10, 12, 34, 56, 0, time.UTC)
+
+func testGenerator() Generator {
+	g := New(Program{
+		ProgramNumber:       1,
+		PMTPID:              testPMTPID,
+		ServiceType:         mpeg2ts.ServiceType_DigitalTelevision,
+		ServiceProviderName: "provider",
+		ServiceName:         "service",
+		Streams: []Stream{
+			{PID: testVideoPID, Type: mpeg2ts.StreamTypeAVC, Bitrate: 2000000},
+			{PID: testAudioPID, Type: mpeg2ts.StreamTypeISO13818_7_AudioWithADTS, Bitrate: 128000},
+		},
+	})
+	g.StartTime = testTime
+	g.TDTInterval = time.Second
+	return g
+}
+
+func generate(t *testing.T, g Generator, duration time.Duration) (*mpeg2ts.MPEG2TS, []byte) {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	if err := g.Generate(context.Background(), buf, duration); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	path := filepath.Join(t.TempDir(), "generated.ts")
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := mpeg2ts.LoadStandardTS(path)
+	if err != nil {
+		return nil, b
+	}
+	return m, b
+}
+
+// clockValue returns PCR in 27MHz.
+func clockValue(pcr mpeg2ts.ProgramClockReference) int64 {
+	return int64(pcr.Base*300 + uint64(pcr.Extension))
+}
+
+// continuityErrors counts continuity_counter which does not follow the
+// previous packet. Packets without payload repeat the counter.
+func continuityErrors(m *mpeg2ts.MPEG2TS) int {
+	n := 0
+	last := map[mpeg2ts.PID]byte{}
+	for _, p := range m.PacketList.All() {
+		if p.PID == mpeg2ts.PID_NullPacket {
+			continue
+		}
+		if cc, ok := last[p.PID]; ok {
+			want := cc
+			if p.AdaptationFieldControl&0x01 != 0 {
+				want = (cc + 1) & 0x0f
+			}
+			if p.ContinuityCheckIndex != want {
+				n++
+			}
+		}
+		last[p.PID] = p.ContinuityCheckIndex
+	}
+	return n
+}
+
+func TestGenerator(t *testing.T) {
+	for _, muxRate := range []int{0, 3000000} {
+		g := testGenerator()
+		g.MuxRate = muxRate
+		m, _ := generate(t, g, 3*time.Second)
+		if m == nil {
+			t.Fatal("generated stream is not loaded")
+		}
+		if n := continuityErrors(m); n != 0 {
+			t.Errorf("MuxRate %d: %d continuity_counter errors", muxRate, n)
+		}
+
+		sdts := m.ReadSections(mpeg2ts.PID_SDT)
+		if len(sdts) < 2 {
+			t.Fatalf("MuxRate %d: %d SDT sections", muxRate, len(sdts))
+		}
+		sdt, err := sdts[0].ParseSDT()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sdt.Services) != 1 || sdt.Services[0].ServiceID != 1 || sdt.Services[0].ServiceName != "service" || sdt.Services[0].ServiceProviderName != "provider" {
+			t.Errorf("MuxRate %d: unexpected services %+v", muxRate, sdt.Services)
+		}
+
+		tdts := []time.Time{}
+		for _, p := range m.FilterByPIDs(mpeg2ts.PID_TDT_TOT).PacketList.All() {
+			tdt, err := p.ParseTDT(false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tdts = append(tdts, tdt.Timestamp)
+		}
+		if len(tdts) < 3 || !tdts[0].Equal(testTime) || tdts[2].Sub(tdts[0]) != 2*time.Second {
+			t.Errorf("MuxRate %d: TDT %v", muxRate, tdts)
+		}
+
+		// PTS increases and the video keyframes are found
+		for _, pid := range []mpeg2ts.PID{testVideoPID, testAudioPID} {
+			last, count, keyframes := uint64(0), 0, 0
+			for _, p := range m.FilterByPIDs(pid).PacketList.All() {
+				if !p.PayloadUnitStartIndicator {
+					continue
+				}
+				ts, err := p.ReadPESTimestamps()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if count > 0 && ts.PTS <= last {
+					t.Errorf("MuxRate %d mpeg2ts.PID 0x%04x: PTS %d after %d", muxRate, pid, ts.PTS, last)
+				}
+				last = ts.PTS
+				count++
+				if p.IsRandomAccessPoint(mpeg2ts.StreamTypeAVC) {
+					keyframes++
+				}
+			}
+			if pid == testVideoPID && (count != 75 || keyframes != 3) {
+				t.Errorf("MuxRate %d: %d video frames and %d keyframes", muxRate, count, keyframes)
+			}
+		}
+	}
+}
+
+func TestGeneratorConstantBitrate(t *testing.T) {
+	g := testGenerator()
+	g.MuxRate = 3000000
+	m, _ := generate(t, g, 2*time.Second)
+	pcrs := m.FilterByPIDs(testVideoPID).PacketList.All()
+	first, last := mpeg2ts.Packet{}, mpeg2ts.Packet{}
+	for _, p := range pcrs {
+		if !p.HasAdaptationField() || !p.AdaptationField.PCRFlag {
+			continue
+		}
+		if first.Data == nil {
+			first = p
+		}
+		last = p
+	}
+	bits := int64(last.Index-first.Index) * mpeg2ts.PacketSizeDefault * 8
+	clock := clockValue(last.AdaptationField.ProgramClockReference) - clockValue(first.AdaptationField.ProgramClockReference)
+	if rate := bits * mpeg2ts.SystemClockFrequency / clock; rate < 2999000 || rate > 3001000 {
+		t.Errorf("bitrate is %d", rate)
+	}
+}
+
+func TestGeneratorNoSIRoom(t *testing.T) {
+	// the elementary streams take the whole mux rate
+	g := testGenerator()
+	g.MuxRate = 1950000
+	g.TDTInterval = 100 * time.Millisecond
+	err := g.Generate(context.Background(), io.Discard, 3*time.Second)
+	if !errors.Is(err, ErrNoSIRoom) {
+		t.Errorf("error %v, want %v", err, ErrNoSIRoom)
+	}
+}
+
+func TestGeneratorFaults(t *testing.T) {
+	g := testGenerator()
+	g.Faults = Faults{ContinuityError: 0.01, TransportError: 0.01, CRCError: 0.5, PCRJump: time.Second, PCRJumpInterval: time.Second}
+	g.Seed = 1
+	m, b := generate(t, g, 3*time.Second)
+	if m == nil {
+		t.Fatal("generated stream is not loaded")
+	}
+	if continuityErrors(m) == 0 {
+		t.Error("no continuity_counter error")
+	}
+	tei, crc := 0, 0
+	for _, p := range m.PacketList.All() {
+		if p.TransportErrorIndicator {
+			tei++
+		}
+		if p.PID == mpeg2ts.PID_PAT {
+			if _, err := p.ParsePAT(); err != nil {
+				crc++
+			}
+		}
+	}
+	if tei == 0 || crc == 0 {
+		t.Errorf("%d packets with transport_error_indicator and %d PAT with CRC error", tei, crc)
+	}
+
+	// PCR jumps ahead of PTS
+	var pcr, pts int64
+	for _, p := range m.FilterByPIDs(testVideoPID).PacketList.All() {
+		if p.HasAdaptationField() && p.AdaptationField.PCRFlag {
+			pcr = clockValue(p.AdaptationField.ProgramClockReference) / 300
+		}
+		if ts, err := p.ReadPESTimestamps(); err == nil {
+			pts = int64(ts.PTS)
+		}
+	}
+	if pcr < pts {
+		t.Errorf("PCR %d does not jump ahead of PTS %d", pcr, pts)
+	}
+
+	// the same seed injects the same faults
+	again := &bytes.Buffer{}
+	if err := g.Generate(context.Background(), again, 3*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, again.Bytes()) {
+		t.Error("faults are not reproduced by the seed")
+	}
+
+	// every fault is logged by FaultInjector
+	types := map[mpeg2ts.FaultType]int{}
+	for _, e := range g.FaultEvents() {
+		types[e.Type]++
+		if e.Type == mpeg2ts.FaultCRCError && e.PID != mpeg2ts.PID_PAT && e.PID != mpeg2ts.PID_SDT && e.PID != testPMTPID {
+			t.Errorf("CRC error on mpeg2ts.PID 0x%04x", e.PID)
+		}
+	}
+	for _, ft := range []mpeg2ts.FaultType{mpeg2ts.FaultContinuityError, mpeg2ts.FaultTransportError, mpeg2ts.FaultCRCError, mpeg2ts.FaultPCRJump} {
+		if types[ft] == 0 {
+			t.Errorf("no %s event", ft)
+		}
+	}
+
+	g.Faults = Faults{SyncLoss: 0.01, SyncLossPackets: 3}
+	if m, _ := generate(t, g, time.Second); m != nil {
+		t.Error("stream without sync_byte is loaded")
+	}
+}
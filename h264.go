@@ -0,0 +1,499 @@
+package mpeg2ts
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrAVCNoSPS = errors.New("no SPS is found")
+
+type AVCNALUnitType byte
+
+// Rec. ITU-T H.264 (08/2021) p.65
+const (
+	AVCNALUnitType_Unspecified             = AVCNALUnitType(0)
+	AVCNALUnitType_NonIDRSlice             = AVCNALUnitType(1)
+	AVCNALUnitType_SliceDataPartitionA     = AVCNALUnitType(2)
+	AVCNALUnitType_SliceDataPartitionB     = AVCNALUnitType(3)
+	AVCNALUnitType_SliceDataPartitionC     = AVCNALUnitType(4)
+	AVCNALUnitType_IDRSlice                = AVCNALUnitType(5)
+	AVCNALUnitType_SEI                     = AVCNALUnitType(6)
+	AVCNALUnitType_SPS                     = AVCNALUnitType(7)
+	AVCNALUnitType_PPS                     = AVCNALUnitType(8)
+	AVCNALUnitType_AccessUnitDelimiter     = AVCNALUnitType(9)
+	AVCNALUnitType_EndOfSequence           = AVCNALUnitType(10)
+	AVCNALUnitType_EndOfStream             = AVCNALUnitType(11)
+	AVCNALUnitType_FillerData              = AVCNALUnitType(12)
+	AVCNALUnitType_SPSExtension            = AVCNALUnitType(13)
+	AVCNALUnitType_PrefixNALUnit           = AVCNALUnitType(14)
+	AVCNALUnitType_SubsetSPS               = AVCNALUnitType(15)
+	AVCNALUnitType_DPS                     = AVCNALUnitType(16)
+	AVCNALUnitType_AuxiliarySlice          = AVCNALUnitType(19)
+	AVCNALUnitType_SliceExtension          = AVCNALUnitType(20)
+	AVCNALUnitType_SliceExtensionDepthView = AVCNALUnitType(21)
+)
+
+func (t AVCNALUnitType) String() string {
+	switch t {
+	case AVCNALUnitType_NonIDRSlice:
+		return "Coded slice of a non-IDR picture"
+	case AVCNALUnitType_SliceDataPartitionA:
+		return "Coded slice data partition A"
+	case AVCNALUnitType_SliceDataPartitionB:
+		return "Coded slice data partition B"
+	case AVCNALUnitType_SliceDataPartitionC:
+		return "Coded slice data partition C"
+	case AVCNALUnitType_IDRSlice:
+		return "Coded slice of an IDR picture"
+	case AVCNALUnitType_SEI:
+		return "Supplemental enhancement information"
+	case AVCNALUnitType_SPS:
+		return "Sequence parameter set"
+	case AVCNALUnitType_PPS:
+		return "Picture parameter set"
+	case AVCNALUnitType_AccessUnitDelimiter:
+		return "Access unit delimiter"
+	case AVCNALUnitType_EndOfSequence:
+		return "End of sequence"
+	case AVCNALUnitType_EndOfStream:
+		return "End of stream"
+	case AVCNALUnitType_FillerData:
+		return "Filler data"
+	case AVCNALUnitType_SPSExtension:
+		return "Sequence parameter set extension"
+	case AVCNALUnitType_PrefixNALUnit:
+		return "Prefix NAL unit"
+	case AVCNALUnitType_SubsetSPS:
+		return "Subset sequence parameter set"
+	case AVCNALUnitType_DPS:
+		return "Depth parameter set"
+	case AVCNALUnitType_AuxiliarySlice:
+		return "Coded slice of an auxiliary coded picture"
+	case AVCNALUnitType_SliceExtension:
+		return "Coded slice extension"
+	case AVCNALUnitType_SliceExtensionDepthView:
+		return "Coded slice extension for a depth view component"
+	}
+	return fmt.Sprintf("Unknown(%d)", byte(t))
+}
+
+// IsVCL reports whether the NAL unit carries a coded slice of the primary picture.
+func (t AVCNALUnitType) IsVCL() bool {
+	return t >= AVCNALUnitType_NonIDRSlice && t <= AVCNALUnitType_IDRSlice
+}
+
+type AVCSliceType byte
+
+// Rec. ITU-T H.264 (08/2021) p.104
+const (
+	AVCSliceType_P  = AVCSliceType(0)
+	AVCSliceType_B  = AVCSliceType(1)
+	AVCSliceType_I  = AVCSliceType(2)
+	AVCSliceType_SP = AVCSliceType(3)
+	AVCSliceType_SI = AVCSliceType(4)
+)
+
+func (t AVCSliceType) String() string {
+	switch t {
+	case AVCSliceType_P:
+		return "P"
+	case AVCSliceType_B:
+		return "B"
+	case AVCSliceType_I:
+		return "I"
+	case AVCSliceType_SP:
+		return "SP"
+	case AVCSliceType_SI:
+		return "SI"
+	}
+	return fmt.Sprintf("Unknown(%d)", byte(t))
+}
+
+// NAL unit of H.264
+// Rec. ITU-T H.264 (08/2021) pp.43-44
+type AVCNALUnit struct {
+	ForbiddenZeroBit bool           // 1
+	NALRefIDC        byte           // 2
+	Type             AVCNALUnitType // 5
+	// NAL unit bytes including the header, with emulation prevention bytes
+	Data []byte
+}
+
+// RBSP returns the payload without the header and emulation prevention bytes.
+func (n *AVCNALUnit) RBSP() []byte {
+	if len(n.Data) < 1 {
+		return nil
+	}
+	return unescapeRBSP(n.Data[1:])
+}
+
+// Sequence parameter set
+// Rec. ITU-T H.264 (08/2021) pp.44-47, 66-71
+type AVCSPS struct {
+	ProfileIDC               byte // 8
+	ConstraintSetFlags       byte // 6
+	LevelIDC                 byte // 8
+	SeqParameterSetID        uint64
+	ChromaFormatIDC          uint64
+	SeparateColourPlaneFlag  bool
+	BitDepthLuma             uint64
+	BitDepthChroma           uint64
+	Log2MaxFrameNum          uint64
+	PicOrderCntType          uint64
+	MaxNumRefFrames          uint64
+	PicWidthInMbs            uint64
+	PicHeightInMapUnits      uint64
+	FrameMbsOnlyFlag         bool
+	FrameCropLeftOffset      uint64
+	FrameCropRightOffset     uint64
+	FrameCropTopOffset       uint64
+	FrameCropBottomOffset    uint64
+	VUIParametersPresentFlag bool
+	AspectRatioIDC           byte // 8
+	SARWidth                 uint16
+	SARHeight                uint16
+	VideoFullRangeFlag       bool
+	ColourPrimaries          byte // 8
+	TransferCharacteristics  byte // 8
+	MatrixCoefficients       byte // 8
+	TimingInfoPresentFlag    bool
+	NumUnitsInTick           uint32 // 32
+	TimeScale                uint32 // 32
+	FixedFrameRateFlag       bool
+
+	// in luma samples after cropping
+	Width  int
+	Height int
+}
+
+// Table E-1 sample aspect ratio indicator
+var avcSampleAspectRatios = [][2]uint16{
+	{0, 0}, {1, 1}, {12, 11}, {10, 11}, {16, 11}, {40, 33}, {24, 11}, {20, 11}, {32, 11},
+	{80, 33}, {18, 11}, {15, 11}, {64, 33}, {160, 99}, {4, 3}, {3, 2}, {2, 1},
+}
+
+// FrameRate returns frames per second from the VUI timing information.
+func (sps *AVCSPS) FrameRate() (float64, bool) {
+	if !sps.TimingInfoPresentFlag || sps.NumUnitsInTick == 0 {
+		return 0, false
+	}
+	return float64(sps.TimeScale) / float64(2*sps.NumUnitsInTick), true
+}
+
+// ProfileName returns the name of profile_idc. Rec. ITU-T H.264 (08/2021) Annex A
+func (sps *AVCSPS) ProfileName() string {
+	switch sps.ProfileIDC {
+	case 66:
+		if sps.ConstraintSetFlags&0x10 != 0 {
+			return "Constrained Baseline"
+		}
+		return "Baseline"
+	case 77:
+		return "Main"
+	case 88:
+		return "Extended"
+	case 100:
+		return "High"
+	case 110:
+		return "High 10"
+	case 122:
+		return "High 4:2:2"
+	case 244:
+		return "High 4:4:4 Predictive"
+	case 44:
+		return "CAVLC 4:4:4 Intra"
+	}
+	return fmt.Sprintf("Unknown(%d)", sps.ProfileIDC)
+}
+
+// Level returns level_idc as a number like 4.1. level_idc 11 with constraint_set3_flag is 1b.
+func (sps *AVCSPS) Level() float64 {
+	return float64(sps.LevelIDC) / 10
+}
+
+// AVCAccessUnit is the NAL units of one primary coded picture.
+type AVCAccessUnit struct {
+	NALUnits []AVCNALUnit
+	// IDR picture
+	IDR bool
+	// IDR picture or every slice is I or SI
+	Keyframe   bool
+	SliceTypes []AVCSliceType
+	// SPS in the access unit
+	SPS    AVCSPS
+	HasSPS bool
+}
+
+// ParseAVCNALUnits splits an Annex B byte stream into NAL units.
+func ParseAVCNALUnits(es []byte) []AVCNALUnit {
+	units := []AVCNALUnit{}
+	for _, b := range splitAnnexB(es) {
+		units = append(units, AVCNALUnit{
+			ForbiddenZeroBit: (b[0]>>7)&0x01 == 1,
+			NALRefIDC:        (b[0] >> 5) & 0x03,
+			Type:             AVCNALUnitType(b[0] & 0x1f),
+			Data:             b,
+		})
+	}
+	return units
+}
+
+// ParseAVCAccessUnits splits an Annex B byte stream into access units.
+// Rec. ITU-T H.264 (08/2021) 7.4.1.2.3
+func ParseAVCAccessUnits(es []byte) []AVCAccessUnit {
+	aus := []AVCAccessUnit{}
+	cur := AVCAccessUnit{}
+	hasVCL := false
+	flush := func() {
+		if len(cur.NALUnits) > 0 {
+			cur.Keyframe = cur.IDR || (len(cur.SliceTypes) > 0 && allIntraSlices(cur.SliceTypes))
+			aus = append(aus, cur)
+		}
+		cur = AVCAccessUnit{}
+		hasVCL = false
+	}
+	for _, n := range ParseAVCNALUnits(es) {
+		switch {
+		case n.Type == AVCNALUnitType_AccessUnitDelimiter, n.Type == AVCNALUnitType_SPS, n.Type == AVCNALUnitType_PPS,
+			n.Type == AVCNALUnitType_SEI, n.Type >= AVCNALUnitType_PrefixNALUnit && n.Type <= 18:
+			if hasVCL {
+				flush()
+			}
+		case n.Type.IsVCL():
+			firstMb, _, err := n.parseSliceType()
+			if hasVCL && err == nil && firstMb == 0 {
+				flush()
+			}
+		}
+
+		cur.NALUnits = append(cur.NALUnits, n)
+		switch {
+		case n.Type == AVCNALUnitType_SPS:
+			if sps, err := n.ParseSPS(); err == nil {
+				cur.SPS = sps
+				cur.HasSPS = true
+			}
+		case n.Type.IsVCL():
+			hasVCL = true
+			if n.Type == AVCNALUnitType_IDRSlice {
+				cur.IDR = true
+			}
+			// data partitions B and C do not have slice_type
+			if n.Type == AVCNALUnitType_SliceDataPartitionB || n.Type == AVCNALUnitType_SliceDataPartitionC {
+				continue
+			}
+			if _, st, err := n.parseSliceType(); err == nil {
+				cur.SliceTypes = append(cur.SliceTypes, st)
+			}
+		}
+	}
+	flush()
+	return aus
+}
+
+// ParseAVCAccessUnit returns the access unit information of the PES packet.
+// NAL units of every access unit in the PES packet are merged.
+func (pes *PES) ParseAVCAccessUnit() (AVCAccessUnit, error) {
+	aus := ParseAVCAccessUnits(pes.ElementaryStream)
+	if len(aus) == 0 {
+		return AVCAccessUnit{}, fmt.Errorf("no NAL unit is found")
+	}
+	au := aus[0]
+	for _, a := range aus[1:] {
+		au.NALUnits = append(au.NALUnits, a.NALUnits...)
+		au.SliceTypes = append(au.SliceTypes, a.SliceTypes...)
+		au.IDR = au.IDR || a.IDR
+		au.Keyframe = au.Keyframe || a.Keyframe
+		if a.HasSPS && !au.HasSPS {
+			au.SPS, au.HasSPS = a.SPS, true
+		}
+	}
+	return au, nil
+}
+
+// FindAVCSPS returns the first SPS in the byte stream.
+func FindAVCSPS(es []byte) (AVCSPS, error) {
+	for _, n := range ParseAVCNALUnits(es) {
+		if n.Type == AVCNALUnitType_SPS {
+			return n.ParseSPS()
+		}
+	}
+	return AVCSPS{}, ErrAVCNoSPS
+}
+
+func allIntraSlices(sts []AVCSliceType) bool {
+	for _, st := range sts {
+		if st != AVCSliceType_I && st != AVCSliceType_SI {
+			return false
+		}
+	}
+	return true
+}
+
+// parseSliceType reads first_mb_in_slice and slice_type of the slice header.
+// Rec. ITU-T H.264 (08/2021) p.51
+func (n *AVCNALUnit) parseSliceType() (uint64, AVCSliceType, error) {
+	rbsp := n.RBSP()
+	if len(rbsp) > 8 {
+		rbsp = rbsp[:8]
+	}
+	br := newBitReader(rbsp)
+	firstMb := br.ue()
+	sliceType := br.ue()
+	if br.err != nil {
+		return 0, 0, br.err
+	}
+	return firstMb, AVCSliceType(sliceType % 5), nil
+}
+
+// ParseSPS decodes seq_parameter_set_data and the VUI up to timing_info.
+func (n *AVCNALUnit) ParseSPS() (AVCSPS, error) {
+	if n.Type != AVCNALUnitType_SPS {
+		return AVCSPS{}, fmt.Errorf("invalid NAL unit type. expected: %d, actual: %d", AVCNALUnitType_SPS, n.Type)
+	}
+	br := newBitReader(n.RBSP())
+	sps := AVCSPS{}
+	sps.ProfileIDC = byte(br.u(8))
+	sps.ConstraintSetFlags = byte(br.u(8)) >> 2
+	sps.LevelIDC = byte(br.u(8))
+	sps.SeqParameterSetID = br.ue()
+	sps.ChromaFormatIDC = 1
+	sps.BitDepthLuma = 8
+	sps.BitDepthChroma = 8
+	switch sps.ProfileIDC {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		sps.ChromaFormatIDC = br.ue()
+		if sps.ChromaFormatIDC == 3 {
+			sps.SeparateColourPlaneFlag = br.flag()
+		}
+		sps.BitDepthLuma = br.ue() + 8
+		sps.BitDepthChroma = br.ue() + 8
+		br.skip(1)     // qpprime_y_zero_transform_bypass_flag
+		if br.flag() { // seq_scaling_matrix_present_flag
+			count := 8
+			if sps.ChromaFormatIDC == 3 {
+				count = 12
+			}
+			for i := 0; i < count; i++ {
+				if !br.flag() {
+					continue
+				}
+				size := 16
+				if i >= 6 {
+					size = 64
+				}
+				skipScalingList(br, size)
+			}
+		}
+	}
+	sps.Log2MaxFrameNum = br.ue() + 4
+	sps.PicOrderCntType = br.ue()
+	switch sps.PicOrderCntType {
+	case 0:
+		br.ue() // log2_max_pic_order_cnt_lsb_minus4
+	case 1:
+		br.skip(1) // delta_pic_order_always_zero_flag
+		br.se()    // offset_for_non_ref_pic
+		br.se()    // offset_for_top_to_bottom_field
+		cycle := br.ue()
+		for i := uint64(0); i < cycle && br.err == nil; i++ {
+			br.se() // offset_for_ref_frame
+		}
+	}
+	sps.MaxNumRefFrames = br.ue()
+	br.skip(1) // gaps_in_frame_num_value_allowed_flag
+	sps.PicWidthInMbs = br.ue() + 1
+	sps.PicHeightInMapUnits = br.ue() + 1
+	sps.FrameMbsOnlyFlag = br.flag()
+	if !sps.FrameMbsOnlyFlag {
+		br.skip(1) // mb_adaptive_frame_field_flag
+	}
+	br.skip(1)     // direct_8x8_inference_flag
+	if br.flag() { // frame_cropping_flag
+		sps.FrameCropLeftOffset = br.ue()
+		sps.FrameCropRightOffset = br.ue()
+		sps.FrameCropTopOffset = br.ue()
+		sps.FrameCropBottomOffset = br.ue()
+	}
+	sps.VUIParametersPresentFlag = br.flag()
+	if br.err != nil {
+		return AVCSPS{}, br.err
+	}
+	if sps.VUIParametersPresentFlag {
+		sps.parseVUI(br)
+	}
+
+	// Rec. ITU-T H.264 (08/2021) pp.77-78, (7-19) and (7-20)
+	chromaArrayType := sps.ChromaFormatIDC
+	if sps.SeparateColourPlaneFlag {
+		chromaArrayType = 0
+	}
+	frameHeightFactor := uint64(2)
+	if sps.FrameMbsOnlyFlag {
+		frameHeightFactor = 1
+	}
+	cropUnitX, cropUnitY := uint64(1), frameHeightFactor
+	switch chromaArrayType {
+	case 1:
+		cropUnitX, cropUnitY = 2, 2*frameHeightFactor
+	case 2:
+		cropUnitX, cropUnitY = 2, frameHeightFactor
+	}
+	sps.Width = int(sps.PicWidthInMbs*16 - cropUnitX*(sps.FrameCropLeftOffset+sps.FrameCropRightOffset))
+	sps.Height = int(frameHeightFactor*sps.PicHeightInMapUnits*16 - cropUnitY*(sps.FrameCropTopOffset+sps.FrameCropBottomOffset))
+	return sps, nil
+}
+
+// parseVUI reads vui_parameters up to timing_info. A truncated VUI leaves the rest zero.
+// Rec. ITU-T H.264 (08/2021) p.432
+func (sps *AVCSPS) parseVUI(br *bitReader) {
+	if br.flag() { // aspect_ratio_info_present_flag
+		sps.AspectRatioIDC = byte(br.u(8))
+		if sps.AspectRatioIDC == 255 { // Extended_SAR
+			sps.SARWidth = uint16(br.u(16))
+			sps.SARHeight = uint16(br.u(16))
+		} else if int(sps.AspectRatioIDC) < len(avcSampleAspectRatios) {
+			sps.SARWidth = avcSampleAspectRatios[sps.AspectRatioIDC][0]
+			sps.SARHeight = avcSampleAspectRatios[sps.AspectRatioIDC][1]
+		}
+	}
+	if br.flag() { // overscan_info_present_flag
+		br.skip(1) // overscan_appropriate_flag
+	}
+	if br.flag() { // video_signal_type_present_flag
+		br.skip(3) // video_format
+		sps.VideoFullRangeFlag = br.flag()
+		if br.flag() { // colour_description_present_flag
+			sps.ColourPrimaries = byte(br.u(8))
+			sps.TransferCharacteristics = byte(br.u(8))
+			sps.MatrixCoefficients = byte(br.u(8))
+		}
+	}
+	if br.flag() { // chroma_loc_info_present_flag
+		br.ue() // chroma_sample_loc_type_top_field
+		br.ue() // chroma_sample_loc_type_bottom_field
+	}
+	sps.TimingInfoPresentFlag = br.flag()
+	if sps.TimingInfoPresentFlag {
+		sps.NumUnitsInTick = uint32(br.u(32))
+		sps.TimeScale = uint32(br.u(32))
+		sps.FixedFrameRateFlag = br.flag()
+	}
+	if br.err != nil {
+		sps.TimingInfoPresentFlag = false
+	}
+}
+
+// Rec. ITU-T H.264 (08/2021) p.46
+func skipScalingList(br *bitReader, size int) {
+	lastScale, nextScale := int64(8), int64(8)
+	for j := 0; j < size && br.err == nil; j++ {
+		if nextScale != 0 {
+			delta := br.se()
+			nextScale = (lastScale + delta + 256) % 256
+		}
+		if nextScale != 0 {
+			lastScale = nextScale
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package mpeg2ts
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrHEVCNoSPS = errors.New("no SPS is found")
+
+type HEVCNALUnitType byte
+
+// Rec. ITU-T H.265 (08/2021) pp.69-70
+const (
+	HEVCNALUnitType_TrailN        = HEVCNALUnitType(0)
+	HEVCNALUnitType_TrailR        = HEVCNALUnitType(1)
+	HEVCNALUnitType_TSAN          = HEVCNALUnitType(2)
+	HEVCNALUnitType_TSAR          = HEVCNALUnitType(3)
+	HEVCNALUnitType_STSAN         = HEVCNALUnitType(4)
+	HEVCNALUnitType_STSAR         = HEVCNALUnitType(5)
+	HEVCNALUnitType_RADLN         = HEVCNALUnitType(6)
+	HEVCNALUnitType_RADLR         = HEVCNALUnitType(7)
+	HEVCNALUnitType_RASLN         = HEVCNALUnitType(8)
+	HEVCNALUnitType_RASLR         = HEVCNALUnitType(9)
+	HEVCNALUnitType_BLAWLP        = HEVCNALUnitType(16)
+	HEVCNALUnitType_BLAWRADL      = HEVCNALUnitType(17)
+	HEVCNALUnitType_BLANLP        = HEVCNALUnitType(18)
+	HEVCNALUnitType_IDRWRADL      = HEVCNALUnitType(19)
+	HEVCNALUnitType_IDRNLP        = HEVCNALUnitType(20)
+	HEVCNALUnitType_CRA           = HEVCNALUnitType(21)
+	HEVCNALUnitType_VPS           = HEVCNALUnitType(32)
+	HEVCNALUnitType_SPS           = HEVCNALUnitType(33)
+	HEVCNALUnitType_PPS           = HEVCNALUnitType(34)
+	HEVCNALUnitType_AUD           = HEVCNALUnitType(35)
+	HEVCNALUnitType_EOS           = HEVCNALUnitType(36)
+	HEVCNALUnitType_EOB           = HEVCNALUnitType(37)
+	HEVCNALUnitType_FD            = HEVCNALUnitType(38)
+	HEVCNALUnitType_PrefixSEI     = HEVCNALUnitType(39)
+	HEVCNALUnitType_SuffixSEI     = HEVCNALUnitType(40)
+	hevcNALUnitType_ReservedIRAP  = HEVCNALUnitType(23)
+	hevcNALUnitType_ReservedVCL31 = HEVCNALUnitType(31)
+)
+
+func (t HEVCNALUnitType) String() string {
+	switch t {
+	case HEVCNALUnitType_TrailN, HEVCNALUnitType_TrailR:
+		return "TRAIL"
+	case HEVCNALUnitType_TSAN, HEVCNALUnitType_TSAR:
+		return "TSA"
+	case HEVCNALUnitType_STSAN, HEVCNALUnitType_STSAR:
+		return "STSA"
+	case HEVCNALUnitType_RADLN, HEVCNALUnitType_RADLR:
+		return "RADL"
+	case HEVCNALUnitType_RASLN, HEVCNALUnitType_RASLR:
+		return "RASL"
+	case HEVCNALUnitType_BLAWLP, HEVCNALUnitType_BLAWRADL, HEVCNALUnitType_BLANLP:
+		return "BLA"
+	case HEVCNALUnitType_IDRWRADL, HEVCNALUnitType_IDRNLP:
+		return "IDR"
+	case HEVCNALUnitType_CRA:
+		return "CRA"
+	case HEVCNALUnitType_VPS:
+		return "Video parameter set"
+	case HEVCNALUnitType_SPS:
+		return "Sequence parameter set"
+	case HEVCNALUnitType_PPS:
+		return "Picture parameter set"
+	case HEVCNALUnitType_AUD:
+		return "Access unit delimiter"
+	case HEVCNALUnitType_EOS:
+		return "End of sequence"
+	case HEVCNALUnitType_EOB:
+		return "End of bitstream"
+	case HEVCNALUnitType_FD:
+		return "Filler data"
+	case HEVCNALUnitType_PrefixSEI:
+		return "Prefix SEI"
+	case HEVCNALUnitType_SuffixSEI:
+		return "Suffix SEI"
+	}
+	return fmt.Sprintf("Unknown(%d)", byte(t))
+}
+
+func (t HEVCNALUnitType) IsVCL() bool {
+	return t <= hevcNALUnitType_ReservedVCL31
+}
+
+// IsIRAP reports whether the NAL unit is a slice of an intra random access point picture.
+func (t HEVCNALUnitType) IsIRAP() bool {
+	return t >= HEVCNALUnitType_BLAWLP && t <= hevcNALUnitType_ReservedIRAP
+}
+
+func (t HEVCNALUnitType) IsIDR() bool {
+	return t == HEVCNALUnitType_IDRWRADL || t == HEVCNALUnitType_IDRNLP
+}
+
+// NAL unit of H.265
+// Rec. ITU-T H.265 (08/2021) pp.36, 67-68
+type HEVCNALUnit struct {
+	ForbiddenZeroBit bool            // 1
+	Type             HEVCNALUnitType // 6
+	LayerID          uint8           // 6
+	TemporalID       uint8           // 3 nuh_temporal_id_plus1 - 1
+	// NAL unit bytes including the header, with emulation prevention bytes
+	Data []byte
+}
+
+// RBSP returns the payload without the header and emulation prevention bytes.
+func (n *HEVCNALUnit) RBSP() []byte {
+	if len(n.Data) < 2 {
+		return nil
+	}
+	return unescapeRBSP(n.Data[2:])
+}
+
+// Sequence parameter set up to the bit depth
+// Rec. ITU-T H.265 (08/2021) pp.40-42
+type HEVCSPS struct {
+	VideoParameterSetID              uint8  // 4
+	MaxSubLayers                     uint8  // 3 sps_max_sub_layers_minus1 + 1
+	TemporalIDNestingFlag            bool   // 1
+	GeneralProfileSpace              uint8  // 2
+	GeneralTierFlag                  bool   // 1
+	GeneralProfileIDC                uint8  // 5
+	GeneralProfileCompatibilityFlags uint32 // 32
+	GeneralProgressiveSourceFlag     bool   // 1
+	GeneralInterlacedSourceFlag      bool   // 1
+	GeneralLevelIDC                  uint8  // 8
+	SeqParameterSetID                uint64
+	ChromaFormatIDC                  uint64
+	SeparateColourPlaneFlag          bool
+	PicWidthInLumaSamples            uint64
+	PicHeightInLumaSamples           uint64
+	ConfWinLeftOffset                uint64
+	ConfWinRightOffset               uint64
+	ConfWinTopOffset                 uint64
+	ConfWinBottomOffset              uint64
+	BitDepthLuma                     uint64
+	BitDepthChroma                   uint64
+
+	// in luma samples after the conformance window
+	Width  int
+	Height int
+}
+
+// ProfileName returns the name of general_profile_idc. Rec. ITU-T H.265 (08/2021) Annex A
+func (sps *HEVCSPS) ProfileName() string {
+	switch sps.GeneralProfileIDC {
+	case 1:
+		return "Main"
+	case 2:
+		return "Main 10"
+	case 3:
+		return "Main Still Picture"
+	case 4:
+		return "Format Range Extensions"
+	case 5:
+		return "High Throughput"
+	case 9:
+		return "Screen Content Coding Extensions"
+	}
+	return fmt.Sprintf("Unknown(%d)", sps.GeneralProfileIDC)
+}
+
+// Level returns general_level_idc as a number like 5.1.
+func (sps *HEVCSPS) Level() float64 {
+	return float64(sps.GeneralLevelIDC) / 30
+}
+
+// HEVCAccessUnit is the NAL units of one coded picture.
+type HEVCAccessUnit struct {
+	NALUnits []HEVCNALUnit
+	// IRAP picture (IDR, CRA or BLA)
+	IRAP   bool
+	IDR    bool
+	HasVPS bool
+	HasPPS bool
+	// SPS in the access unit
+	SPS    HEVCSPS
+	HasSPS bool
+}
+
+// ParseHEVCNALUnits splits an Annex B byte stream into NAL units.
+func ParseHEVCNALUnits(es []byte) []HEVCNALUnit {
+	units := []HEVCNALUnit{}
+	for _, b := range splitAnnexB(es) {
+		if len(b) < 2 {
+			continue
+		}
+		units = append(units, HEVCNALUnit{
+			ForbiddenZeroBit: (b[0]>>7)&0x01 == 1,
+			Type:             HEVCNALUnitType((b[0] >> 1) & 0x3f),
+			LayerID:          (b[0]&0x01)<<5 | (b[1]>>3)&0x1f,
+			TemporalID:       (b[1] & 0x07) - 1,
+			Data:             b,
+		})
+	}
+	return units
+}
+
+// ParseHEVCAccessUnits splits an Annex B byte stream into access units.
+// Rec. ITU-T H.265 (08/2021) 7.4.2.4.4
+func ParseHEVCAccessUnits(es []byte) []HEVCAccessUnit {
+	aus := []HEVCAccessUnit{}
+	cur := HEVCAccessUnit{}
+	hasVCL := false
+	flush := func() {
+		if len(cur.NALUnits) > 0 {
+			aus = append(aus, cur)
+		}
+		cur = HEVCAccessUnit{}
+		hasVCL = false
+	}
+	for _, n := range ParseHEVCNALUnits(es) {
+		switch {
+		case n.Type >= HEVCNALUnitType_VPS && n.Type <= HEVCNALUnitType_AUD, n.Type == HEVCNALUnitType_PrefixSEI,
+			n.Type >= 41 && n.Type <= 44, n.Type >= 48 && n.Type <= 55:
+			if hasVCL {
+				flush()
+			}
+		case n.Type.IsVCL():
+			// first_slice_segment_in_pic_flag
+			if hasVCL && len(n.Data) > 2 && (n.Data[2]>>7)&0x01 == 1 {
+				flush()
+			}
+		}
+
+		cur.NALUnits = append(cur.NALUnits, n)
+		switch {
+		case n.Type == HEVCNALUnitType_VPS:
+			cur.HasVPS = true
+		case n.Type == HEVCNALUnitType_PPS:
+			cur.HasPPS = true
+		case n.Type == HEVCNALUnitType_SPS:
+			if sps, err := n.ParseSPS(); err == nil {
+				cur.SPS = sps
+				cur.HasSPS = true
+			}
+		case n.Type.IsVCL():
+			hasVCL = true
+			cur.IRAP = cur.IRAP || n.Type.IsIRAP()
+			cur.IDR = cur.IDR || n.Type.IsIDR()
+		}
+	}
+	flush()
+	return aus
+}
+
+// ParseHEVCAccessUnit returns the access unit information of the PES packet.
+// NAL units of every access unit in the PES packet are merged.
+func (pes *PES) ParseHEVCAccessUnit() (HEVCAccessUnit, error) {
+	aus := ParseHEVCAccessUnits(pes.ElementaryStream)
+	if len(aus) == 0 {
+		return HEVCAccessUnit{}, fmt.Errorf("no NAL unit is found")
+	}
+	au := aus[0]
+	for _, a := range aus[1:] {
+		au.NALUnits = append(au.NALUnits, a.NALUnits...)
+		au.IRAP = au.IRAP || a.IRAP
+		au.IDR = au.IDR || a.IDR
+		au.HasVPS = au.HasVPS || a.HasVPS
+		au.HasPPS = au.HasPPS || a.HasPPS
+		if a.HasSPS && !au.HasSPS {
+			au.SPS, au.HasSPS = a.SPS, true
+		}
+	}
+	return au, nil
+}
+
+// FindHEVCSPS returns the first SPS in the byte stream.
+func FindHEVCSPS(es []byte) (HEVCSPS, error) {
+	for _, n := range ParseHEVCNALUnits(es) {
+		if n.Type == HEVCNALUnitType_SPS {
+			return n.ParseSPS()
+		}
+	}
+	return HEVCSPS{}, ErrHEVCNoSPS
+}
+
+// ParseSPS decodes seq_parameter_set_rbsp up to bit_depth_chroma_minus8.
+func (n *HEVCNALUnit) ParseSPS() (HEVCSPS, error) {
+	if n.Type != HEVCNALUnitType_SPS {
+		return HEVCSPS{}, fmt.Errorf("invalid NAL unit type. expected: %d, actual: %d", HEVCNALUnitType_SPS, n.Type)
+	}
+	br := newBitReader(n.RBSP())
+	sps := HEVCSPS{}
+	sps.VideoParameterSetID = uint8(br.u(4))
+	sps.MaxSubLayers = uint8(br.u(3)) + 1
+	sps.TemporalIDNestingFlag = br.flag()
+
+	// profile_tier_level(1, sps_max_sub_layers_minus1)
+	// Rec. ITU-T H.265 (08/2021) pp.37-38
+	sps.GeneralProfileSpace = uint8(br.u(2))
+	sps.GeneralTierFlag = br.flag()
+	sps.GeneralProfileIDC = uint8(br.u(5))
+	sps.GeneralProfileCompatibilityFlags = uint32(br.u(32))
+	sps.GeneralProgressiveSourceFlag = br.flag()
+	sps.GeneralInterlacedSourceFlag = br.flag()
+	br.skip(2)  // general_non_packed_constraint_flag, general_frame_only_constraint_flag
+	br.skip(44) // general_reserved_zero_43bits, general_inbld_flag
+	sps.GeneralLevelIDC = uint8(br.u(8))
+	maxSubLayersMinus1 := int(sps.MaxSubLayers) - 1
+	profilePresent := make([]bool, maxSubLayersMinus1)
+	levelPresent := make([]bool, maxSubLayersMinus1)
+	for i := 0; i < maxSubLayersMinus1; i++ {
+		profilePresent[i] = br.flag()
+		levelPresent[i] = br.flag()
+	}
+	if maxSubLayersMinus1 > 0 {
+		br.skip(2 * (8 - maxSubLayersMinus1)) // reserved_zero_2bits
+	}
+	for i := 0; i < maxSubLayersMinus1; i++ {
+		if profilePresent[i] {
+			br.skip(88)
+		}
+		if levelPresent[i] {
+			br.skip(8)
+		}
+	}
+
+	sps.SeqParameterSetID = br.ue()
+	sps.ChromaFormatIDC = br.ue()
+	if sps.ChromaFormatIDC == 3 {
+		sps.SeparateColourPlaneFlag = br.flag()
+	}
+	sps.PicWidthInLumaSamples = br.ue()
+	sps.PicHeightInLumaSamples = br.ue()
+	if br.flag() { // conformance_window_flag
+		sps.ConfWinLeftOffset = br.ue()
+		sps.ConfWinRightOffset = br.ue()
+		sps.ConfWinTopOffset = br.ue()
+		sps.ConfWinBottomOffset = br.ue()
+	}
+	sps.BitDepthLuma = br.ue() + 8
+	sps.BitDepthChroma = br.ue() + 8
+	if br.err != nil {
+		return HEVCSPS{}, br.err
+	}
+
+	// Rec. ITU-T H.265 (08/2021) Table 6-1
+	subWidthC, subHeightC := uint64(1), uint64(1)
+	if !sps.SeparateColourPlaneFlag {
+		switch sps.ChromaFormatIDC {
+		case 1:
+			subWidthC, subHeightC = 2, 2
+		case 2:
+			subWidthC = 2
+		}
+	}
+	sps.Width = int(sps.PicWidthInLumaSamples - subWidthC*(sps.ConfWinLeftOffset+sps.ConfWinRightOffset))
+	sps.Height = int(sps.PicHeightInLumaSamples - subHeightC*(sps.ConfWinTopOffset+sps.ConfWinBottomOffset))
+	return sps, nil
+}
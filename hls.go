@@ -0,0 +1,277 @@
+package mpeg2ts
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type HLSPlaylistType int
+
+const (
+	// the playlist is written on Close with all segments
+	HLSPlaylistVOD HLSPlaylistType = iota
+	// the playlist is rewritten on every segment with the last LiveWindowSize segments
+	HLSPlaylistLive
+)
+
+// HLSSegmenter splits a program into MPEG-TS segments at video random access points
+// and writes a media playlist. RFC 8216
+type HLSSegmenter struct {
+	Dir               string
+	PlaylistName      string
+	SegmentNameFormat string
+	TargetDuration    time.Duration
+	// EXT-X-TARGETDURATION of the live playlist, which does not change once the playlist is written.
+	// Segments are split only at random access points, so it must not be shorter than the longest
+	// GOP. 0 for the longer of TargetDuration and the first segment. RFC 8216 6.2.1
+	LiveTargetDuration time.Duration
+	PlaylistType       HLSPlaylistType
+	LiveWindowSize     int
+	// remove segment files which slid out of the live window
+	DeleteExpiredSegments bool
+
+	tracker  programTracker
+	rewriter packetRewriter
+	current  *hlsSegmentWriter
+	segments []hlsSegment
+
+	nextSequence          int
+	mediaSequence         int
+	discontinuitySequence int
+	maxDuration           float64
+	// EXT-X-TARGETDURATION in seconds, which is fixed when the live playlist is written first
+	targetDuration int
+
+	lastDTS              uint64
+	hasLastDTS           bool
+	lastPCR              int64
+	hasLastPCR           bool
+	pendingDiscontinuity bool
+}
+
+type hlsSegment struct {
+	name          string
+	duration      float64
+	discontinuity bool
+}
+
+type hlsSegmentWriter struct {
+	hlsSegment
+	file *os.File
+	w    *bufio.Writer
+	// accumulated DTS difference in 90kHz
+	ticks int64
+}
+
+func NewHLSSegmenter(dir string, targetDuration time.Duration, playlistType HLSPlaylistType) (HLSSegmenter, error) {
+	if targetDuration <= 0 {
+		return HLSSegmenter{}, fmt.Errorf("invalid target duration %v", targetDuration)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return HLSSegmenter{}, err
+	}
+	h := HLSSegmenter{
+		Dir:               dir,
+		PlaylistName:      "index.m3u8",
+		SegmentNameFormat: "segment%05d.ts",
+		TargetDuration:    targetDuration,
+		PlaylistType:      playlistType,
+		LiveWindowSize:    6,
+	}
+	h.rewriter = newPacketRewriter()
+	return h, nil
+}
+
+// SetProgramNumber selects the program to segment. The first program in PAT is used by default.
+func (h *HLSSegmenter) SetProgramNumber(programNumber uint16) {
+	h.tracker.programNumber = programNumber
+}
+
+// Run segments packets from TransportStreamEngine.StartPacketReadLoop until the channel is closed
+// or ctx is done, then closes the segmenter.
+func (h *HLSSegmenter) Run(ctx context.Context, packets <-chan Packet) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return h.Close()
+		case p, ok := <-packets:
+			if !ok {
+				return h.Close()
+			}
+			if err := h.EnqueueTSPacket(p); err != nil {
+				h.Close()
+				return err
+			}
+		}
+	}
+}
+
+// EnqueueTSPacket writes a packet to the current segment. Packets before the first
+// video random access point are dropped.
+func (h *HLSSegmenter) EnqueueTSPacket(p Packet) error {
+	h.tracker.observe(p)
+
+	if h.tracker.hasPMT && p.PID == h.tracker.pcrPID && p.HasAdaptationField() && p.AdaptationField.PCRFlag {
+		pcr := p.AdaptationField.ProgramClockReference
+		raw := int64(pcr.Base*300 + uint64(pcr.Extension))
+		if h.hasLastPCR {
+			delta := raw - h.lastPCR
+			if delta < -pcrWrap/2 {
+				delta += pcrWrap
+			}
+			if p.AdaptationField.DiscontinuityIndicator || delta < 0 || delta > pcrMaxGap {
+				h.pendingDiscontinuity = true
+			}
+		}
+		h.lastPCR = raw
+		h.hasLastPCR = true
+	}
+
+	if h.tracker.hasVideo() && p.PID == h.tracker.videoPID && p.PayloadUnitStartIndicator {
+		if ts, err := p.ReadPESTimestamps(); err == nil && ts.HasPTS {
+			dts := ts.PTS
+			if ts.HasDTS {
+				dts = ts.DTS
+			}
+			if h.hasLastDTS && h.current != nil {
+				d := ptsDiff(dts, h.lastDTS)
+				if d > 0 && d <= pcrMaxGap/300 {
+					h.current.ticks += d
+				}
+			}
+			h.lastDTS = dts
+			h.hasLastDTS = true
+		}
+		if p.IsRandomAccessPoint(h.tracker.videoType) {
+			if h.current == nil || h.pendingDiscontinuity || h.current.ticks >= durationToPTS(h.TargetDuration) {
+				if err := h.startSegment(h.current != nil && h.pendingDiscontinuity); err != nil {
+					return err
+				}
+				h.pendingDiscontinuity = false
+			}
+		}
+	}
+
+	if h.current == nil {
+		return nil
+	}
+	return h.writePacket(p)
+}
+
+func (h *HLSSegmenter) writePacket(p Packet) error {
+	p = h.rewriter.rewrite(p)
+	_, err := h.current.w.Write(p.Data[:PacketSizeDefault])
+	return err
+}
+
+func (h *HLSSegmenter) startSegment(discontinuity bool) error {
+	if err := h.finishSegment(); err != nil {
+		return err
+	}
+	name := fmt.Sprintf(h.SegmentNameFormat, h.nextSequence)
+	h.nextSequence++
+	f, err := os.Create(filepath.Join(h.Dir, name))
+	if err != nil {
+		return err
+	}
+	h.current = &hlsSegmentWriter{
+		hlsSegment: hlsSegment{name: name, discontinuity: discontinuity},
+		file:       f,
+		w:          bufio.NewWriter(f),
+	}
+	if err := h.writePacket(h.tracker.pat); err != nil {
+		return err
+	}
+	return h.writePacket(h.tracker.pmt)
+}
+
+func (h *HLSSegmenter) finishSegment() error {
+	if h.current == nil {
+		return nil
+	}
+	cur := h.current
+	h.current = nil
+	if err := cur.w.Flush(); err != nil {
+		cur.file.Close()
+		return err
+	}
+	if err := cur.file.Close(); err != nil {
+		return err
+	}
+	cur.duration = float64(cur.ticks) / PTSClockFrequency
+	if cur.duration > h.maxDuration {
+		h.maxDuration = cur.duration
+	}
+	h.segments = append(h.segments, cur.hlsSegment)
+
+	if h.PlaylistType != HLSPlaylistLive {
+		return nil
+	}
+	for h.LiveWindowSize > 0 && len(h.segments) > h.LiveWindowSize {
+		expired := h.segments[0]
+		h.segments = h.segments[1:]
+		h.mediaSequence++
+		if expired.discontinuity {
+			h.discontinuitySequence++
+		}
+		if h.DeleteExpiredSegments {
+			if err := os.Remove(filepath.Join(h.Dir, expired.name)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return h.writePlaylist(false)
+}
+
+// Close finishes the current segment and writes the playlist with EXT-X-ENDLIST.
+func (h *HLSSegmenter) Close() error {
+	if err := h.finishSegment(); err != nil {
+		return err
+	}
+	return h.writePlaylist(true)
+}
+
+func (h *HLSSegmenter) writePlaylist(end bool) error {
+	b := &strings.Builder{}
+	b.WriteString("#EXTM3U\n")
+	b.WriteString("#EXT-X-VERSION:3\n")
+	target := h.targetDuration
+	if target == 0 {
+		target = int(math.Ceil(math.Max(h.maxDuration, h.TargetDuration.Seconds())))
+		if h.PlaylistType == HLSPlaylistLive {
+			target = int(math.Max(float64(target), math.Ceil(h.LiveTargetDuration.Seconds())))
+			h.targetDuration = target
+		}
+	}
+	fmt.Fprintf(b, "#EXT-X-TARGETDURATION:%d\n", target)
+	if h.PlaylistType == HLSPlaylistVOD {
+		b.WriteString("#EXT-X-PLAYLIST-TYPE:VOD\n")
+	}
+	fmt.Fprintf(b, "#EXT-X-MEDIA-SEQUENCE:%d\n", h.mediaSequence)
+	if h.discontinuitySequence > 0 {
+		fmt.Fprintf(b, "#EXT-X-DISCONTINUITY-SEQUENCE:%d\n", h.discontinuitySequence)
+	}
+	for _, s := range h.segments {
+		if s.discontinuity {
+			b.WriteString("#EXT-X-DISCONTINUITY\n")
+		}
+		fmt.Fprintf(b, "#EXTINF:%.3f,\n%s\n", s.duration, s.name)
+	}
+	if end {
+		b.WriteString("#EXT-X-ENDLIST\n")
+	}
+
+	// replace the playlist atomically for live clients
+	path := filepath.Join(h.Dir, h.PlaylistName)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
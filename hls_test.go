@@ -0,0 +1,90 @@
+package mpeg2ts
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHLSLiveTargetDuration(t *testing.T) {
+	// GOPs of 1s followed by GOPs of 3s
+	packets := []Packet{}
+	for _, gopSize := range []int{25, 75} {
+		m, _ := fixtureMux(t, 7*time.Second, gopSize, fixtureProgram())
+		packets = append(packets, m.PacketList.All()...)
+	}
+
+	dir := t.TempDir()
+	h, err := NewHLSSegmenter(dir, time.Second, HLSPlaylistLive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.LiveTargetDuration = 3 * time.Second
+	h.LiveWindowSize = 0
+	targets := map[string]bool{}
+	for _, p := range packets {
+		if err := h.EnqueueTSPacket(p); err != nil {
+			t.Fatal(err)
+		}
+		if b, err := os.ReadFile(filepath.Join(dir, h.PlaylistName)); err == nil {
+			targets[strings.SplitN(string(b), "\n", 4)[2]] = true
+		}
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 1 {
+		t.Fatalf("EXT-X-TARGETDURATION is changed: %v", targets)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, h.PlaylistName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, segments := 0, 0
+	for _, line := range strings.Split(string(b), "\n") {
+		if strings.HasPrefix(line, "#EXT-X-TARGETDURATION:") {
+			target, _ = strconv.Atoi(strings.TrimPrefix(line, "#EXT-X-TARGETDURATION:"))
+		}
+		if strings.HasPrefix(line, "#EXTINF:") {
+			segments++
+			d, _ := strconv.ParseFloat(strings.TrimSuffix(strings.TrimPrefix(line, "#EXTINF:"), ","), 64)
+			if int(math.Round(d)) > target {
+				t.Errorf("segment of %.3fs exceeds EXT-X-TARGETDURATION:%d", d, target)
+			}
+		} else if strings.HasSuffix(line, ".ts") {
+			checkSegmentStart(t, filepath.Join(dir, line))
+		}
+	}
+	if target != 3 || segments < 8 {
+		t.Errorf("EXT-X-TARGETDURATION:%d with %d segments", target, segments)
+	}
+}
+
+// checkSegmentStart checks that the first video PES of the segment is a random access point.
+func checkSegmentStart(t *testing.T, path string) {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pl, _ := NewPacketList(PacketSizeDefault)
+	for ; len(b) >= PacketSizeDefault; b = b[PacketSizeDefault:] {
+		if err := pl.AddBytes(b[:PacketSizeDefault], PacketSizeDefault); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, p := range pl.All() {
+		if p.PID == fixtureVideoPID && p.PayloadUnitStartIndicator {
+			if !p.IsRandomAccessPoint(StreamTypeAVC) {
+				t.Errorf("%s does not start with a random access point", filepath.Base(path))
+			}
+			return
+		}
+	}
+	t.Errorf("%s has no video", filepath.Base(path))
+}
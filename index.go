@@ -0,0 +1,411 @@
+package mpeg2ts
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrIndexInvalidFormat = errors.New("invalid index format")
+	ErrIndexNoEntry       = errors.New("no index entry is found")
+)
+
+const (
+	indexMagic   = "TSIX"
+	indexVersion = 1
+)
+
+// Index is a seek index of the random access points of every video stream.
+type Index struct {
+	PacketSize int
+	Streams    []IndexStream
+}
+
+type IndexStream struct {
+	PID     PID
+	Type    StreamType
+	Entries []IndexEntry
+}
+
+// IndexEntry is a PES packet which starts with random_access_indicator or a keyframe.
+type IndexEntry struct {
+	ByteOffset  int64
+	PacketIndex int
+	PTS         uint64 // 33
+	DTS         uint64 // 33
+	// the last PCR of the program before the entry in 27MHz
+	PCR    uint64 // 42
+	HasPTS bool
+	HasDTS bool
+	HasPCR bool
+
+	// PTS unwrapped from the first entry
+	extendedPTS int64
+}
+
+// IndexBuilder collects index entries from a packet stream. Every program in PAT is followed.
+type IndexBuilder struct {
+	index   Index
+	streams map[PID]int
+	pmtPIDs map[PID]bool
+	videos  map[PID]indexVideo
+	pcrs    map[PID]uint64
+	mutex   *sync.Mutex
+}
+
+type indexVideo struct {
+	streamType StreamType
+	pcrPID     PID
+}
+
+func NewIndexBuilder(packetSize int) IndexBuilder {
+	ib := IndexBuilder{
+		index:   Index{PacketSize: packetSize},
+		streams: map[PID]int{},
+		pmtPIDs: map[PID]bool{},
+		videos:  map[PID]indexVideo{},
+		pcrs:    map[PID]uint64{},
+	}
+	ib.mutex = &sync.Mutex{}
+	return ib
+}
+
+// BuildIndex builds an index from every packet of the stream.
+func (m *MPEG2TS) BuildIndex() Index {
+	ib := NewIndexBuilder(m.chunkSize)
+	for _, p := range m.PacketList.All() {
+		ib.EnqueueTSPacket(p)
+	}
+	return ib.Index()
+}
+
+// BuildIndex builds an index reading packets of packetSize bytes from r.
+// Bytes before a sync byte are skipped, so ByteOffset is the position in r of the packet, which
+// starts with TP_extra_header for 192 bytes packets.
+func BuildIndex(r io.Reader, packetSize int) (Index, error) {
+	if packetSize < PacketSizeDefault {
+		return Index{}, fmt.Errorf("invalid packet size %d", packetSize)
+	}
+	// bytes before the sync byte
+	prefix := 0
+	if packetSize == PacketSizeM2TS {
+		prefix = PacketSizeM2TS - PacketSizeDefault
+	}
+	ib := NewIndexBuilder(packetSize)
+	br := bufio.NewReaderSize(r, packetSize*1024)
+	offset := int64(0)
+	index := 0
+	synced := false
+	for {
+		// the packet and the sync byte of the next one
+		b, err := br.Peek(packetSize + prefix + 1)
+		if err != nil && err != io.EOF {
+			return Index{}, err
+		}
+		if len(b) < packetSize {
+			break
+		}
+		// a sync byte found while resyncing is trusted only if the next packet has one
+		if b[prefix] != 0x47 || (!synced && len(b) > packetSize+prefix && b[packetSize+prefix] != 0x47) {
+			synced = false
+			br.Discard(1)
+			offset++
+			continue
+		}
+		synced = true
+
+		p := Packet{Index: index}
+		p.Data = make([]byte, PacketSizeDefault)
+		copy(p.Data, b[prefix:])
+		if err := p.parseHeader(); err == nil {
+			ib.addPacket(p, offset)
+		}
+		br.Discard(packetSize)
+		offset += int64(packetSize)
+		index++
+	}
+	return ib.Index(), nil
+}
+
+// EnqueueTSPacket observes a packet. The byte offset is computed from Index and the packet size.
+func (ib *IndexBuilder) EnqueueTSPacket(p Packet) {
+	ib.addPacket(p, int64(p.Index)*int64(ib.index.PacketSize))
+}
+
+func (ib *IndexBuilder) addPacket(p Packet, offset int64) {
+	ib.mutex.Lock()
+	defer ib.mutex.Unlock()
+
+	if p.HasAdaptationField() && p.AdaptationField.PCRFlag {
+		pcr := p.AdaptationField.ProgramClockReference
+		ib.pcrs[p.PID] = pcr.Base*300 + uint64(pcr.Extension)
+	}
+
+	if p.PID == PID_PAT {
+		if !isCompleteSectionPacket(p) {
+			return
+		}
+		pat, err := p.ParsePAT()
+		if err != nil {
+			return
+		}
+		for _, prog := range pat.Programs {
+			if prog.ProgramNumber != 0 {
+				ib.pmtPIDs[prog.ProgramMapPID] = true
+			}
+		}
+		return
+	}
+	if ib.pmtPIDs[p.PID] {
+		if !isCompleteSectionPacket(p) {
+			return
+		}
+		pmt, err := p.ParsePMT(false)
+		if err != nil {
+			return
+		}
+		for _, si := range pmt.Streams {
+			if si.Type.IsVideo() {
+				ib.videos[si.ElementaryPID] = indexVideo{streamType: si.Type, pcrPID: pmt.PCR_PID}
+			}
+		}
+		return
+	}
+
+	v, ok := ib.videos[p.PID]
+	if !ok || !p.IsRandomAccessPoint(v.streamType) {
+		return
+	}
+	e := IndexEntry{ByteOffset: offset, PacketIndex: p.Index}
+	if ts, err := p.ReadPESTimestamps(); err == nil {
+		e.PTS, e.HasPTS = ts.PTS, ts.HasPTS
+		e.DTS, e.HasDTS = ts.DTS, ts.HasDTS
+	}
+	e.PCR, e.HasPCR = ib.pcrs[v.pcrPID]
+
+	i, ok := ib.streams[p.PID]
+	if !ok {
+		ib.index.Streams = append(ib.index.Streams, IndexStream{PID: p.PID, Type: v.streamType})
+		i = len(ib.index.Streams) - 1
+		ib.streams[p.PID] = i
+	}
+	ib.index.Streams[i].append(e)
+}
+
+// Index returns the entries collected so far.
+func (ib *IndexBuilder) Index() Index {
+	ib.mutex.Lock()
+	defer ib.mutex.Unlock()
+	idx := Index{PacketSize: ib.index.PacketSize}
+	for _, s := range ib.index.Streams {
+		entries := make([]IndexEntry, len(s.Entries))
+		copy(entries, s.Entries)
+		idx.Streams = append(idx.Streams, IndexStream{PID: s.PID, Type: s.Type, Entries: entries})
+	}
+	sort.Slice(idx.Streams, func(i, j int) bool { return idx.Streams[i].PID < idx.Streams[j].PID })
+	return idx
+}
+
+func (s *IndexStream) append(e IndexEntry) {
+	if n := len(s.Entries); n > 0 {
+		last := s.Entries[n-1]
+		e.extendedPTS = last.extendedPTS
+		if e.HasPTS && last.HasPTS {
+			e.extendedPTS += ptsDiff(e.PTS, last.PTS)
+		} else if e.HasPTS {
+			e.extendedPTS = int64(e.PTS)
+		}
+	} else if e.HasPTS {
+		e.extendedPTS = int64(e.PTS)
+	}
+	s.Entries = append(s.Entries, e)
+}
+
+// Stream returns the entries of the video PID.
+func (idx *Index) Stream(pid PID) (*IndexStream, bool) {
+	i := sort.Search(len(idx.Streams), func(i int) bool { return idx.Streams[i].PID >= pid })
+	if i == len(idx.Streams) || idx.Streams[i].PID != pid {
+		return nil, false
+	}
+	return &idx.Streams[i], true
+}
+
+// SeekPTS returns the last entry whose PTS is not later than pts. pts is resolved in
+// the 2^33 period which starts at the first entry.
+func (s *IndexStream) SeekPTS(pts uint64) (IndexEntry, error) {
+	if len(s.Entries) == 0 {
+		return IndexEntry{}, ErrIndexNoEntry
+	}
+	first := s.Entries[0]
+	target := first.extendedPTS + int64((pts-first.PTS)&(ptsWrap-1))
+	return s.seekExtendedPTS(target)
+}
+
+// SeekTime returns the last entry which is not later than d from the first entry.
+func (s *IndexStream) SeekTime(d time.Duration) (IndexEntry, error) {
+	if len(s.Entries) == 0 {
+		return IndexEntry{}, ErrIndexNoEntry
+	}
+	return s.seekExtendedPTS(s.Entries[0].extendedPTS + durationToPTS(d))
+}
+
+func (s *IndexStream) seekExtendedPTS(target int64) (IndexEntry, error) {
+	i := sort.Search(len(s.Entries), func(i int) bool { return s.Entries[i].extendedPTS > target })
+	if i == 0 {
+		return IndexEntry{}, ErrIndexNoEntry
+	}
+	return s.Entries[i-1], nil
+}
+
+// SeekByteOffset returns the last entry which starts at or before offset.
+func (s *IndexStream) SeekByteOffset(offset int64) (IndexEntry, error) {
+	i := sort.Search(len(s.Entries), func(i int) bool { return s.Entries[i].ByteOffset > offset })
+	if i == 0 {
+		return IndexEntry{}, ErrIndexNoEntry
+	}
+	return s.Entries[i-1], nil
+}
+
+// Duration returns the PTS distance between the first and the last entry.
+func (s *IndexStream) Duration() time.Duration {
+	if len(s.Entries) < 2 {
+		return 0
+	}
+	ticks := s.Entries[len(s.Entries)-1].extendedPTS - s.Entries[0].extendedPTS
+	return time.Duration(ticks) * time.Second / PTSClockFrequency
+}
+
+// WriteTo serializes the index in a little endian binary format.
+//
+//	magic "TSIX", version(8), packet_size(16), stream_count(16)
+//	stream: PID(16), stream_type(8), entry_count(32)
+//	entry: byte_offset(64), packet_index(64), PTS(64), DTS(64), PCR(64), flags(8)
+func (idx *Index) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	n := int64(0)
+	write := func(v any) error {
+		if err := binary.Write(bw, binary.LittleEndian, v); err != nil {
+			return err
+		}
+		n += int64(binary.Size(v))
+		return nil
+	}
+
+	if err := write([]byte(indexMagic)); err != nil {
+		return n, err
+	}
+	if err := write(struct {
+		Version     uint8
+		PacketSize  uint16
+		StreamCount uint16
+	}{indexVersion, uint16(idx.PacketSize), uint16(len(idx.Streams))}); err != nil {
+		return n, err
+	}
+	for _, s := range idx.Streams {
+		if err := write(struct {
+			PID        uint16
+			StreamType uint8
+			EntryCount uint32
+		}{uint16(s.PID), uint8(s.Type), uint32(len(s.Entries))}); err != nil {
+			return n, err
+		}
+		for _, e := range s.Entries {
+			if err := write(e.record()); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, bw.Flush()
+}
+
+type indexEntryRecord struct {
+	ByteOffset  int64
+	PacketIndex int64
+	PTS         uint64
+	DTS         uint64
+	PCR         uint64
+	Flags       uint8
+}
+
+func (e IndexEntry) record() indexEntryRecord {
+	r := indexEntryRecord{ByteOffset: e.ByteOffset, PacketIndex: int64(e.PacketIndex), PTS: e.PTS, DTS: e.DTS, PCR: e.PCR}
+	if e.HasPTS {
+		r.Flags |= 0x01
+	}
+	if e.HasDTS {
+		r.Flags |= 0x02
+	}
+	if e.HasPCR {
+		r.Flags |= 0x04
+	}
+	return r
+}
+
+// ReadIndex reads an index written by WriteTo.
+func ReadIndex(r io.Reader) (Index, error) {
+	br := bufio.NewReader(r)
+	read := func(v any) error {
+		err := binary.Read(br, binary.LittleEndian, v)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrIndexInvalidFormat
+		}
+		return err
+	}
+
+	magic := make([]byte, len(indexMagic))
+	if err := read(magic); err != nil {
+		return Index{}, err
+	}
+	if string(magic) != indexMagic {
+		return Index{}, ErrIndexInvalidFormat
+	}
+	header := struct {
+		Version     uint8
+		PacketSize  uint16
+		StreamCount uint16
+	}{}
+	if err := read(&header); err != nil {
+		return Index{}, err
+	}
+	if header.Version != indexVersion {
+		return Index{}, fmt.Errorf("unsupported index version %d", header.Version)
+	}
+
+	idx := Index{PacketSize: int(header.PacketSize)}
+	for i := 0; i < int(header.StreamCount); i++ {
+		sh := struct {
+			PID        uint16
+			StreamType uint8
+			EntryCount uint32
+		}{}
+		if err := read(&sh); err != nil {
+			return Index{}, err
+		}
+		s := IndexStream{PID: PID(sh.PID), Type: StreamType(sh.StreamType)}
+		for j := 0; j < int(sh.EntryCount); j++ {
+			rec := indexEntryRecord{}
+			if err := read(&rec); err != nil {
+				return Index{}, err
+			}
+			s.append(IndexEntry{
+				ByteOffset:  rec.ByteOffset,
+				PacketIndex: int(rec.PacketIndex),
+				PTS:         rec.PTS,
+				DTS:         rec.DTS,
+				PCR:         rec.PCR,
+				HasPTS:      rec.Flags&0x01 != 0,
+				HasDTS:      rec.Flags&0x02 != 0,
+				HasPCR:      rec.Flags&0x04 != 0,
+			})
+		}
+		idx.Streams = append(idx.Streams, s)
+	}
+	sort.Slice(idx.Streams, func(i, j int) bool { return idx.Streams[i].PID < idx.Streams[j].PID })
+	return idx, nil
+}
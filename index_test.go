@@ -0,0 +1,35 @@
+package mpeg2ts
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestBuildIndexM2TS(t *testing.T) {
+	_, ts := fixtureMux(t, 3*time.Second, 25, fixtureProgram())
+	// garbage with false sync bytes before 192 bytes packets with TP_extra_header
+	m2ts := []byte{0x47, 0x00, 0x47}
+	for i, b := 0, ts; len(b) > 0; i, b = i+1, b[PacketSizeDefault:] {
+		m2ts = append(m2ts, 0x00, 0x01, byte(i>>8), byte(i))
+		m2ts = append(m2ts, b[:PacketSizeDefault]...)
+	}
+
+	index, err := BuildIndex(bytes.NewReader(m2ts), PacketSizeM2TS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Streams) != 1 || len(index.Streams[0].Entries) != 3 {
+		t.Fatalf("index %+v", index)
+	}
+	for _, e := range index.Streams[0].Entries {
+		if e.ByteOffset != 3+int64(e.PacketIndex)*PacketSizeM2TS {
+			t.Errorf("packet %d at %d", e.PacketIndex, e.ByteOffset)
+		}
+		// the packet starts with TP_extra_header
+		packet := m2ts[e.ByteOffset+4 : e.ByteOffset+PacketSizeM2TS]
+		if !bytes.Equal(packet, ts[e.PacketIndex*PacketSizeDefault:(e.PacketIndex+1)*PacketSizeDefault]) {
+			t.Errorf("packet %d at %d is not the one of the entry", e.PacketIndex, e.ByteOffset)
+		}
+	}
+}
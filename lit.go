@@ -0,0 +1,77 @@
+package mpeg2ts
+
+import (
+	"fmt"
+)
+
+// Local Event Information Table
+// ARIB STD-B10 Part 2 p.41
+type LIT struct {
+	TableID                byte   // 8
+	SectionSyntaxIndicator bool   // 1
+	SectionLength          uint16 // 12
+	EventID                uint16 // 16
+	Version                byte   // 5
+	CurrentNextIndicator   bool   // 1
+	SectionNumber          byte   // 8
+	LastSectionNumber      byte   // 8
+	ServiceID              uint16 // 16
+	TransportStreamID      uint16 // 16
+	OriginalNetworkID      uint16 // 16
+	LocalEvents            []LITLocalEvent
+	CRC32                  uint // 32
+}
+
+type LITLocalEvent struct {
+	LocalEventID          uint16 // 16
+	DescriptorsLoopLength uint16 // 12
+	Descriptors           []ProgramElementDescriptor
+}
+
+func (s *Section) ParseLIT() (LIT, error) {
+	if s.TableID != TableID_LocalEventInformationSection {
+		return LIT{}, fmt.Errorf("invalid TableID. expected: 0x%02x, actual: 0x%02x", TableID_LocalEventInformationSection, s.TableID)
+	}
+	if err := s.CheckCRC(); err != nil {
+		return LIT{}, err
+	}
+	lit := LIT{}
+	lit.TableID = s.TableID
+	lit.SectionSyntaxIndicator = s.SectionSyntaxIndicator
+	lit.SectionLength = s.SectionLength
+	lit.EventID = s.TableIDExtension
+	lit.Version = s.Version
+	lit.CurrentNextIndicator = s.CurrentNextIndicator
+	lit.SectionNumber = s.SectionNumber
+	lit.LastSectionNumber = s.LastSectionNumber
+	lit.CRC32 = s.CRC32
+
+	data := s.Data
+	end := len(data) - 4
+	if end < 14 {
+		return LIT{}, ErrSectionTooShort
+	}
+	lit.ServiceID = uint16(data[8])<<8 | uint16(data[9])
+	lit.TransportStreamID = uint16(data[10])<<8 | uint16(data[11])
+	lit.OriginalNetworkID = uint16(data[12])<<8 | uint16(data[13])
+	index := 14
+
+	for index+4 <= end {
+		le := LITLocalEvent{}
+		le.LocalEventID = uint16(data[index])<<8 | uint16(data[index+1])                 // 16
+		le.DescriptorsLoopLength = uint16(data[index+2]&0x0f)<<8 | uint16(data[index+3]) // 12
+		index += 4
+		if index+int(le.DescriptorsLoopLength) > end {
+			return LIT{}, ErrSectionTooShort
+		}
+		var err error
+		var diff int
+		le.Descriptors, diff, err = readDescriptor(data, index, int(le.DescriptorsLoopLength), s.SIOptions.orARIB())
+		if err != nil {
+			return LIT{}, err
+		}
+		index += diff
+		lit.LocalEvents = append(lit.LocalEvents, le)
+	}
+	return lit, nil
+}
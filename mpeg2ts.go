@@ -6,7 +6,7 @@ import (
 )
 
 func New(chunkSize int) *MPEG2TS {
-	m := MPEG2TS{}
+	m := MPEG2TS{chunkSize: chunkSize}
 	m.PacketList, _ = NewPacketList(chunkSize)
 	return &m
 }
@@ -130,3 +130,22 @@ func (m *MPEG2TS) FilterByPIDs(pids ...PID) *MPEG2TS {
 	}
 	return mx
 }
+
+// ReadSections reassembles PSI/SI sections carried on the given PIDs.
+func (m *MPEG2TS) ReadSections(pids ...PID) []Section {
+	sa := NewSectionAssembler()
+	sections := []Section{}
+	for _, p := range m.PacketList.All() {
+		for _, id := range pids {
+			if p.PID == id {
+				s, err := sa.EnqueueTSPacket(p)
+				if err != nil {
+					break
+				}
+				sections = append(sections, s...)
+				break
+			}
+		}
+	}
+	return sections
+}
@@ -0,0 +1,316 @@
+package mpeg2ts
+
+import (
+	"fmt"
+	"time"
+)
+
+// start codes
+// Rec. ITU-T H.262 (02/2012) p.24
+const (
+	MPEG2VideoStartCode_Picture        = 0x00
+	MPEG2VideoStartCode_SliceMin       = 0x01
+	MPEG2VideoStartCode_SliceMax       = 0xAF
+	MPEG2VideoStartCode_UserData       = 0xB2
+	MPEG2VideoStartCode_SequenceHeader = 0xB3
+	MPEG2VideoStartCode_SequenceError  = 0xB4
+	MPEG2VideoStartCode_Extension      = 0xB5
+	MPEG2VideoStartCode_SequenceEnd    = 0xB7
+	MPEG2VideoStartCode_GroupOfPicture = 0xB8
+
+	// extension_start_code_identifier
+	mpeg2VideoExtension_Sequence      = 1
+	mpeg2VideoExtension_PictureCoding = 8
+)
+
+type MPEG2PictureCodingType uint8
+
+const (
+	MPEG2PictureCodingType_I = MPEG2PictureCodingType(1)
+	MPEG2PictureCodingType_P = MPEG2PictureCodingType(2)
+	MPEG2PictureCodingType_B = MPEG2PictureCodingType(3)
+	MPEG2PictureCodingType_D = MPEG2PictureCodingType(4) // MPEG-1 only
+)
+
+func (t MPEG2PictureCodingType) String() string {
+	switch t {
+	case MPEG2PictureCodingType_I:
+		return "I"
+	case MPEG2PictureCodingType_P:
+		return "P"
+	case MPEG2PictureCodingType_B:
+		return "B"
+	case MPEG2PictureCodingType_D:
+		return "D"
+	}
+	return fmt.Sprintf("Unknown(%d)", uint8(t))
+}
+
+// frame_rate_value by frame_rate_code
+// Rec. ITU-T H.262 (02/2012) p.40
+var mpeg2VideoFrameRates = [][2]int{
+	{0, 0}, {24000, 1001}, {24, 1}, {25, 1}, {30000, 1001}, {30, 1}, {50, 1}, {60000, 1001}, {60, 1},
+}
+
+// Rec. ITU-T H.262 (02/2012) pp.30, 38-40
+type MPEG2SequenceHeader struct {
+	HorizontalSize              uint16 // 12
+	VerticalSize                uint16 // 12
+	AspectRatioInformation      uint8  // 4
+	FrameRateCode               uint8  // 4
+	BitRateValue                uint32 // 18
+	VBVBufferSizeValue          uint16 // 10
+	ConstrainedParametersFlag   bool   // 1
+	LoadIntraQuantiserMatrix    bool   // 1
+	LoadNonIntraQuantiserMatrix bool   // 1
+}
+
+// Rec. ITU-T H.262 (02/2012) pp.31, 41-43
+type MPEG2SequenceExtension struct {
+	ProfileAndLevelIndication uint8  // 8
+	ProgressiveSequence       bool   // 1
+	ChromaFormat              uint8  // 2
+	HorizontalSizeExtension   uint8  // 2
+	VerticalSizeExtension     uint8  // 2
+	BitRateExtension          uint16 // 12
+	VBVBufferSizeExtension    uint8  // 8
+	LowDelay                  bool   // 1
+	FrameRateExtensionN       uint8  // 2
+	FrameRateExtensionD       uint8  // 5
+}
+
+// Rec. ITU-T H.262 (02/2012) pp.34, 50-51
+type MPEG2GOPHeader struct {
+	DropFrameFlag bool  // 1
+	Hours         uint8 // 5
+	Minutes       uint8 // 6
+	Seconds       uint8 // 6
+	Pictures      uint8 // 6
+	ClosedGOP     bool  // 1
+	BrokenLink    bool  // 1
+}
+
+// Rec. ITU-T H.262 (02/2012) pp.34, 51-52
+type MPEG2PictureHeader struct {
+	TemporalReference uint16                 // 10
+	PictureCodingType MPEG2PictureCodingType // 3
+	VBVDelay          uint16                 // 16
+}
+
+// Rec. ITU-T H.262 (02/2012) pp.35-36, 53-56
+type MPEG2PictureCodingExtension struct {
+	FCode                    [2][2]uint8 // 4 each
+	IntraDCPrecision         uint8       // 2
+	PictureStructure         uint8       // 2
+	TopFieldFirst            bool        // 1
+	FramePredFrameDCT        bool        // 1
+	ConcealmentMotionVectors bool        // 1
+	QScaleType               bool        // 1
+	IntraVLCFormat           bool        // 1
+	AlternateScan            bool        // 1
+	RepeatFirstField         bool        // 1
+	Chroma420Type            bool        // 1
+	ProgressiveFrame         bool        // 1
+	CompositeDisplayFlag     bool        // 1
+}
+
+type MPEG2Picture struct {
+	Header             MPEG2PictureHeader
+	CodingExtension    MPEG2PictureCodingExtension
+	HasCodingExtension bool
+}
+
+// MPEG2VideoHeaders is the headers found in a part of an elementary stream.
+type MPEG2VideoHeaders struct {
+	SequenceHeader       MPEG2SequenceHeader
+	HasSequenceHeader    bool
+	SequenceExtension    MPEG2SequenceExtension
+	HasSequenceExtension bool
+	GOPHeader            MPEG2GOPHeader
+	HasGOPHeader         bool
+	Pictures             []MPEG2Picture
+}
+
+// ParseMPEG2VideoHeaders decodes the sequence, GOP and picture headers in the elementary stream.
+// Slices are skipped.
+func ParseMPEG2VideoHeaders(es []byte) (MPEG2VideoHeaders, error) {
+	h := MPEG2VideoHeaders{}
+	// the header which the following extension belongs to
+	last := -1
+	for i := 0; i+3 < len(es); i++ {
+		if es[i] != 0x00 || es[i+1] != 0x00 || es[i+2] != 0x01 {
+			continue
+		}
+		code := es[i+3]
+		br := newBitReader(es[i+4:])
+		switch {
+		case code == MPEG2VideoStartCode_SequenceHeader:
+			h.SequenceHeader = readMPEG2SequenceHeader(br)
+			h.HasSequenceHeader = true
+		case code == MPEG2VideoStartCode_GroupOfPicture:
+			h.GOPHeader = readMPEG2GOPHeader(br)
+			h.HasGOPHeader = true
+		case code == MPEG2VideoStartCode_Picture:
+			p := MPEG2Picture{}
+			p.Header.TemporalReference = uint16(br.u(10))
+			p.Header.PictureCodingType = MPEG2PictureCodingType(br.u(3))
+			p.Header.VBVDelay = uint16(br.u(16))
+			h.Pictures = append(h.Pictures, p)
+		case code == MPEG2VideoStartCode_Extension:
+			switch br.u(4) {
+			case mpeg2VideoExtension_Sequence:
+				if last == MPEG2VideoStartCode_SequenceHeader {
+					h.SequenceExtension = readMPEG2SequenceExtension(br)
+					h.HasSequenceExtension = true
+				}
+			case mpeg2VideoExtension_PictureCoding:
+				if last == MPEG2VideoStartCode_Picture && len(h.Pictures) > 0 {
+					p := &h.Pictures[len(h.Pictures)-1]
+					p.CodingExtension = readMPEG2PictureCodingExtension(br)
+					p.HasCodingExtension = true
+				}
+			}
+		}
+		if br.err != nil {
+			return h, fmt.Errorf("start code 0x%02X: %w", code, br.err)
+		}
+		if code != MPEG2VideoStartCode_Extension && code != MPEG2VideoStartCode_UserData {
+			last = int(code)
+		}
+		if code >= MPEG2VideoStartCode_SliceMin && code <= MPEG2VideoStartCode_SliceMax {
+			// the rest of the picture is slice data
+			last = -1
+		}
+		i += 3
+	}
+	return h, nil
+}
+
+// ParseMPEG2VideoHeaders decodes the headers in the elementary stream of the PES packet.
+func (pes *PES) ParseMPEG2VideoHeaders() (MPEG2VideoHeaders, error) {
+	return ParseMPEG2VideoHeaders(pes.ElementaryStream)
+}
+
+func readMPEG2SequenceHeader(br *bitReader) MPEG2SequenceHeader {
+	sh := MPEG2SequenceHeader{}
+	sh.HorizontalSize = uint16(br.u(12))
+	sh.VerticalSize = uint16(br.u(12))
+	sh.AspectRatioInformation = uint8(br.u(4))
+	sh.FrameRateCode = uint8(br.u(4))
+	sh.BitRateValue = uint32(br.u(18))
+	br.skip(1) // marker_bit
+	sh.VBVBufferSizeValue = uint16(br.u(10))
+	sh.ConstrainedParametersFlag = br.flag()
+	sh.LoadIntraQuantiserMatrix = br.flag()
+	if sh.LoadIntraQuantiserMatrix {
+		br.skip(8 * 64)
+	}
+	sh.LoadNonIntraQuantiserMatrix = br.flag()
+	return sh
+}
+
+func readMPEG2SequenceExtension(br *bitReader) MPEG2SequenceExtension {
+	se := MPEG2SequenceExtension{}
+	se.ProfileAndLevelIndication = uint8(br.u(8))
+	se.ProgressiveSequence = br.flag()
+	se.ChromaFormat = uint8(br.u(2))
+	se.HorizontalSizeExtension = uint8(br.u(2))
+	se.VerticalSizeExtension = uint8(br.u(2))
+	se.BitRateExtension = uint16(br.u(12))
+	br.skip(1) // marker_bit
+	se.VBVBufferSizeExtension = uint8(br.u(8))
+	se.LowDelay = br.flag()
+	se.FrameRateExtensionN = uint8(br.u(2))
+	se.FrameRateExtensionD = uint8(br.u(5))
+	return se
+}
+
+func readMPEG2GOPHeader(br *bitReader) MPEG2GOPHeader {
+	g := MPEG2GOPHeader{}
+	g.DropFrameFlag = br.flag()
+	g.Hours = uint8(br.u(5))
+	g.Minutes = uint8(br.u(6))
+	br.skip(1) // marker_bit
+	g.Seconds = uint8(br.u(6))
+	g.Pictures = uint8(br.u(6))
+	g.ClosedGOP = br.flag()
+	g.BrokenLink = br.flag()
+	return g
+}
+
+func readMPEG2PictureCodingExtension(br *bitReader) MPEG2PictureCodingExtension {
+	pe := MPEG2PictureCodingExtension{}
+	pe.FCode[0][0] = uint8(br.u(4))
+	pe.FCode[0][1] = uint8(br.u(4))
+	pe.FCode[1][0] = uint8(br.u(4))
+	pe.FCode[1][1] = uint8(br.u(4))
+	pe.IntraDCPrecision = uint8(br.u(2))
+	pe.PictureStructure = uint8(br.u(2))
+	pe.TopFieldFirst = br.flag()
+	pe.FramePredFrameDCT = br.flag()
+	pe.ConcealmentMotionVectors = br.flag()
+	pe.QScaleType = br.flag()
+	pe.IntraVLCFormat = br.flag()
+	pe.AlternateScan = br.flag()
+	pe.RepeatFirstField = br.flag()
+	pe.Chroma420Type = br.flag()
+	pe.ProgressiveFrame = br.flag()
+	pe.CompositeDisplayFlag = br.flag()
+	return pe
+}
+
+// Width returns horizontal_size including horizontal_size_extension.
+func (h *MPEG2VideoHeaders) Width() int {
+	return int(h.SequenceExtension.HorizontalSizeExtension)<<12 | int(h.SequenceHeader.HorizontalSize)
+}
+
+// Height returns vertical_size including vertical_size_extension.
+func (h *MPEG2VideoHeaders) Height() int {
+	return int(h.SequenceExtension.VerticalSizeExtension)<<12 | int(h.SequenceHeader.VerticalSize)
+}
+
+// FrameRate returns frames per second including frame_rate_extension.
+func (h *MPEG2VideoHeaders) FrameRate() (float64, bool) {
+	code := int(h.SequenceHeader.FrameRateCode)
+	if code == 0 || code >= len(mpeg2VideoFrameRates) {
+		return 0, false
+	}
+	r := mpeg2VideoFrameRates[code]
+	n := float64(h.SequenceExtension.FrameRateExtensionN) + 1
+	d := float64(h.SequenceExtension.FrameRateExtensionD) + 1
+	return float64(r[0]) / float64(r[1]) * n / d, true
+}
+
+// Bitrate returns bit/s. bit_rate_value 0x3FFFF of MPEG-1 means a variable bitrate.
+func (h *MPEG2VideoHeaders) Bitrate() int {
+	return (int(h.SequenceExtension.BitRateExtension)<<18 | int(h.SequenceHeader.BitRateValue)) * 400
+}
+
+// AspectRatio returns the display aspect ratio like "16:9", or "1:1" for square samples.
+// Rec. ITU-T H.262 (02/2012) p.39
+func (h *MPEG2VideoHeaders) AspectRatio() string {
+	switch h.SequenceHeader.AspectRatioInformation {
+	case 1:
+		return "1:1"
+	case 2:
+		return "4:3"
+	case 3:
+		return "16:9"
+	case 4:
+		return "2.21:1"
+	}
+	return ""
+}
+
+// TimeCode returns time_code of the GOP header.
+func (g *MPEG2GOPHeader) TimeCode() time.Duration {
+	return time.Duration(g.Hours)*time.Hour + time.Duration(g.Minutes)*time.Minute + time.Duration(g.Seconds)*time.Second
+}
+
+// IsKeyframe reports whether the headers start a sequence or a GOP with an I picture.
+func (h *MPEG2VideoHeaders) IsKeyframe() bool {
+	if !h.HasSequenceHeader && !h.HasGOPHeader {
+		return false
+	}
+	return len(h.Pictures) > 0 && h.Pictures[0].Header.PictureCodingType == MPEG2PictureCodingType_I
+}
@@ -0,0 +1,172 @@
+package mpeg2ts
+
+// bitrates in kbit/s by bitrate_index
+// ISO/IEC 11172-3:1993 p.21, ISO/IEC 13818-3:1998 p.15
+var (
+	mpegAudioBitratesV1 = [3][15]int{
+		{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448}, // Layer I
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},    // Layer II
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},     // Layer III
+	}
+	mpegAudioBitratesV2 = [3][15]int{
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256}, // Layer I
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},      // Layer II
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},      // Layer III
+	}
+	mpegAudioSampleRates = [3]int{44100, 48000, 32000}
+)
+
+const (
+	MPEGAudioVersion2_5 = 0
+	MPEGAudioVersion2   = 2
+	MPEGAudioVersion1   = 3
+
+	MPEGAudioLayer3 = 1
+	MPEGAudioLayer2 = 2
+	MPEGAudioLayer1 = 3
+
+	MPEGAudioMode_Stereo        = 0
+	MPEGAudioMode_JointStereo   = 1
+	MPEGAudioMode_DualChannel   = 2
+	MPEGAudioMode_SingleChannel = 3
+)
+
+// MPEG audio frame header
+// ISO/IEC 11172-3:1993 pp.20-23, ISO/IEC 13818-3:1998 pp.14-15
+type MPEGAudioHeader struct {
+	Syncword          uint16 // 11 (12 in ISO/IEC 11172-3 with ID)
+	Version           uint8  // 2 including the MPEG-2.5 extension
+	Layer             uint8  // 2
+	ProtectionBit     bool   // 1 0: protected by CRC
+	BitrateIndex      uint8  // 4
+	SamplingFrequency uint8  // 2
+	PaddingBit        bool   // 1
+	PrivateBit        bool   // 1
+	Mode              uint8  // 2
+	ModeExtension     uint8  // 2
+	Copyright         bool   // 1
+	OriginalHome      bool   // 1
+	Emphasis          uint8  // 2
+}
+
+// ParseMPEGAudioHeader decodes the MPEG-1/2 audio frame header at the head of b.
+// Free format bitstreams are not supported.
+func ParseMPEGAudioHeader(b []byte) (MPEGAudioHeader, error) {
+	if len(b) < 4 {
+		return MPEGAudioHeader{}, errAudioNeedMoreData
+	}
+	h := MPEGAudioHeader{}
+	h.Syncword = uint16(b[0])<<3 | uint16(b[1]>>5) // 11
+	h.Version = (b[1] >> 3) & 0x03                 // 2
+	h.Layer = (b[1] >> 1) & 0x03                   // 2
+	h.ProtectionBit = (b[1] & 0x01) == 1           // 1
+	h.BitrateIndex = (b[2] >> 4) & 0x0f            // 4
+	h.SamplingFrequency = (b[2] >> 2) & 0x03       // 2
+	h.PaddingBit = ((b[2] >> 1) & 0x01) == 1       // 1
+	h.PrivateBit = (b[2] & 0x01) == 1              // 1
+	h.Mode = (b[3] >> 6) & 0x03                    // 2
+	h.ModeExtension = (b[3] >> 4) & 0x03           // 2
+	h.Copyright = ((b[3] >> 3) & 0x01) == 1        // 1
+	h.OriginalHome = ((b[3] >> 2) & 0x01) == 1     // 1
+	h.Emphasis = b[3] & 0x03                       // 2
+	if h.Syncword != 0x7ff || h.Version == 1 || h.Layer == 0 || h.BitrateIndex == 0 || h.BitrateIndex == 0x0f || h.SamplingFrequency == 3 {
+		return MPEGAudioHeader{}, errAudioNoSync
+	}
+	return h, nil
+}
+
+// Bitrate returns the bitrate in bit/s.
+func (h *MPEGAudioHeader) Bitrate() int {
+	if h.Version == MPEGAudioVersion1 {
+		return mpegAudioBitratesV1[3-h.Layer][h.BitrateIndex] * 1000
+	}
+	return mpegAudioBitratesV2[3-h.Layer][h.BitrateIndex] * 1000
+}
+
+func (h *MPEGAudioHeader) SampleRate() int {
+	sr := mpegAudioSampleRates[h.SamplingFrequency]
+	switch h.Version {
+	case MPEGAudioVersion2:
+		return sr / 2
+	case MPEGAudioVersion2_5:
+		return sr / 4
+	}
+	return sr
+}
+
+func (h *MPEGAudioHeader) SamplesPerFrame() int {
+	switch {
+	case h.Layer == MPEGAudioLayer1:
+		return 384
+	case h.Layer == MPEGAudioLayer3 && h.Version != MPEGAudioVersion1:
+		return 576
+	}
+	return 1152
+}
+
+// FrameLength returns the frame size in bytes including the header.
+func (h *MPEGAudioHeader) FrameLength() int {
+	padding := 0
+	if h.PaddingBit {
+		padding = 1
+	}
+	if h.Layer == MPEGAudioLayer1 {
+		return (12*h.Bitrate()/h.SampleRate() + padding) * 4
+	}
+	return h.SamplesPerFrame()/8*h.Bitrate()/h.SampleRate() + padding
+}
+
+func (h *MPEGAudioHeader) AudioInfo() AudioInfo {
+	info := AudioInfo{
+		Codec:           "MPEG-1 Audio",
+		SampleRate:      h.SampleRate(),
+		Channels:        2,
+		ChannelLayout:   "2.0",
+		SamplesPerFrame: h.SamplesPerFrame(),
+		Bitrate:         h.Bitrate(),
+	}
+	if h.Version != MPEGAudioVersion1 {
+		info.Codec = "MPEG-2 Audio"
+	}
+	switch h.Layer {
+	case MPEGAudioLayer1:
+		info.Profile = "Layer I"
+	case MPEGAudioLayer2:
+		info.Profile = "Layer II"
+	case MPEGAudioLayer3:
+		info.Profile = "Layer III"
+	}
+	switch h.Mode {
+	case MPEGAudioMode_Stereo:
+		info.ChannelMode = "Stereo"
+	case MPEGAudioMode_JointStereo:
+		info.ChannelMode = "Joint stereo"
+	case MPEGAudioMode_DualChannel:
+		info.ChannelMode = "Dual channel"
+		info.ChannelLayout = "1+1"
+	case MPEGAudioMode_SingleChannel:
+		info.ChannelMode = "Single channel"
+		info.Channels = 1
+		info.ChannelLayout = "1.0"
+	}
+	return info
+}
+
+type mpegAudioFrameReader struct{}
+
+func (mpegAudioFrameReader) readFrame(b []byte) (int, AudioInfo, error) {
+	h, err := ParseMPEGAudioHeader(b)
+	if err != nil {
+		return 0, AudioInfo{}, err
+	}
+	size := h.FrameLength()
+	if len(b) < size {
+		return 0, AudioInfo{}, errAudioNeedMoreData
+	}
+	return size, h.AudioInfo(), nil
+}
+
+// NewMPEGAudioSplitter returns a splitter for StreamTypeISO11172_3_Audio and StreamTypeISO13818_3_Audio.
+func NewMPEGAudioSplitter() AudioSplitter {
+	return newAudioSplitter(mpegAudioFrameReader{})
+}
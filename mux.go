@@ -0,0 +1,489 @@
+package mpeg2ts
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+var (
+	ErrMuxerUnknownPID   = errors.New("PID is not an elementary stream of the programs")
+	ErrMuxerDuplicatePID = errors.New("PID is already used")
+	ErrMuxerPESTooLong   = errors.New("PES packet of a non-video stream exceeds 65535 bytes")
+)
+
+const (
+	// stream_id of audio and video streams
+	// Rec. ITU-T H.222.0 (06/2021) p.43
+	streamIDAudio = 0xc0
+	streamIDVideo = 0xe0
+)
+
+// MuxerStream is an elementary stream of a MuxerProgram.
+type MuxerStream struct {
+	PID  PID
+	Type StreamType
+	// stream_id of the PES packets. 0 to choose from Type
+	StreamID    byte
+	Descriptors []ProgramElementDescriptor
+	// buffers of the T-STD model in the constant bitrate mode. zero value for DefaultTSTDBuffer(Type, 0)
+	TSTD TSTDBuffer
+}
+
+// MuxerProgram is a program of PAT and its PMT.
+type MuxerProgram struct {
+	ProgramNumber uint16
+	PMTPID        PID
+	// 0 to carry PCR on the first video stream, or the first stream. A PID of no stream
+	// gets PCR only packets.
+	PCRPID      PID
+	Descriptors []ProgramElementDescriptor
+	Streams     []MuxerStream
+}
+
+// AccessUnit is an access unit of an elementary stream, packetized into one PES packet.
+type AccessUnit struct {
+	PID    PID
+	PTS    uint64 // 33
+	DTS    uint64 // 33
+	HasPTS bool
+	HasDTS bool
+	// sets random_access_indicator on the first packet
+	RandomAccess bool
+	Data         []byte
+}
+
+// Muxer packetizes access units into a transport stream. PAT and PMTs are repeated every
+// PSIInterval, and every program gets PCR at least every PCRInterval of the system time clock,
+// which runs PCRDelay behind DTS of the access units.
+type Muxer struct {
+	TransportStreamID uint16
+	PSIInterval       time.Duration
+	// must not exceed 100ms. Rec. ITU-T H.222.0 (06/2021) p.145
+	PCRInterval time.Duration
+	PCRDelay    time.Duration
+	// sets data_alignment_indicator on the PES packets
+	DataAlignment bool
+	// in bit/s. 0 for a variable bitrate stream. see writeAccessUnitCBR
+	MuxRate int
+
+	w        io.Writer
+	programs []*muxerProgram
+	streams  map[PID]*muxerStream
+	// continuity_counter of the next packet with payload
+	cc         map[PID]byte
+	patVersion byte
+
+	started bool
+	// system time clock in 27MHz, extended beyond 33bit
+	clock       int64
+	extendedDTS int64
+	lastDTS     uint64
+	hasDTS      bool
+	lastPSI     int64
+	psiDue      bool
+	cbr         *cbrScheduler
+	mutex       *sync.Mutex
+}
+
+type muxerProgram struct {
+	MuxerProgram
+	lastPCR int64
+	hasPCR  bool
+	// PCR_PID carries no elementary stream, so PCR is not sent with access units
+	dedicatedPCR bool
+}
+
+type muxerStream struct {
+	MuxerStream
+	program *muxerProgram
+}
+
+func NewMuxer(w io.Writer) Muxer {
+	mx := Muxer{
+		PSIInterval:   100 * time.Millisecond,
+		PCRInterval:   40 * time.Millisecond,
+		PCRDelay:      700 * time.Millisecond,
+		DataAlignment: true,
+		w:             w,
+	}
+	mx.streams = map[PID]*muxerStream{}
+	mx.cc = map[PID]byte{}
+	mx.mutex = &sync.Mutex{}
+	return mx
+}
+
+// AddProgram adds a program. A program added after the first access unit is announced by
+// a new version of PAT.
+func (mx *Muxer) AddProgram(p MuxerProgram) error {
+	mx.mutex.Lock()
+	defer mx.mutex.Unlock()
+
+	used := map[PID]bool{PID_PAT: true, PID_NullPacket: true}
+	for pid := range mx.streams {
+		used[pid] = true
+	}
+	for _, prog := range mx.programs {
+		if prog.ProgramNumber == p.ProgramNumber {
+			return fmt.Errorf("program_number %d is already used", p.ProgramNumber)
+		}
+		used[prog.PMTPID] = true
+		if prog.dedicatedPCR {
+			used[prog.PCRPID] = true
+		}
+	}
+	if used[p.PMTPID] {
+		return fmt.Errorf("PMT PID 0x%04x: %w", p.PMTPID, ErrMuxerDuplicatePID)
+	}
+	used[p.PMTPID] = true
+	own := map[PID]bool{}
+	for _, s := range p.Streams {
+		if used[s.PID] {
+			return fmt.Errorf("PID 0x%04x: %w", s.PID, ErrMuxerDuplicatePID)
+		}
+		used[s.PID] = true
+		own[s.PID] = true
+	}
+	dedicatedPCR := p.PCRPID != 0 && p.PCRPID != PID_NullPacket && !own[p.PCRPID]
+	if dedicatedPCR && used[p.PCRPID] {
+		return fmt.Errorf("PCR PID 0x%04x: %w", p.PCRPID, ErrMuxerDuplicatePID)
+	}
+	if p.PCRPID == 0 && len(p.Streams) > 0 {
+		p.PCRPID = p.Streams[0].PID
+		for _, s := range p.Streams {
+			if s.Type.IsVideo() {
+				p.PCRPID = s.PID
+				break
+			}
+		}
+	}
+
+	prog := &muxerProgram{MuxerProgram: p, dedicatedPCR: dedicatedPCR}
+	mx.programs = append(mx.programs, prog)
+	for _, s := range p.Streams {
+		if s.StreamID == 0 {
+			s.StreamID = defaultStreamID(s.Type)
+		}
+		mx.streams[s.PID] = &muxerStream{MuxerStream: s, program: prog}
+	}
+	if mx.started {
+		mx.patVersion = (mx.patVersion + 1) & 0x1f
+	}
+	mx.psiDue = true
+	return nil
+}
+
+func defaultStreamID(st StreamType) byte {
+	switch st {
+	case StreamTypeISO11172_3_Audio, StreamTypeISO13818_3_Audio, StreamTypeISO13818_7_AudioWithADTS, StreamTypeISO14496_3_AudioWithLATM:
+		return streamIDAudio
+	}
+	if st.IsVideo() {
+		return streamIDVideo
+	}
+	return StreamID_PrivateStream1
+}
+
+// WriteAccessUnit writes an access unit as a PES packet with the PSI and PCR packets which are due.
+// Access units are expected in DTS order.
+func (mx *Muxer) WriteAccessUnit(au AccessUnit) error {
+	mx.mutex.Lock()
+	defer mx.mutex.Unlock()
+
+	s, ok := mx.streams[au.PID]
+	if !ok {
+		return fmt.Errorf("PID 0x%04x: %w", au.PID, ErrMuxerUnknownPID)
+	}
+	pes, err := s.buildPES(au, mx.DataAlignment)
+	if err != nil {
+		return err
+	}
+	if mx.MuxRate > 0 {
+		return mx.writeAccessUnitCBR(s, au, pes)
+	}
+
+	if err := mx.advanceClock(au); err != nil {
+		return err
+	}
+	if mx.psiDue || mx.clock-mx.lastPSI >= durationToClock(mx.PSIInterval) {
+		if err := mx.writePSI(); err != nil {
+			return err
+		}
+	}
+	if err := mx.startDedicatedPCR(); err != nil {
+		return err
+	}
+
+	prog := s.program
+	af := muxerAdaptationField{randomAccess: au.RandomAccess}
+	if prog.PCRPID == au.PID && (!prog.hasPCR || mx.clock > prog.lastPCR) {
+		af.hasPCR, af.pcr = true, mx.clock
+		prog.lastPCR, prog.hasPCR = mx.clock, true
+	}
+	for first := true; first || len(pes) > 0; first = false {
+		b, n := buildTSPacket(au.PID, first, mx.nextCC(au.PID, true), af, pes)
+		if err := mx.write(b); err != nil {
+			return err
+		}
+		pes = pes[n:]
+		af = muxerAdaptationField{}
+	}
+	return nil
+}
+
+// advanceClock moves the system time clock to PCRDelay before DTS of the access unit. Programs
+// whose PCR would fall behind PCRInterval get PCR only packets on the way.
+func (mx *Muxer) advanceClock(au AccessUnit) error {
+	first := !mx.hasDTS
+	if _, ok := mx.extendDTS(au); ok {
+		target := mx.clockTarget()
+		if first {
+			mx.clock = target
+		} else if target > mx.clock {
+			interval := durationToClock(mx.PCRInterval)
+			for _, prog := range mx.programs {
+				for prog.hasPCR && target-prog.lastPCR > interval {
+					prog.lastPCR += interval
+					if err := mx.write(mx.pcrOnlyPacket(prog, prog.lastPCR)); err != nil {
+						return err
+					}
+				}
+			}
+			mx.clock = target
+		}
+	}
+	if !mx.started {
+		mx.started = true
+		mx.lastPSI = mx.clock
+	}
+	return nil
+}
+
+// startDedicatedPCR sends the first PCR of the programs whose PCR_PID carries no elementary stream.
+// advanceClock sends the following ones.
+func (mx *Muxer) startDedicatedPCR() error {
+	for _, prog := range mx.programs {
+		if !prog.dedicatedPCR || prog.hasPCR {
+			continue
+		}
+		prog.lastPCR, prog.hasPCR = mx.clock, true
+		if err := mx.write(mx.pcrOnlyPacket(prog, mx.clock)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extendDTS returns DTS, or PTS without DTS, of the access unit extended beyond 33bit.
+func (mx *Muxer) extendDTS(au AccessUnit) (int64, bool) {
+	dts, ok := au.DTS, au.HasDTS
+	if !ok {
+		dts, ok = au.PTS, au.HasPTS
+	}
+	if !ok {
+		return 0, false
+	}
+	if !mx.hasDTS {
+		mx.extendedDTS, mx.hasDTS = int64(dts), true
+	} else {
+		mx.extendedDTS += ptsDiff(dts, mx.lastDTS)
+	}
+	mx.lastDTS = dts
+	return mx.extendedDTS, true
+}
+
+// clockTarget returns the system time clock PCRDelay before the last DTS.
+func (mx *Muxer) clockTarget() int64 {
+	return (mx.extendedDTS - durationToPTS(mx.PCRDelay)) * 300
+}
+
+// writePSI writes PAT and the PMTs.
+func (mx *Muxer) writePSI() error {
+	for _, b := range mx.psiPackets() {
+		if err := mx.write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// psiPackets returns the packets of PAT and the PMTs.
+func (mx *Muxer) psiPackets() [][]byte {
+	pat := PAT{TransportStreamID: mx.TransportStreamID, Version: mx.patVersion, CurrentNextIndicator: true}
+	for _, prog := range mx.programs {
+		pat.Programs = append(pat.Programs, PATProgram{ProgramNumber: prog.ProgramNumber, ProgramMapPID: prog.PMTPID})
+	}
+	packets := mx.sectionPackets(PID_PAT, pat.Encode())
+	for _, prog := range mx.programs {
+		pmt := PMT{ProgramNumber: prog.ProgramNumber, CurrentNextIndicator: true, PCR_PID: prog.PCRPID, Descriptors: prog.Descriptors}
+		for _, s := range prog.Streams {
+			pmt.Streams = append(pmt.Streams, StreamInfo{Type: s.Type, ElementaryPID: s.PID, Descriptors: s.Descriptors})
+		}
+		packets = append(packets, mx.sectionPackets(prog.PMTPID, pmt.Encode())...)
+	}
+	mx.lastPSI = mx.clock
+	mx.psiDue = false
+	return packets
+}
+
+func (mx *Muxer) sectionPackets(pid PID, section []byte) [][]byte {
+	return PacketizeSection(pid, section, func() byte { return mx.nextCC(pid, true) })
+}
+
+// PacketizeSection returns a section in packets with pointer_field in the first packet. The
+// rest of the last packet is filled with 0xff. nextCC gives continuity_counter of each packet.
+func PacketizeSection(pid PID, section []byte, nextCC func() byte) [][]byte {
+	packets := [][]byte{}
+	payload := append([]byte{0x00}, section...)
+	for first := true; len(payload) > 0; first = false {
+		b := make([]byte, PacketSizeDefault)
+		for i := range b {
+			b[i] = 0xff
+		}
+		writeTSHeader(b, pid, first, AdaptationField_PayloadOnly, nextCC())
+		n := copy(b[4:], payload)
+		payload = payload[n:]
+		packets = append(packets, b)
+	}
+	return packets
+}
+
+// pcrOnlyPacket returns an adaptation field only packet on PCR_PID of the program.
+func (mx *Muxer) pcrOnlyPacket(prog *muxerProgram, pcr int64) []byte {
+	af := muxerAdaptationField{hasPCR: true, pcr: pcr}
+	b, _ := buildTSPacket(prog.PCRPID, false, mx.nextCC(prog.PCRPID, false), af, nil)
+	return b
+}
+
+// nextCC returns continuity_counter of the next packet. It is incremented only by packets with payload.
+func (mx *Muxer) nextCC(pid PID, hasPayload bool) byte {
+	cc := mx.cc[pid]
+	if !hasPayload {
+		return (cc - 1) & 0x0f
+	}
+	mx.cc[pid] = (cc + 1) & 0x0f
+	return cc
+}
+
+func (mx *Muxer) write(b []byte) error {
+	_, err := mx.w.Write(b)
+	return err
+}
+
+// buildPES returns a PES packet of the access unit.
+// Rec. ITU-T H.222.0 (06/2021) pp.39-44
+func (s *muxerStream) buildPES(au AccessUnit, dataAlignment bool) ([]byte, error) {
+	header := []byte{}
+	flags := byte(0)
+	if au.HasPTS {
+		if au.HasDTS && au.DTS != au.PTS {
+			flags = 0x03
+			header = appendTimestamp(header, 0x03, au.PTS)
+			header = appendTimestamp(header, 0x01, au.DTS)
+		} else {
+			flags = 0x02
+			header = appendTimestamp(header, 0x02, au.PTS)
+		}
+	}
+	length := 3 + len(header) + len(au.Data)
+	if length > 0xffff {
+		if !s.Type.IsVideo() {
+			return nil, fmt.Errorf("PID 0x%04x: %w", s.PID, ErrMuxerPESTooLong)
+		}
+		// unbounded PES packet is allowed only for video elementary streams
+		length = 0
+	}
+	b := make([]byte, 0, 9+len(header)+len(au.Data))
+	b = append(b, 0x00, 0x00, 0x01, s.StreamID, byte(length>>8), byte(length))
+	b = append(b, 0x80|boolToByte(dataAlignment)<<2, flags<<6, byte(len(header)))
+	b = append(b, header...)
+	return append(b, au.Data...), nil
+}
+
+// appendTimestamp appends a 33bit timestamp with the 4bit prefix and marker bits. inverse of readTimestamp
+func appendTimestamp(b []byte, prefix byte, ts uint64) []byte {
+	return append(b,
+		prefix<<4|byte(ts>>29)&0x0e|0x01,
+		byte(ts>>22),
+		byte(ts>>14)|0x01,
+		byte(ts>>7),
+		byte(ts<<1)|0x01,
+	)
+}
+
+// appendPCR appends program_clock_reference_base, reserved bits and program_clock_reference_extension
+// of a 27MHz clock, which may be extended beyond 42bit or negative.
+func appendPCR(b []byte, pcr int64) []byte {
+	pcr %= pcrWrap
+	if pcr < 0 {
+		pcr += pcrWrap
+	}
+	base, ext := uint64(pcr/300), uint16(pcr%300)
+	return append(b, byte(base>>25), byte(base>>17), byte(base>>9), byte(base>>1), byte(base<<7)|0x7e|byte(ext>>8)&0x01, byte(ext))
+}
+
+type muxerAdaptationField struct {
+	randomAccess bool
+	hasPCR       bool
+	// in 27MHz, extended beyond 42bit
+	pcr int64
+}
+
+func writeTSHeader(b []byte, pid PID, pusi bool, afc byte, cc byte) {
+	b[0] = 0x47
+	b[1] = boolToByte(pusi)<<6 | byte(pid>>8)&0x1f
+	b[2] = byte(pid)
+	b[3] = afc<<4 | cc&0x0f
+}
+
+// buildTSPacket returns a 188 bytes packet with as much payload as fits and the number of the
+// payload bytes. A short payload is padded by stuffing bytes of the adaptation field.
+// Rec. ITU-T H.222.0 (06/2021) pp.22-29
+func buildTSPacket(pid PID, pusi bool, cc byte, af muxerAdaptationField, payload []byte) ([]byte, int) {
+	b := make([]byte, PacketSizeDefault)
+	body := []byte{}
+	if af.randomAccess || af.hasPCR {
+		flags := boolToByte(af.randomAccess)<<6 | boolToByte(af.hasPCR)<<4
+		body = append(body, flags)
+		if af.hasPCR {
+			body = appendPCR(body, af.pcr)
+		}
+	}
+
+	space := PacketSizeDefault - 4
+	hasAF := len(body) > 0 || len(payload) < space
+	if hasAF {
+		space -= 1 + len(body)
+	}
+	n := len(payload)
+	if n > space {
+		n = space
+	}
+	afc := byte(AdaptationField_PayloadOnly)
+	switch {
+	case hasAF && n == 0:
+		afc = AdaptationField_AdaptationFieldOnly
+	case hasAF:
+		afc = AdaptationField_AdaptationFieldFollowed
+	}
+	writeTSHeader(b, pid, pusi, afc, cc)
+	if hasAF {
+		// adaptation_field_length covers the flags, the fields and the stuffing bytes
+		afLength := PacketSizeDefault - 4 - 1 - n
+		b[4] = byte(afLength)
+		if afLength > 0 {
+			if len(body) == 0 {
+				// no flags
+				body = []byte{0x00}
+			}
+			copy(b[5:], body)
+			for i := 5 + len(body); i < 5+afLength; i++ {
+				b[i] = 0xff
+			}
+		}
+	}
+	copy(b[PacketSizeDefault-n:], payload[:n])
+	return b, n
+}
@@ -0,0 +1,234 @@
+package mpeg2ts
+
+import (
+	"fmt"
+)
+
+type DeliverySystem int
+
+const (
+	DeliverySystemUnknown DeliverySystem = iota
+	DeliverySystemSatellite
+	DeliverySystemCable
+	DeliverySystemTerrestrial
+	DeliverySystemISDBTerrestrial
+)
+
+// Network Information Table
+// ETSI EN 300 468 V1.17.1 pp.26-28, ARIB STD-B10 Part 2 p.13
+type NIT struct {
+	TableID                   byte   // 8
+	SectionSyntaxIndicator    bool   // 1
+	SectionLength             uint16 // 12
+	NetworkID                 uint16 // 16
+	Version                   byte   // 5
+	CurrentNextIndicator      bool   // 1
+	SectionNumber             byte   // 8
+	LastSectionNumber         byte   // 8
+	NetworkDescriptorsLength  uint16 // 12
+	Descriptors               []ProgramElementDescriptor
+	TransportStreamLoopLength uint16 // 12
+	TransportStreams          []NITTransportStream
+	CRC32                     uint // 32
+
+	// of the section, which TuningTable reads the delivery system descriptors with
+	siOptions SIOptions
+}
+
+type NITTransportStream struct {
+	TransportStreamID          uint16 // 16
+	OriginalNetworkID          uint16 // 16
+	TransportDescriptorsLength uint16 // 12
+	Descriptors                []ProgramElementDescriptor
+}
+
+// TuningEntry is the delivery system and the services of a transport stream announced in NIT.
+type TuningEntry struct {
+	NetworkID         uint16
+	NetworkName       string
+	TransportStreamID uint16
+	OriginalNetworkID uint16
+
+	DeliverySystem DeliverySystem
+	FrequenciesHz  []uint64
+	// satellite and cable. modulation_type of DVB-S/S2 or the 5 bit modulation of ARIB on satellite
+	SymbolRate uint64
+	Modulation uint8
+	FECInner   uint8
+	// satellite
+	Polarization          uint8
+	RollOff               uint8 // DVB
+	ModulationSystem      uint8 // DVB. 0: DVB-S, 1: DVB-S2
+	OrbitalPositionDegree float64
+	WestEastFlag          bool
+	// terrestrial
+	GuardInterval    uint8
+	TransmissionMode uint8
+	AreaCode         uint16
+
+	Services                   []ServiceListEntry
+	PartialReceptionServiceIDs []uint16
+	// TS_information_descriptor (ARIB)
+	RemoteControlKeyID uint8
+	TSName             string
+}
+
+// IsActualNetwork reports whether the table describes the network of the current transport stream.
+func (nit *NIT) IsActualNetwork() bool {
+	return nit.TableID == TableID_NetworkInformationSection_ActualNetwork
+}
+
+// NetworkName returns the name in network_name_descriptor.
+func (nit *NIT) NetworkName() string {
+	for _, d := range nit.Descriptors {
+		if d.Tag == 0x40 {
+			return d.NetworkNameDescriptor.NetworkName
+		}
+	}
+	return ""
+}
+
+func (s *Section) ParseNIT() (NIT, error) {
+	if s.TableID != TableID_NetworkInformationSection_ActualNetwork && s.TableID != TableID_NetworkInformationSection_OtherNetwork {
+		return NIT{}, fmt.Errorf("invalid TableID. expected: 0x40 or 0x41, actual: 0x%02x", s.TableID)
+	}
+	if err := s.CheckCRC(); err != nil {
+		return NIT{}, err
+	}
+	nit := NIT{}
+	nit.TableID = s.TableID
+	nit.SectionSyntaxIndicator = s.SectionSyntaxIndicator
+	nit.SectionLength = s.SectionLength
+	nit.NetworkID = s.TableIDExtension
+	nit.Version = s.Version
+	nit.CurrentNextIndicator = s.CurrentNextIndicator
+	nit.SectionNumber = s.SectionNumber
+	nit.LastSectionNumber = s.LastSectionNumber
+	nit.CRC32 = s.CRC32
+	nit.siOptions = s.SIOptions
+
+	data := s.Data
+	end := len(data) - 4
+	if end < 10 {
+		return NIT{}, ErrSectionTooShort
+	}
+	nit.NetworkDescriptorsLength = uint16(data[8]&0x0f)<<8 | uint16(data[9]) // 12
+	index := 10
+	if index+int(nit.NetworkDescriptorsLength)+2 > end {
+		return NIT{}, ErrSectionTooShort
+	}
+	var err error
+	var diff int
+	nit.Descriptors, diff, err = readDescriptor(data, index, int(nit.NetworkDescriptorsLength), s.SIOptions)
+	if err != nil {
+		return NIT{}, err
+	}
+	index += diff
+
+	nit.TransportStreamLoopLength = uint16(data[index]&0x0f)<<8 | uint16(data[index+1]) // 12
+	index += 2
+	loopEnd := index + int(nit.TransportStreamLoopLength)
+	if loopEnd > end {
+		return NIT{}, ErrSectionTooShort
+	}
+
+	for index+6 <= loopEnd {
+		ts := NITTransportStream{}
+		ts.TransportStreamID = uint16(data[index])<<8 | uint16(data[index+1])                 // 16
+		ts.OriginalNetworkID = uint16(data[index+2])<<8 | uint16(data[index+3])               // 16
+		ts.TransportDescriptorsLength = uint16(data[index+4]&0x0f)<<8 | uint16(data[index+5]) // 12
+		index += 6
+		if index+int(ts.TransportDescriptorsLength) > loopEnd {
+			return NIT{}, ErrSectionTooShort
+		}
+		ts.Descriptors, diff, err = readDescriptor(data, index, int(ts.TransportDescriptorsLength), s.SIOptions)
+		if err != nil {
+			return NIT{}, err
+		}
+		index += diff
+		nit.TransportStreams = append(nit.TransportStreams, ts)
+	}
+	return nit, nil
+}
+
+// TuningTable lists the transport streams of the NIT sections with their delivery parameters.
+// A transport stream which appears in several sections is merged into one entry.
+func TuningTable(nits ...NIT) []TuningEntry {
+	type tsKey struct {
+		networkID, onid, tsid uint16
+	}
+	entries := []TuningEntry{}
+	indexes := map[tsKey]int{}
+	names := map[uint16]string{}
+	for _, nit := range nits {
+		if name := nit.NetworkName(); name != "" {
+			names[nit.NetworkID] = name
+		}
+	}
+
+	for _, nit := range nits {
+		for _, ts := range nit.TransportStreams {
+			key := tsKey{nit.NetworkID, ts.OriginalNetworkID, ts.TransportStreamID}
+			i, ok := indexes[key]
+			if !ok {
+				entries = append(entries, TuningEntry{
+					NetworkID:         nit.NetworkID,
+					NetworkName:       names[nit.NetworkID],
+					TransportStreamID: ts.TransportStreamID,
+					OriginalNetworkID: ts.OriginalNetworkID,
+				})
+				i = len(entries) - 1
+				indexes[key] = i
+			}
+			e := &entries[i]
+			for _, d := range ts.Descriptors {
+				switch d.Tag {
+				case 0x41: // service_list_descriptor
+					e.Services = append(e.Services, d.ServiceListDescriptor.Services...)
+				case 0x43: // satellite_delivery_system_descriptor
+					sd := d.SatelliteDeliverySystemDescriptor
+					e.DeliverySystem = DeliverySystemSatellite
+					e.FrequenciesHz = []uint64{sd.FrequencyHz}
+					e.SymbolRate = sd.SymbolRateSymbolPerSec
+					if nit.siOptions.ARIB {
+						e.Modulation = sd.Modulation
+					} else {
+						e.Modulation = sd.ModulationType
+						e.RollOff = sd.RollOff
+						e.ModulationSystem = sd.ModulationSystem
+					}
+					e.FECInner = sd.FECInner
+					e.Polarization = sd.Polarization
+					e.OrbitalPositionDegree = sd.OrbitalPositionDegree
+					e.WestEastFlag = sd.WestEastFlag
+				case 0x44: // cable_delivery_system_descriptor
+					cd := d.CableDeliverySystemDescriptor
+					e.DeliverySystem = DeliverySystemCable
+					e.FrequenciesHz = []uint64{cd.FrequencyHz}
+					e.SymbolRate = cd.SymbolRateSymbolPerSec
+					e.Modulation = cd.Modulation
+					e.FECInner = cd.FECInner
+				case 0x5A: // terrestrial_delivery_system_descriptor
+					td := d.TerrestrialDeliverySystemDescriptor
+					e.DeliverySystem = DeliverySystemTerrestrial
+					e.FrequenciesHz = []uint64{td.FrequencyHz}
+					e.GuardInterval = td.GuardInterval
+					e.TransmissionMode = td.TransmissionMode
+				case 0xCD: // TS_information_descriptor
+					e.RemoteControlKeyID = d.TSInformationDescriptor.RemoteControlKeyID
+					e.TSName = d.TSInformationDescriptor.TSName
+				case 0xFA: // terrestrial_delivery_system_descriptor (ISDB-T)
+					td := d.ISDBTerrestrialDeliverySystemDescriptor
+					e.DeliverySystem = DeliverySystemISDBTerrestrial
+					e.FrequenciesHz = td.FrequenciesHz
+					e.GuardInterval = td.GuardInterval
+					e.TransmissionMode = td.TransmissionMode
+					e.AreaCode = td.AreaCode
+				case 0xFB: // partial_reception_descriptor
+					e.PartialReceptionServiceIDs = append(e.PartialReceptionServiceIDs, d.PartialReceptionDescriptor.ServiceIDs...)
+				}
+			}
+		}
+	}
+	return entries
+}
@@ -51,6 +51,12 @@ const (
 	TableID_SelectionInformationSection                                      = 0x7F
 	TableID_UserDefinedMin                                                   = 0x80
 	TableID_UserDefinedMax                                                   = 0xFE
+
+	// ARIB STD-B10 Part 2 p.5
+	TableID_SoftwareDownloadTriggerSection = 0xC3
+	TableID_BroadcasterInformationSection  = 0xC4
+	TableID_CommonDataSection              = 0xC8
+	TableID_LocalEventInformationSection   = 0xD0
 	// TableID_Reserved                                                      = 0x04
 	// ...
 	// TableID_Reserved                                                      = 0x3F
@@ -125,6 +131,20 @@ func (ps *PacketList) AddBytes(packetBytes []byte, packetSize int) error {
 	return nil
 }
 
+// ParsePacket returns a Packet of a copy of the 188 bytes, which is the packet at the index of
+// its stream.
+func ParsePacket(packetBytes []byte, index int) (Packet, error) {
+	if len(packetBytes) != PacketSizeDefault {
+		return Packet{}, fmt.Errorf("invalid data size %d", len(packetBytes))
+	}
+	p := Packet{Index: index, Data: make([]byte, PacketSizeDefault)}
+	copy(p.Data, packetBytes)
+	if err := p.parseHeader(); err != nil {
+		return Packet{}, err
+	}
+	return p, nil
+}
+
 func (p *Packet) GetHeader() ([]byte, error) {
 	if p.Data == nil || len(p.Data) != PacketSizeDefault {
 		return nil, fmt.Errorf("invalid header")
@@ -183,7 +203,7 @@ func (p *Packet) parseHeader() error {
 			}
 		}
 
-		if p.AdaptationField.Length == 0 {
+		if af.Length == 0 {
 			p.AdaptationField = af
 			p.isHeaderParsed = true
 			return nil
@@ -230,12 +250,13 @@ func (p *Packet) parseHeader() error {
 			// 	private_data_byte 8 bslbf
 			// }
 			af.TransportPrivateData.Length = p.Data[fieldIndex]
+			if fieldIndex+1+int(af.TransportPrivateData.Length) > 5+int(af.Length) {
+				return fmt.Errorf("transport_private_data_length %d exceeds adaptation field", af.TransportPrivateData.Length)
+			}
 			af.TransportPrivateData.Data = p.Data[fieldIndex+1 : fieldIndex+1+int(af.TransportPrivateData.Length)]
 			fieldIndex += 1 + int(af.TransportPrivateData.Length)
 		}
 		if af.ExtensionFlag {
-			fmt.Println("[BUG] AdaptationFieldExtension parsing is not implemented")
-			fmt.Printf("af: %#v\n", af)
 			// adaptation_field_extension_length 8 uimsbf
 			// ltw_flag 1 bslbf
 			// piecewise_rate_flag 1 bslbf
@@ -269,12 +290,19 @@ func (p *Packet) parseHeader() error {
 			// 		reserved 8 bslbf
 			// 	}
 			// }
+			// the extension is skipped for now
+			if fieldIndex >= 5+int(af.Length) {
+				return fmt.Errorf("adaptation_field_extension_length exceeds adaptation field")
+			}
+			af.ExtensionLength = p.Data[fieldIndex]
+			fieldIndex += 1 + int(af.ExtensionLength)
 		}
 
-		// TODO: nokori
-		readedAFBytes := fieldIndex - 6 + 2
-		if int(af.Length) > 0 && readedAFBytes < int(af.Length) {
-			af.Stuffing = p.Data[6+readedAFBytes : 5+int(af.Length)]
+		if fieldIndex > 5+int(af.Length) {
+			return fmt.Errorf("adaptation field fields (%d bytes) exceed adaptation_field_length %d", fieldIndex-5, af.Length)
+		}
+		if fieldIndex < 5+int(af.Length) {
+			af.Stuffing = p.Data[fieldIndex : 5+int(af.Length)]
 			for i, v := range af.Stuffing {
 				if v != 0xff {
 					return fmt.Errorf("[BUG] stuffing bytes contains non-0xff byte. data:0x%02x index:%d", v, i)
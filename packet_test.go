@@ -0,0 +1,63 @@
+package mpeg2ts
+
+import (
+	"bytes"
+	"testing"
+)
+
+// packetFields returns the parsed fields of the packets without Data.
+func packetFields(m *MPEG2TS) []Packet {
+	packets := []Packet{}
+	for _, p := range m.PacketList.All() {
+		p.Data = nil
+		packets = append(packets, p)
+	}
+	return packets
+}
+
+func TestAdaptationField(t *testing.T) {
+	m := loadFixture(t, "adaptation.ts", fixtureAdaptationFields)
+	checkGolden(t, "adaptation", packetFields(m))
+
+	packets := m.PacketList.All()
+	if len(packets) != 8 {
+		t.Fatalf("%d packets, want 8", len(packets))
+	}
+	tests := []struct {
+		name    string
+		index   int
+		payload int
+		check   func(af AdaptationField) bool
+	}{
+		{"adaptation field only", 0, 0, func(af AdaptationField) bool { return af.Length == 183 && len(af.Stuffing) == 182 }},
+		{"zero length", 1, 183, func(af AdaptationField) bool { return af.Length == 0 }},
+		{"PCR", 2, 100, func(af AdaptationField) bool {
+			return af.DiscontinuityIndicator && af.RandomAccessIndicator && af.ESPriorityIndicator && pcrValue(af.ProgramClockReference) == 0x1_2345_6789*300+299
+		}},
+		{"OPCR", 3, 160, func(af AdaptationField) bool {
+			return pcrValue(af.ProgramClockReference) == 900000*300+1 && pcrValue(af.OriginalProgramClockReference) == 450000*300+2
+		}},
+		{"splice countdown", 4, 170, func(af AdaptationField) bool { return af.SplicingPointFlag && int8(af.SpliceCountdown) == -2 }},
+		{"private data", 5, 150, func(af AdaptationField) bool { return string(af.TransportPrivateData.Data) == "PRIV" }},
+		{"extension", 6, 170, func(af AdaptationField) bool {
+			return af.ExtensionFlag && af.ExtensionLength == 3 && len(af.Stuffing) == 8
+		}},
+		{"every field", 7, 0, func(af AdaptationField) bool {
+			return af.SplicingPointFlag && af.SpliceCountdown == 3 && bytes.Equal(af.TransportPrivateData.Data, []byte{0xaa}) && af.ExtensionLength == 1
+		}},
+	}
+	for _, tt := range tests {
+		p := packets[tt.index]
+		payload, err := p.GetPayload()
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(payload) != tt.payload {
+			t.Errorf("%s: %d bytes of payload, want %d", tt.name, len(payload), tt.payload)
+		}
+		if !tt.check(p.AdaptationField) {
+			t.Errorf("%s: unexpected adaptation field %+v", tt.name, p.AdaptationField)
+		}
+	}
+}
@@ -29,11 +29,24 @@ type PATProgram struct {
 }
 
 func (p *Packet) ParsePAT() (PAT, error) {
-	pat := PAT{}
 	payload, err := p.GetPayload()
 	if err != nil {
 		return PAT{}, err
 	}
+	return parsePAT(payload)
+}
+
+// ParsePAT parses a section which has been reassembled by SectionAssembler.
+func (s *Section) ParsePAT() (PAT, error) {
+	return parsePAT(append([]byte{0}, s.Data...))
+}
+
+// parsePAT parses a section which follows pointer_field in payload.
+func parsePAT(payload []byte) (PAT, error) {
+	pat := PAT{}
+	if len(payload) < 4 {
+		return PAT{}, ErrSectionTooShort
+	}
 	pat.Pointer = payload[0]
 	pat.TableID = payload[1]
 	pat.SectionSyntaxIndicator = ((payload[2] >> 7) & 0x01) == 1
@@ -41,6 +54,9 @@ func (p *Packet) ParsePAT() (PAT, error) {
 		return PAT{}, fmt.Errorf("invalid format")
 	}
 	pat.SectionLength = uint16(payload[2]&0x0F)<<8 | uint16(payload[3])
+	if pat.SectionLength < 9 || int(pat.SectionLength)+4 > len(payload) {
+		return PAT{}, fmt.Errorf("invalid section_length %d", pat.SectionLength)
+	}
 	pat.TransportStreamID = uint16(payload[4])<<8 | uint16(payload[5])
 	pat.Version = (payload[6] >> 1) & 0x1F
 	pat.CurrentNextIndicator = (payload[6] & 0x01) == 0x01
@@ -67,3 +83,37 @@ func (p *Packet) ParsePAT() (PAT, error) {
 	// fmt.Println("CRC OK")
 	return pat, nil
 }
+
+// Encode returns the section from table_id to CRC_32. section_length and CRC_32 are
+// calculated from Programs.
+// Rec. ITU-T H.222.0 (06/2021) pp.53-54
+func (pat *PAT) Encode() []byte {
+	b := []byte{TableID_ProgramAssociationSection, 0, 0}
+	b = append(b, byte(pat.TransportStreamID>>8), byte(pat.TransportStreamID))
+	b = append(b, 0xc0|(pat.Version&0x1f)<<1|boolToByte(pat.CurrentNextIndicator), pat.SectionNumber, pat.LastSectionNumber)
+	for _, prog := range pat.Programs {
+		pid := prog.ProgramMapPID
+		if prog.ProgramNumber == 0x0000 {
+			pid = prog.NetworkPID
+		}
+		b = append(b, byte(prog.ProgramNumber>>8), byte(prog.ProgramNumber), 0xe0|byte(pid>>8)&0x1f, byte(pid))
+	}
+	return finishSection(b)
+}
+
+// finishSection fills section_syntax_indicator and section_length of a long form section
+// and appends CRC_32.
+func finishSection(b []byte) []byte {
+	length := len(b) - 3 + 4
+	b[1] = 0xb0 | byte(length>>8)&0x0f
+	b[2] = byte(length)
+	crc := calculateCRC(b)
+	return append(b, byte(crc>>24), byte(crc>>16), byte(crc>>8), byte(crc))
+}
+
+func boolToByte(v bool) byte {
+	if v {
+		return 1
+	}
+	return 0
+}
@@ -73,11 +73,11 @@ type PES struct {
 	CRCFlag                bool
 	ExtensionFlag          bool
 	HeaderDataLength       byte
-	rawPTS                 uint32
-	rawDTS                 uint32
+	rawPTS                 uint64
+	rawDTS                 uint64
 	PTS                    float64
 	DTS                    float64
-	ESCRBase               uint32
+	ESCRBase               uint64
 	ESCRExtension          uint16
 	ESRate                 uint32
 	ElementaryStream       []byte
@@ -86,6 +86,16 @@ type PES struct {
 	// DSM_trick_mode_flag == 1
 }
 
+// RawPTS returns PTS in 90kHz.
+func (pes *PES) RawPTS() uint64 {
+	return pes.rawPTS
+}
+
+// RawDTS returns DTS in 90kHz.
+func (pes *PES) RawDTS() uint64 {
+	return pes.rawDTS
+}
+
 type PESParser struct {
 	packetCount      int
 	buffer           []PESByte
@@ -162,7 +172,7 @@ func (pp *PESParser) findPrefix() bool {
 }
 
 func (pp *PESParser) readPaddingBytes() bool {
-	if pp.getBufferLength() > int(pp.PES.PacketLength) {
+	if pp.getBufferLength() < int(pp.PES.PacketLength) {
 		// not enough buffer
 		return false
 	}
@@ -193,29 +203,41 @@ func (pp *PESParser) parseOptionalPESHeaders() {
 	pp.PES.HeaderDataLength = pp.buffer[2].Datum
 
 	// PES header
-	trimIndex := 2
+	fieldIndex := 3
 	if pp.PTSFlag {
 		// if (PTS_DTS_flags == '10') {
-		pp.PES.rawPTS = uint32((pp.buffer[3].Datum>>1)&0x07)<<30 | uint32(pp.buffer[4].Datum)<<22 | uint32(pp.buffer[5].Datum>>1)<<15 | uint32(pp.buffer[6].Datum)<<7 | uint32(pp.buffer[7].Datum>>1)
+		pp.PES.rawPTS = uint64((pp.buffer[3].Datum>>1)&0x07)<<30 | uint64(pp.buffer[4].Datum)<<22 | uint64(pp.buffer[5].Datum>>1)<<15 | uint64(pp.buffer[6].Datum)<<7 | uint64(pp.buffer[7].Datum>>1)
 		pp.PES.PTS = float64(pp.PES.rawPTS) / 90000 // 90kHz
-		trimIndex += 5
+		fieldIndex += 5
 	}
 	if pp.DTSFlag {
 		// if (PTS_DTS_flags == '11') {
-		pp.PES.rawDTS = uint32((pp.buffer[8].Datum>>1)&0x07)<<30 | uint32(pp.buffer[9].Datum)<<22 | uint32(pp.buffer[10].Datum>>1)<<15 | uint32(pp.buffer[11].Datum)<<7 | uint32(pp.buffer[12].Datum>>1)
+		pp.PES.rawDTS = uint64((pp.buffer[8].Datum>>1)&0x07)<<30 | uint64(pp.buffer[9].Datum)<<22 | uint64(pp.buffer[10].Datum>>1)<<15 | uint64(pp.buffer[11].Datum)<<7 | uint64(pp.buffer[12].Datum>>1)
 		pp.PES.DTS = float64(pp.PES.rawDTS) / 90000 // 90kHz
-		trimIndex += 5
+		fieldIndex += 5
+	}
+	if pp.ESCRFlag && fieldIndex+6 <= 3+int(pp.PES.HeaderDataLength) {
+		// reserved 2, ESCR_base[32..30] 3, marker_bit 1, ESCR_base[29..15] 15, marker_bit 1,
+		// ESCR_base[14..0] 15, marker_bit 1, ESCR_extension 9, marker_bit 1
+		b := make([]byte, 6)
+		for i := range b {
+			b[i] = pp.buffer[fieldIndex+i].Datum
+		}
+		pp.PES.ESCRBase = uint64(b[0]>>3&0x07)<<30 | uint64(b[0]&0x03)<<28 | uint64(b[1])<<20 | uint64(b[2]>>3)<<15 | uint64(b[2]&0x03)<<13 | uint64(b[3])<<5 | uint64(b[4]>>3)
+		pp.PES.ESCRExtension = uint16(b[4]&0x03)<<7 | uint16(b[5]>>1)
+		fieldIndex += 6
 	}
-	if pp.ESCRFlag {
-		// pp.ESCRBase = pp.buffer
+	if pp.ESRateFlag && fieldIndex+3 <= 3+int(pp.PES.HeaderDataLength) {
+		// marker_bit 1, ES_rate 22, marker_bit 1
+		pp.PES.ESRate = uint32(pp.buffer[fieldIndex].Datum&0x7f)<<15 | uint32(pp.buffer[fieldIndex+1].Datum)<<7 | uint32(pp.buffer[fieldIndex+2].Datum>>1)
 	}
-	// ESRate
 	// DSMtrick
 	// additionalCopyInfo
 	// CRC
 	// PES extension
 
-	pp.dequeue(trimIndex + 1)
+	// the fields which are not parsed and stuffing bytes are skipped by PES_header_data_length
+	pp.dequeue(3 + int(pp.PES.HeaderDataLength))
 }
 
 func (pp *PESParser) StartPESReadLoop(ctx context.Context) <-chan PES {
@@ -264,6 +286,10 @@ func (pp *PESParser) StartPESReadLoop(ctx context.Context) <-chan PES {
 							state = StateFindPrefix
 							break ReadLoop
 						}
+						if pp.getBufferLength() < 3+int(pp.buffer[2].Datum) {
+							// not enough buffer for PES_header_data_length
+							break ReadLoop
+						}
 
 						pp.parseOptionalPESHeaders()
 						state = StateReadPacket
@@ -327,13 +353,13 @@ func (pp *PESParser) StartPESReadLoop(ctx context.Context) <-chan PES {
 						pp.PES.PacketDataStream[i] = v.Datum
 					}
 					pp.mutex.Unlock()
-					fmt.Println(pp.PES.PacketLength)
 					pp.dequeue(int(pp.PES.PacketLength))
 					state = StateFindPrefix
 				case StateReadPaddingBytes:
-					if ok := pp.readPaddingBytes(); ok {
-						state = StateFindPrefix
+					if ok := pp.readPaddingBytes(); !ok {
+						break ReadLoop
 					}
+					state = StateFindPrefix
 
 				}
 			}
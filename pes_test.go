@@ -0,0 +1,110 @@
+package mpeg2ts
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+// parsePES feeds the packets to a PESParser and returns the PES packets. The last packet is
+// enqueued by EnqueueLastTSPacket.
+func parsePES(t *testing.T, packets []Packet) []PES {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	pp := NewPESParser(1 << 20)
+	c := pp.StartPESReadLoop(ctx)
+	for i, p := range packets {
+		var err error
+		if i == len(packets)-1 {
+			err = pp.EnqueueLastTSPacket(p)
+		} else {
+			err = pp.EnqueueTSPacket(p)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	pes := []PES{}
+	for p := range c {
+		pes = append(pes, p)
+	}
+	if ctx.Err() != nil {
+		t.Fatal("PESParser did not stop at the last packet")
+	}
+	return pes
+}
+
+func TestPESParser(t *testing.T) {
+	m := loadFixture(t, "pes.ts", fixturePESStream)
+	entries := fixturePESEntries()
+	for _, pid := range []PID{fixtureVideoPID, fixtureAudioPID} {
+		pes := parsePES(t, m.FilterByPIDs(pid).PacketList.All())
+		want := []fixturePESEntry{}
+		for _, e := range entries {
+			if e.pid == pid {
+				want = append(want, e)
+			}
+		}
+		// the last one is not emitted
+		want = want[:len(want)-1]
+		if len(pes) != len(want) {
+			t.Fatalf("PID 0x%04x: %d PES packets, want %d", pid, len(pes), len(want))
+		}
+		for i, e := range want {
+			headerLength := 9 + int(e.pes[8])
+			if !bytes.Equal(pes[i].ElementaryStream, e.pes[headerLength:]) {
+				t.Errorf("PID 0x%04x PES %d: elementary stream is %x", pid, i, pes[i].ElementaryStream)
+			}
+		}
+		if pid == fixtureVideoPID {
+			checkGolden(t, "pes_video", pes)
+		} else {
+			checkGolden(t, "pes_audio", pes)
+		}
+	}
+}
+
+func TestPESHeaderFields(t *testing.T) {
+	m := loadFixture(t, "pes.ts", fixturePESStream)
+	pes := parsePES(t, m.FilterByPIDs(fixtureVideoPID).PacketList.All())
+	if len(pes) != 3 {
+		t.Fatalf("%d PES packets, want 3", len(pes))
+	}
+	p := pes[2]
+	if !p.Priority || !p.DataAlignment || !p.Copyright || !p.Original || p.ScramblingControl != 0 {
+		t.Errorf("unexpected flags %+v", p)
+	}
+	if !p.PTSFlag || !p.DTSFlag || !p.ESCRFlag || !p.ESRateFlag || !p.DSMTrickModeFlag || !p.AdditionalCopyInfoFlag || !p.CRCFlag || !p.ExtensionFlag {
+		t.Errorf("unexpected flags %+v", p)
+	}
+	if p.RawPTS() != 0x1_0000_0000+183000 || p.RawDTS() != 180000 {
+		t.Errorf("PTS %d, DTS %d", p.RawPTS(), p.RawDTS())
+	}
+	if p.ESCRBase != 123456789 || p.ESCRExtension != 257 || p.ESRate != 50000 {
+		t.Errorf("ESCR %d/%d, ES_rate %d", p.ESCRBase, p.ESCRExtension, p.ESRate)
+	}
+
+	// the timestamps are read from a packet without PESParser
+	found := false
+	for _, p := range m.FilterByPIDs(fixtureVideoPID).PacketList.All() {
+		if !p.PayloadUnitStartIndicator {
+			continue
+		}
+		es, ts, err := p.readPESHeader()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ts.DTS != 180000 {
+			continue
+		}
+		found = true
+		if ts.PTS != 0x1_0000_0000+183000 || !bytes.Equal(es, fixturePayload(len(es), 0x70)) {
+			t.Errorf("unexpected PES header of packet %d: %+v", p.Index, ts)
+		}
+	}
+	if !found {
+		t.Error("no PES header has DTS 180000")
+	}
+}
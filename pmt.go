@@ -7,60 +7,60 @@ import (
 type StreamType byte
 
 const (
-	StreamTypeReserved                   = StreamType(0x00) // ITU-T | ISO/IEC Reserved
-	StreamTypeISO11172_2_Video           = StreamType(0x01) // ISO/IEC 11172-2 Video
-	StreamTypeISO13818_2_Video           = StreamType(0x02) // Rec. ITU-T H.262 | ISO/IEC 13818-2 Video or ISO/IEC 11172-2 constrained parameter video stream
-	StreamTypeISO11172_3_Audio           = StreamType(0x03) // ISO/IEC 11172-3 Audio
-	StreamTypeISO13818_3_Audio           = StreamType(0x04) // ISO/IEC 13818-3 Audio
-	StreamTypeISO13818_1_PrivateSections = StreamType(0x05) // Rec. ITU-T H.222.0 | ISO/IEC 13818-1 private_sections
-	StreamTypeISO13818_1_PES             = StreamType(0x06) // Rec. ITU-T H.222.0 | ISO/IEC 13818-1 PES packets containing private data
-	StreamTypeISO13522_MHEG              = StreamType(0x07) // ISO/IEC 13522 MHEG
-	StreamTypeISO13818_AnnexA            = StreamType(0x08) // Rec. ITU-T H.222.0 | ISO/IEC 13818-1 Annex A DSM-CC
-	StreamTypeH222_1                     = StreamType(0x09) // Rec. ITU-T H.222.1
-	StreamTypeISO13818_6_TypeA           = StreamType(0x0A) // ISO/IEC 13818-6 type A
-	StreamTypeISO13818_6_TypeB           = StreamType(0x0B) // ISO/IEC 13818-6 type B
-	StreamTypeISO13818_6_TypeC           = StreamType(0x0C) // ISO/IEC 13818-6 type C
-	StreamTypeISO13818_6_TypeD           = StreamType(0x0D) // ISO/IEC 13818-6 type D
-	StreamTypeISO13818_1_Aux             = StreamType(0x0E) // Rec. ITU-T H.222.0 | ISO/IEC 13818-1 auxiliary
-	StreamTypeISO13818_7_AudioWithADTS   = StreamType(0x0F) // ISO/IEC 13818-7 Audio with ADTS transport syntax
-	StreamTypeISO14496_2_Visual          = StreamType(0x10) // ISO/IEC 14496-2 Visual
-	StreamTypeISO14496_3_AudioWithLATM   = StreamType(0x11) // ISO/IEC 14496-3 Audio with the LATM transport syntax as defined in ISO/IEC 14496-3
-	StreamTypeISO14496_1_PES             = StreamType(0x12) // ISO/IEC 14496-1 SL-packetized stream or FlexMux stream carried in PES packets
-	StreamTypeISO14496_1_Sections        = StreamType(0x13) // ISO/IEC 14496-1 SL-packetized stream or FlexMux stream carried in ISO/IEC 14496_sections
-	StreamTypeISO13818_6_SDP             = StreamType(0x14) // ISO/IEC 13818-6 Synchronized Download Protocol
-	StreamTypeMetadataInPES              = StreamType(0x15) // Metadata carried in PES packets
-	StreamTypeMetadataInSections         = StreamType(0x16) // Metadata carried in metadata_sections
-	StreamTypeMetadataInDataCarousel     = StreamType(0x17) // Metadata carried in ISO/IEC 13818-6 Data Carousel
-	StreamTypeMetadataInObjectCarousel   = StreamType(0x18) // Metadata carried in ISO/IEC 13818-6 Object Carousel
-	StreamTypeMetadataInSDP              = StreamType(0x19) // Metadata carried in ISO/IEC 13818-6 Synchronized Download Protocol
-	StreamTypeIPMP                       = StreamType(0x1A) // IPMP stream (defined in ISO/IEC 13818-11, MPEG-2 IPMP)
-	StreamTypeAVC                        = StreamType(0x1B) // AVC video stream conforming to one or more profiles defined in Annex A of Rec. ITU-T H.264 |
-	StreamTypeISO14496_3_Audio           = StreamType(0x1C) // ISO/IEC 14496-3 Audio, without using any additional transport syntax, such as DST, ALS and SLS
-	StreamTypeISO14496_17_Text           = StreamType(0x1D) // ISO/IEC 14496-17 Text
-	StreamTypeISO23002_3_AuxVideo        = StreamType(0x1E) // Auxiliary video stream as defined in ISO/IEC 23002-3
-	StreamTypeISO14496_10_SVC            = StreamType(0x1F) // SVC video sub-bitstream of an AVC video stream conforming to one or more profiles defined in Annex G of Rec. ITU-T H.264 | ISO/IEC 14496-10
-	StreamTypeISO14496_10_MVC            = StreamType(0x20) // MVC video sub-bitstream of an AVC video stream conforming to one or more profiles defined in Annex H of Rec. ITU-T H.264 | ISO/IEC 14496-10
-	// StreamType       = StreamType(0x21) // Video stream conforming to one or more profiles as defined in Rec. ITU-T T.800 | ISO/IEC 15444-1
-	// StreamType       = StreamType(0x22) // Additional view Rec. ITU-T H.262 | ISO/IEC 13818-2 video stream for service-compatible stereoscopic 3D services (see Notes 3 and 4)
-	// StreamType       = StreamType(0x23) // Additional view Rec. ITU-T H.264 | ISO/IEC 14496-10 video stream conforming to one or more profiles defined in Annex A for service-compatible stereoscopic 3D services (see Notes 3 and 4)
-	// StreamType       = StreamType(0x24) // Rec. ITU-T H.265 | ISO/IEC 23008-2 video stream or an HEVC temporal video sub-bitstream
-	// StreamType       = StreamType(0x25) // HEVC temporal video subset of an HEVC video stream conforming to one or more profiles defined in Annex A of Rec. ITU-T H.265 | ISO/IEC 23008-2
-	// StreamType       = StreamType(0x26) // MVCD video sub-bitstream of an AVC video stream conforming to one or more profiles defined in Annex I of Rec. ITU-T H.264 | ISO/IEC 14496-10
-	// StreamType       = StreamType(0x27) // Timeline and External Media Information Stream (see Annex U)
-	// StreamType       = StreamType(0x28) // HEVC enhancement sub-partition which includes TemporalId 0 of an HEVC video stream where all NALs units contained in the stream conform to one or more profiles defined in Annex G of Rec. ITU-T H.265 | ISO/IEC 23008-2
-	// StreamType       = StreamType(0x29) // HEVC temporal enhancement sub-partition of an HEVC video stream where all NAL units contained in the stream conform to one or more profiles defined in Annex G of Rec. ITU-T H.265 | ISO/IEC 23008-2
-	// StreamType       = StreamType(0x2A) // HEVC enhancement sub-partition which includes TemporalId 0 of an HEVC video stream where all NAL units contained in the stream conform to one or more profiles defined in Annex H of Rec. ITU-T H.265 | ISO/IEC 23008-2
-	// StreamType       = StreamType(0x2B) // HEVC temporal enhancement sub-partition of an HEVC video stream where all NAL units contained in the stream conform to one or more profiles defined in Annex H of Rec. ITU-T H.265 | ISO/IEC 23008-2
-	// StreamType       = StreamType(0x2C) // Green access units carried in MPEG-2 sections
-	// StreamType       = StreamType(0x2D) // ISO/IEC 23008-3 Audio with MHAS transport syntax – main stream
-	// StreamType       = StreamType(0x2E) // ISO/IEC 23008-3 Audio with MHAS transport syntax – auxiliary stream
-	// StreamType       = StreamType(0x2F) // Quality access units carried in sections
-	// StreamType       = StreamType(0x30) // Media Orchestration Access Units carried in sections
-	// StreamType       = StreamType(0x31) // Substream of a Rec. ITU-T H.265 | ISO/IEC 23008 2 video stream that contains a Motion Constrained Tile Set, parameter sets, slice headers or a combination thereof. See 2.17.5.1.
-	// StreamType       = StreamType(0x32) // JPEG XS video stream conforming to one or more profiles as defined in ISO/IEC 21122-2
-	// StreamType       = StreamType(0x33) // VVC video stream or a VVC temporal video sub-bitstream conforming to one or more profiles defined in Annex A of Rec. ITU-T H.266 | ISO/IEC 23090-3
-	// StreamType       = StreamType(0x34) // VVC temporal video subset of a VVC video stream conforming to one or more profiles defined in Annex A of Rec. ITU-T H.266 | ISO/IEC 23090-3
-	// StreamType       = StreamType(0x35) // EVC video stream or an EVC temporal video sub-bitstream conforming to one or more profiles defined in ISO/IEC 23094-1
+	StreamTypeReserved                        = StreamType(0x00) // ITU-T | ISO/IEC Reserved
+	StreamTypeISO11172_2_Video                = StreamType(0x01) // ISO/IEC 11172-2 Video
+	StreamTypeISO13818_2_Video                = StreamType(0x02) // Rec. ITU-T H.262 | ISO/IEC 13818-2 Video or ISO/IEC 11172-2 constrained parameter video stream
+	StreamTypeISO11172_3_Audio                = StreamType(0x03) // ISO/IEC 11172-3 Audio
+	StreamTypeISO13818_3_Audio                = StreamType(0x04) // ISO/IEC 13818-3 Audio
+	StreamTypeISO13818_1_PrivateSections      = StreamType(0x05) // Rec. ITU-T H.222.0 | ISO/IEC 13818-1 private_sections
+	StreamTypeISO13818_1_PES                  = StreamType(0x06) // Rec. ITU-T H.222.0 | ISO/IEC 13818-1 PES packets containing private data
+	StreamTypeISO13522_MHEG                   = StreamType(0x07) // ISO/IEC 13522 MHEG
+	StreamTypeISO13818_AnnexA                 = StreamType(0x08) // Rec. ITU-T H.222.0 | ISO/IEC 13818-1 Annex A DSM-CC
+	StreamTypeH222_1                          = StreamType(0x09) // Rec. ITU-T H.222.1
+	StreamTypeISO13818_6_TypeA                = StreamType(0x0A) // ISO/IEC 13818-6 type A
+	StreamTypeISO13818_6_TypeB                = StreamType(0x0B) // ISO/IEC 13818-6 type B
+	StreamTypeISO13818_6_TypeC                = StreamType(0x0C) // ISO/IEC 13818-6 type C
+	StreamTypeISO13818_6_TypeD                = StreamType(0x0D) // ISO/IEC 13818-6 type D
+	StreamTypeISO13818_1_Aux                  = StreamType(0x0E) // Rec. ITU-T H.222.0 | ISO/IEC 13818-1 auxiliary
+	StreamTypeISO13818_7_AudioWithADTS        = StreamType(0x0F) // ISO/IEC 13818-7 Audio with ADTS transport syntax
+	StreamTypeISO14496_2_Visual               = StreamType(0x10) // ISO/IEC 14496-2 Visual
+	StreamTypeISO14496_3_AudioWithLATM        = StreamType(0x11) // ISO/IEC 14496-3 Audio with the LATM transport syntax as defined in ISO/IEC 14496-3
+	StreamTypeISO14496_1_PES                  = StreamType(0x12) // ISO/IEC 14496-1 SL-packetized stream or FlexMux stream carried in PES packets
+	StreamTypeISO14496_1_Sections             = StreamType(0x13) // ISO/IEC 14496-1 SL-packetized stream or FlexMux stream carried in ISO/IEC 14496_sections
+	StreamTypeISO13818_6_SDP                  = StreamType(0x14) // ISO/IEC 13818-6 Synchronized Download Protocol
+	StreamTypeMetadataInPES                   = StreamType(0x15) // Metadata carried in PES packets
+	StreamTypeMetadataInSections              = StreamType(0x16) // Metadata carried in metadata_sections
+	StreamTypeMetadataInDataCarousel          = StreamType(0x17) // Metadata carried in ISO/IEC 13818-6 Data Carousel
+	StreamTypeMetadataInObjectCarousel        = StreamType(0x18) // Metadata carried in ISO/IEC 13818-6 Object Carousel
+	StreamTypeMetadataInSDP                   = StreamType(0x19) // Metadata carried in ISO/IEC 13818-6 Synchronized Download Protocol
+	StreamTypeIPMP                            = StreamType(0x1A) // IPMP stream (defined in ISO/IEC 13818-11, MPEG-2 IPMP)
+	StreamTypeAVC                             = StreamType(0x1B) // AVC video stream conforming to one or more profiles defined in Annex A of Rec. ITU-T H.264 |
+	StreamTypeISO14496_3_Audio                = StreamType(0x1C) // ISO/IEC 14496-3 Audio, without using any additional transport syntax, such as DST, ALS and SLS
+	StreamTypeISO14496_17_Text                = StreamType(0x1D) // ISO/IEC 14496-17 Text
+	StreamTypeISO23002_3_AuxVideo             = StreamType(0x1E) // Auxiliary video stream as defined in ISO/IEC 23002-3
+	StreamTypeISO14496_10_SVC                 = StreamType(0x1F) // SVC video sub-bitstream of an AVC video stream conforming to one or more profiles defined in Annex G of Rec. ITU-T H.264 | ISO/IEC 14496-10
+	StreamTypeISO14496_10_MVC                 = StreamType(0x20) // MVC video sub-bitstream of an AVC video stream conforming to one or more profiles defined in Annex H of Rec. ITU-T H.264 | ISO/IEC 14496-10
+	StreamTypeISO15444_1_Video                = StreamType(0x21) // Video stream conforming to one or more profiles as defined in Rec. ITU-T T.800 | ISO/IEC 15444-1
+	StreamTypeISO13818_2_AdditionalView       = StreamType(0x22) // Additional view Rec. ITU-T H.262 | ISO/IEC 13818-2 video stream for service-compatible stereoscopic 3D services (see Notes 3 and 4)
+	StreamTypeAVC_AdditionalView              = StreamType(0x23) // Additional view Rec. ITU-T H.264 | ISO/IEC 14496-10 video stream conforming to one or more profiles defined in Annex A for service-compatible stereoscopic 3D services (see Notes 3 and 4)
+	StreamTypeHEVC                            = StreamType(0x24) // Rec. ITU-T H.265 | ISO/IEC 23008-2 video stream or an HEVC temporal video sub-bitstream
+	StreamTypeHEVC_TemporalSubset             = StreamType(0x25) // HEVC temporal video subset of an HEVC video stream conforming to one or more profiles defined in Annex A of Rec. ITU-T H.265 | ISO/IEC 23008-2
+	StreamTypeISO14496_10_MVCD                = StreamType(0x26) // MVCD video sub-bitstream of an AVC video stream conforming to one or more profiles defined in Annex I of Rec. ITU-T H.264 | ISO/IEC 14496-10
+	StreamTypeTEMI                            = StreamType(0x27) // Timeline and External Media Information Stream (see Annex U)
+	StreamTypeHEVC_AnnexG_Enhancement         = StreamType(0x28) // HEVC enhancement sub-partition which includes TemporalId 0 of an HEVC video stream where all NALs units contained in the stream conform to one or more profiles defined in Annex G of Rec. ITU-T H.265 | ISO/IEC 23008-2
+	StreamTypeHEVC_AnnexG_TemporalEnhancement = StreamType(0x29) // HEVC temporal enhancement sub-partition of an HEVC video stream where all NAL units contained in the stream conform to one or more profiles defined in Annex G of Rec. ITU-T H.265 | ISO/IEC 23008-2
+	StreamTypeHEVC_AnnexH_Enhancement         = StreamType(0x2A) // HEVC enhancement sub-partition which includes TemporalId 0 of an HEVC video stream where all NAL units contained in the stream conform to one or more profiles defined in Annex H of Rec. ITU-T H.265 | ISO/IEC 23008-2
+	StreamTypeHEVC_AnnexH_TemporalEnhancement = StreamType(0x2B) // HEVC temporal enhancement sub-partition of an HEVC video stream where all NAL units contained in the stream conform to one or more profiles defined in Annex H of Rec. ITU-T H.265 | ISO/IEC 23008-2
+	StreamTypeGreenAccessUnits                = StreamType(0x2C) // Green access units carried in MPEG-2 sections
+	StreamTypeISO23008_3_MHASMain             = StreamType(0x2D) // ISO/IEC 23008-3 Audio with MHAS transport syntax – main stream
+	StreamTypeISO23008_3_MHASAuxiliary        = StreamType(0x2E) // ISO/IEC 23008-3 Audio with MHAS transport syntax – auxiliary stream
+	StreamTypeQualityAccessUnits              = StreamType(0x2F) // Quality access units carried in sections
+	StreamTypeMediaOrchestration              = StreamType(0x30) // Media Orchestration Access Units carried in sections
+	StreamTypeHEVC_MCTS                       = StreamType(0x31) // Substream of a Rec. ITU-T H.265 | ISO/IEC 23008 2 video stream that contains a Motion Constrained Tile Set, parameter sets, slice headers or a combination thereof. See 2.17.5.1.
+	StreamTypeISO21122_2_JPEGXS               = StreamType(0x32) // JPEG XS video stream conforming to one or more profiles as defined in ISO/IEC 21122-2
+	StreamTypeVVC                             = StreamType(0x33) // VVC video stream or a VVC temporal video sub-bitstream conforming to one or more profiles defined in Annex A of Rec. ITU-T H.266 | ISO/IEC 23090-3
+	StreamTypeVVC_TemporalSubset              = StreamType(0x34) // VVC temporal video subset of a VVC video stream conforming to one or more profiles defined in Annex A of Rec. ITU-T H.266 | ISO/IEC 23090-3
+	StreamTypeEVC                             = StreamType(0x35) // EVC video stream or an EVC temporal video sub-bitstream conforming to one or more profiles defined in ISO/IEC 23094-1
 	// StreamType       = StreamType(0x36) // .. 0x7E Rec. ITU-T H.222.0 | ISO/IEC 13818-1 reserved
 	// StreamType       = StreamType(0x7F) // IPMP stream
 	StreamTypeUserPrivateMin = StreamType(0x80)
@@ -106,14 +106,47 @@ func (s *StreamInfo) IsUserPrivateStream() bool {
 type ProgramElementDescriptor struct {
 	Tag    uint8
 	Length uint8
+	// descriptor bytes after descriptor_length. Encode writes only Tag and Raw
+	Raw []byte
 
 	VideoStreamDescriptor
 	RegistrationDescriptor
+	CADescriptor
 	ISO639LanguageDescriptor
 	UserPrivateDescriptor
 	MPEG4VideoDescriptor
 	MPEG4AudioDescriptor
 	AVCVideoDescriptor
+	HEVCVideoDescriptor
+
+	// ARIB STD-B10
+	StreamIdentifierDescriptor
+	DigitalCopyControlDescriptor
+	AudioComponentDescriptor
+	DataContentDescriptor
+	VideoDecodeControlDescriptor
+	TSInformationDescriptor
+	LogoTransmissionDescriptor
+	SeriesDescriptor
+	EventGroupDescriptor
+	ComponentGroupDescriptor
+	ContentAvailabilityDescriptor
+	ISDBTerrestrialDeliverySystemDescriptor
+	PartialReceptionDescriptor
+
+	// ETSI EN 300 468
+	NetworkNameDescriptor
+	ServiceListDescriptor
+	SatelliteDeliverySystemDescriptor
+	CableDeliverySystemDescriptor
+	TerrestrialDeliverySystemDescriptor
+	LinkageDescriptor
+	LocalTimeOffsetDescriptor
+	ServiceDescriptor
+	ShortEventDescriptor
+	ExtendedEventDescriptor
+	ComponentDescriptor
+	ContentDescriptor
 }
 
 type VideoStreamDescriptor struct {
@@ -136,6 +169,14 @@ type RegistrationDescriptor struct {
 	AdditionalIdentificationInfo []byte
 }
 
+type CADescriptor struct {
+	// Rec. ITU-T H.222.0 (06-2021) p.85
+	CASystemID      uint16 // 16
+	Reserved        uint8  // 3
+	CAPID           PID    // 13
+	PrivateDataByte []byte
+}
+
 type ISO639LanguageDescriptor struct {
 	// Rec. ITU-T H.222.0 (06-2021) pp.86-87
 	Languages []ISO639LanguageRelation
@@ -177,13 +218,50 @@ type AVCVideoDescriptor struct {
 	Reserved                      uint8
 }
 
+type HEVCVideoDescriptor struct {
+	// Rec. ITU-T H.222.0 (06-2021) 2.6.95
+	ProfileSpace                   uint8  // 2
+	TierFlag                       bool   // 1
+	ProfileIDC                     uint8  // 5
+	ProfileCompatibilityIndication uint32 // 32
+	ProgressiveSourceFlag          bool   // 1
+	InterlacedSourceFlag           bool   // 1
+	NonPackedConstraintFlag        bool   // 1
+	FrameOnlyConstraintFlag        bool   // 1
+	Copied44Bits                   uint64 // 44
+	LevelIDC                       uint8  // 8
+	TemporalLayerSubsetFlag        bool   // 1
+	HEVCStillPresentFlag           bool   // 1
+	HEVC24HrPicturePresentFlag     bool   // 1
+	SubPicHRDParamsNotPresentFlag  bool   // 1
+	Reserved                       uint8  // 2
+	HDRWCGIdc                      uint8  // 2
+	TemporalIDMin                  uint8  // 3
+	TemporalIDMax                  uint8  // 3
+}
+
 func (p *Packet) ParsePMT(disableCRCcheck bool) (PMT, error) {
-	pmt := PMT{}
 	payload, err := p.GetPayload()
 	if err != nil {
 		return PMT{}, err
 	}
 	// fmt.Printf("raw pmt dump %#v\r\n", payload)
+	return parsePMT(payload, disableCRCcheck, SIOptions{})
+}
+
+// ParsePMT parses a section which has been reassembled by SectionAssembler, like a PMT which
+// spans several TS packets.
+func (s *Section) ParsePMT() (PMT, error) {
+	return parsePMT(append([]byte{0}, s.Data...), false, s.SIOptions)
+}
+
+// parsePMT parses a section which follows pointer_field in payload.
+func parsePMT(payload []byte, disableCRCcheck bool, opts SIOptions) (PMT, error) {
+	pmt := PMT{}
+	if len(payload) < 4 {
+		return PMT{}, ErrSectionTooShort
+	}
+	var err error
 
 	// Rec. ITU-T H.222.0 (06-2021) pp.57-60,p.261
 	pmt.Pointer = payload[0]
@@ -194,6 +272,9 @@ func (p *Packet) ParsePMT(disableCRCcheck bool) (PMT, error) {
 	}
 	pmt.Reserved1 = (payload[2] >> 4) & 0x03                            // 2
 	pmt.SectionLength = uint16(payload[2]&0x0F)<<8 | uint16(payload[3]) // 12
+	if pmt.SectionLength < 13 || int(pmt.SectionLength)+4 > len(payload) {
+		return PMT{}, fmt.Errorf("invalid section_length %d", pmt.SectionLength)
+	}
 	pmt.ProgramNumber = uint16(payload[4])<<8 | uint16(payload[5])      // 16
 	pmt.Reserved2 = (payload[6] >> 6) & 0x03                            // 2
 	pmt.Version = (payload[6] >> 1) & 0x1F                              // 5
@@ -210,7 +291,7 @@ func (p *Packet) ParsePMT(disableCRCcheck bool) (PMT, error) {
 
 	var diff int
 	for i := 0; i < int(pmt.ProgramInfoLength); i += diff { // N loop descriptors
-		pmt.Descriptors, diff, err = readDescriptor(payload, index, int(pmt.ProgramInfoLength))
+		pmt.Descriptors, diff, err = readDescriptor(payload, index, int(pmt.ProgramInfoLength), opts)
 		if err != nil {
 			return PMT{}, err
 		}
@@ -228,13 +309,16 @@ func (p *Packet) ParsePMT(disableCRCcheck bool) (PMT, error) {
 		index += 5
 
 		// N2 loop
-		si.Descriptors, diff, err = readDescriptor(payload, index, int(si.ESInfoLength))
+		si.Descriptors, diff, err = readDescriptor(payload, index, int(si.ESInfoLength), opts)
 		if err != nil {
 			return PMT{}, err
 		}
 		pmt.Streams = append(pmt.Streams, si)
 		index += diff
 	}
+	if index > int(pmt.SectionLength) {
+		return PMT{}, fmt.Errorf("ES_info_length exceeds section_length %d", pmt.SectionLength)
+	}
 	pmt.CRC32 = uint(payload[index])<<24 | uint(payload[index+1])<<16 | uint(payload[index+2])<<8 | uint(payload[index+3])
 	// fmt.Printf("crc: %08x\n", pmt.CRC32)
 	if disableCRCcheck {
@@ -251,7 +335,34 @@ func (p *Packet) ParsePMT(disableCRCcheck bool) (PMT, error) {
 	return pmt, nil
 }
 
-func readDescriptor(payload []byte, startIndex, length int) ([]ProgramElementDescriptor, int, error) {
+// Encode returns the section from table_id to CRC_32. section_length, program_info_length,
+// ES_info_length and CRC_32 are calculated from the descriptors and Streams.
+// Rec. ITU-T H.222.0 (06/2021) pp.57-60
+func (pmt *PMT) Encode() []byte {
+	b := []byte{TableID_ProgramMapSection, 0, 0}
+	b = append(b, byte(pmt.ProgramNumber>>8), byte(pmt.ProgramNumber))
+	b = append(b, 0xc0|(pmt.Version&0x1f)<<1|boolToByte(pmt.CurrentNextIndicator), pmt.SectionNumber, pmt.LastSectionNumber)
+	b = append(b, 0xe0|byte(pmt.PCR_PID>>8)&0x1f, byte(pmt.PCR_PID))
+	b = appendDescriptorLoop(b, pmt.Descriptors)
+	for _, si := range pmt.Streams {
+		b = append(b, byte(si.Type), 0xe0|byte(si.ElementaryPID>>8)&0x1f, byte(si.ElementaryPID))
+		b = appendDescriptorLoop(b, si.Descriptors)
+	}
+	return finishSection(b)
+}
+
+// appendDescriptorLoop appends the 12bit loop length with 4 reserved bits and the descriptors.
+func appendDescriptorLoop(b []byte, peds []ProgramElementDescriptor) []byte {
+	loop := []byte{}
+	for _, ped := range peds {
+		loop = append(loop, ped.Tag, byte(len(ped.Raw)))
+		loop = append(loop, ped.Raw...)
+	}
+	b = append(b, 0xf0|byte(len(loop)>>8)&0x0f, byte(len(loop)))
+	return append(b, loop...)
+}
+
+func readDescriptor(payload []byte, startIndex, length int, opts SIOptions) ([]ProgramElementDescriptor, int, error) {
 	// Rec. ITU-T H.222.0 (06-2021) pp.76-156,p.261
 
 	diffSum := 0
@@ -263,8 +374,12 @@ func readDescriptor(payload []byte, startIndex, length int) ([]ProgramElementDes
 	for index := startIndex; index < endIndex; {
 		// fmt.Printf("desc index:%d max:%d len:%d\n", index, (startIndex + length), endIndex)
 		ped := ProgramElementDescriptor{}
+		if index+2 > len(payload) || index+2+int(payload[index+1]) > len(payload) {
+			return nil, 0, fmt.Errorf("descriptor at %d exceeds the section", index)
+		}
 		ped.Tag = payload[index]
 		ped.Length = payload[index+1]
+		ped.Raw = copyBytes(payload[index+2 : index+2+int(ped.Length)])
 
 		diff := 2
 
@@ -306,7 +421,13 @@ func readDescriptor(payload []byte, startIndex, length int) ([]ProgramElementDes
 			fmt.Println("[WARN] not implemented", ped.Tag)
 			diff += int(ped.Length)
 		case ped.Tag == 9: //CA_descriptor
-			fmt.Println("[WARN] not implemented", ped.Tag, ped.Length)
+			if ped.Length < 4 || index+2+int(ped.Length) > len(payload) {
+				return nil, 0, errDescriptorTooShort(ped.Tag)
+			}
+			ped.CADescriptor.CASystemID = uint16(payload[index+2])<<8 | uint16(payload[index+3])      // 16
+			ped.CADescriptor.Reserved = (payload[index+4] >> 5) & 0x07                                // 3
+			ped.CADescriptor.CAPID = PID(uint16(payload[index+4]&0x1f)<<8 | uint16(payload[index+5])) // 13
+			ped.CADescriptor.PrivateDataByte = copyBytes(payload[index+6 : index+2+int(ped.Length)])
 			diff += int(ped.Length)
 		case ped.Tag == 10: //ISO_639_language_descriptor
 			ped.ISO639LanguageDescriptor.Languages = make([]ISO639LanguageRelation, ped.Length/4)
@@ -441,7 +562,36 @@ func readDescriptor(payload []byte, startIndex, length int) ([]ProgramElementDes
 			fmt.Println("[WARN] not implemented", ped.Tag)
 			diff += int(ped.Length)
 		case ped.Tag == 56: // HEVC video descriptor
-			fmt.Println("[WARN] not implemented", ped.Tag)
+			if ped.Length < 13 || index+2+int(ped.Length) > len(payload) {
+				return nil, 0, errDescriptorTooShort(ped.Tag)
+			}
+			d := payload[index+2 : index+2+int(ped.Length)]
+			hd := &ped.HEVCVideoDescriptor
+			hd.ProfileSpace = (d[0] >> 6) & 0x03    // 2
+			hd.TierFlag = ((d[0] >> 5) & 0x01) == 1 // 1
+			hd.ProfileIDC = d[0] & 0x1f             // 5
+			// profile_compatibility_indication (32)
+			hd.ProfileCompatibilityIndication = uint32(d[1])<<24 | uint32(d[2])<<16 | uint32(d[3])<<8 | uint32(d[4])
+			hd.ProgressiveSourceFlag = ((d[5] >> 7) & 0x01) == 1   // 1
+			hd.InterlacedSourceFlag = ((d[5] >> 6) & 0x01) == 1    // 1
+			hd.NonPackedConstraintFlag = ((d[5] >> 5) & 0x01) == 1 // 1
+			hd.FrameOnlyConstraintFlag = ((d[5] >> 4) & 0x01) == 1 // 1
+			// copied_44bits (44)
+			hd.Copied44Bits = uint64(d[5]&0x0f)<<40 | uint64(d[6])<<32 | uint64(d[7])<<24 | uint64(d[8])<<16 | uint64(d[9])<<8 | uint64(d[10])
+			hd.LevelIDC = d[11]                                           // 8
+			hd.TemporalLayerSubsetFlag = ((d[12] >> 7) & 0x01) == 1       // 1
+			hd.HEVCStillPresentFlag = ((d[12] >> 6) & 0x01) == 1          // 1
+			hd.HEVC24HrPicturePresentFlag = ((d[12] >> 5) & 0x01) == 1    // 1
+			hd.SubPicHRDParamsNotPresentFlag = ((d[12] >> 4) & 0x01) == 1 // 1
+			hd.Reserved = (d[12] >> 2) & 0x03                             // 2
+			hd.HDRWCGIdc = d[12] & 0x03                                   // 2
+			if hd.TemporalLayerSubsetFlag {
+				if ped.Length < 15 {
+					return nil, 0, errDescriptorTooShort(ped.Tag)
+				}
+				hd.TemporalIDMin = (d[13] >> 5) & 0x07 // 3
+				hd.TemporalIDMax = (d[14] >> 5) & 0x07 // 3
+			}
 			diff += int(ped.Length)
 		case ped.Tag == 57: // VVC video descriptor
 			fmt.Println("[WARN] not implemented", ped.Tag)
@@ -462,7 +612,18 @@ func readDescriptor(payload []byte, startIndex, length int) ([]ProgramElementDes
 		case ped.Tag == 63: // Extension_descriptor
 			fmt.Println("[WARN] not implemented", ped.Tag)
 		case ped.Tag >= 64 && ped.Tag <= 255: //  User Private
-			fmt.Printf("[WARN] user-private descriptor %02X %dbytes\n", ped.Tag, ped.Length)
+			ok, err := readSIDescriptor(&ped, payload, index, opts)
+			if err != nil {
+				// the meaning of a user-private tag depends on the network, and a descriptor of
+				// another network may not fit it. it is kept as raw bytes
+				ped = ProgramElementDescriptor{Tag: ped.Tag, Length: ped.Length, Raw: ped.Raw}
+				ok = false
+			}
+			if ok {
+				diff += int(ped.Length)
+				break
+			}
+			// unknown on the network. SI tables carry many of them, so they are kept without a warning
 			ped.UserPrivateDescriptor.Data = payload[index+2 : int(ped.Length)+index+2]
 			diff += int(ped.Length)
 		}
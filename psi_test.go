@@ -0,0 +1,158 @@
+package mpeg2ts
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPAT(t *testing.T) {
+	m := loadFixture(t, "psi.ts", fixturePSI)
+	p := m.PacketList.All()[0]
+	pat, err := p.ParsePAT()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "pat", pat)
+	if len(pat.Programs) != 3 || pat.Programs[0].NetworkPID != 0x0010 || pat.Programs[2].ProgramMapPID != fixtureLargePMTPID {
+		t.Errorf("unexpected programs %+v", pat.Programs)
+	}
+}
+
+func TestPATSections(t *testing.T) {
+	m := loadFixture(t, "psi.ts", fixturePSI)
+	sections := m.ReadSections(PID_PAT)
+	if len(sections) != 3 {
+		t.Fatalf("%d sections, want 3", len(sections))
+	}
+	pats := []PAT{}
+	for _, s := range sections[1:] {
+		pat, err := s.ParsePAT()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pats = append(pats, pat)
+	}
+	checkGolden(t, "pat_sections", pats)
+	for i, pat := range pats {
+		if pat.Version != 4 || int(pat.SectionNumber) != i || pat.LastSectionNumber != 1 {
+			t.Errorf("section %d: version %d, section_number %d/%d", i, pat.Version, pat.SectionNumber, pat.LastSectionNumber)
+		}
+	}
+}
+
+func TestPMT(t *testing.T) {
+	m := loadFixture(t, "psi.ts", fixturePSI)
+	pmts := m.FilterByPIDs(fixturePMTPID).PacketList.All()
+	if len(pmts) != 1 {
+		t.Fatalf("%d PMT packets, want 1", len(pmts))
+	}
+	pmt, err := pmts[0].ParsePMT(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "pmt", pmt)
+	if pmt.PCR_PID != fixtureVideoPID || len(pmt.Streams) != 2 {
+		t.Fatalf("unexpected PMT %+v", pmt)
+	}
+	if d := pmt.Streams[0].Descriptors; len(d) != 1 || d[0].AVCVideoDescriptor.ProfileIDC != 100 || d[0].AVCVideoDescriptor.LevelIDC != 40 {
+		t.Errorf("unexpected AVC_video_descriptor %+v", d)
+	}
+	if d := pmt.Descriptors; len(d) != 1 || string(d[0].RegistrationDescriptor.FormatIdentifier) != "HDMV" {
+		t.Errorf("unexpected registration_descriptor %+v", d)
+	}
+}
+
+func TestPMTSpanningPackets(t *testing.T) {
+	m := loadFixture(t, "psi.ts", fixturePSI)
+	packets := m.FilterByPIDs(fixtureLargePMTPID)
+	if n := len(packets.PacketList.All()); n != 2 {
+		t.Fatalf("%d PMT packets, want 2", n)
+	}
+	// the first packet has a part of the section
+	first := packets.PacketList.All()[0]
+	if _, err := first.ParsePMT(false); err == nil {
+		t.Error("PMT in the first packet is parsed")
+	}
+
+	sections := packets.ReadSections(fixtureLargePMTPID)
+	if len(sections) != 1 {
+		t.Fatalf("%d sections, want 1", len(sections))
+	}
+	if !bytes.Equal(sections[0].Data, fixtureLargePMT()) {
+		t.Errorf("reassembled section is %x", sections[0].Data)
+	}
+	pmt, err := sections[0].ParsePMT()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "pmt_sections", pmt)
+	if len(pmt.Streams) != 4 {
+		t.Fatalf("%d streams, want 4", len(pmt.Streams))
+	}
+	for i, si := range pmt.Streams {
+		if si.ElementaryPID != fixtureLargePMTPID+1+PID(i) || len(si.Descriptors) != 1 || si.Descriptors[0].Length != 50 {
+			t.Errorf("unexpected stream %d %+v", i, si)
+		}
+	}
+}
+
+func TestPMTUserPrivateDescriptor(t *testing.T) {
+	// digital_copy_control_descriptor of ARIB is 1 byte or more, but 0xC1 is a user-private tag
+	// which another network may use for a shorter descriptor
+	body := append(fixturePID(fixtureVideoPID), fixtureLoop()...)
+	body = append(body, fixturePMTStream(StreamTypeAVC, fixtureVideoPID, fixtureDescriptor(0xc1), fixtureDescriptor(0xfa, 0x01))...)
+	data := fixtureSectionPackets(fixturePMTPID, 0, fixtureLongSection(TableID_ProgramMapSection, 1, 0, 0, 0, body))
+	pl, _ := NewPacketList(PacketSizeDefault)
+	if err := pl.AddBytes(data[:PacketSizeDefault], PacketSizeDefault); err != nil {
+		t.Fatal(err)
+	}
+	pmt, err := pl.All()[0].ParsePMT(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pmt.Streams) != 1 || len(pmt.Streams[0].Descriptors) != 2 {
+		t.Fatalf("unexpected PMT %+v", pmt)
+	}
+	for _, d := range pmt.Streams[0].Descriptors {
+		if !bytes.Equal(d.Raw, d.UserPrivateDescriptor.Data) || len(d.Raw) != int(d.Length) {
+			t.Errorf("descriptor 0x%02X is not kept as raw bytes %+v", d.Tag, d)
+		}
+	}
+}
+
+func TestTuningTableSatellite(t *testing.T) {
+	// 12.5GHz, 110.0 east, DVB-S2 8PSK of roll_off 0.25, 28.86Msymbol/s and FEC 3/4
+	satellite := fixtureDescriptor(0x43, 0x01, 0x25, 0x00, 0x00, 0x11, 0x00, 0x8e, 0x02, 0x88, 0x60, 0x04)
+	ts := append([]byte{0x00, 0x10, 0x00, 0x04}, fixtureLoop(satellite)...)
+	body := append(fixtureLoop(), fixtureLoop(ts)...)
+	s, err := ParseSection(fixtureLongSection(TableID_NetworkInformationSection_ActualNetwork, 4, 0, 0, 0, body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		opts SIOptions
+		want TuningEntry
+	}{
+		{"DVB", SIOptions{}, TuningEntry{Modulation: 2, RollOff: 1, ModulationSystem: 1}},
+		{"ARIB", ARIBSIOptions(), TuningEntry{Modulation: 0x0e}},
+	} {
+		s.SIOptions = tc.opts
+		nit, err := s.ParseNIT()
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries := TuningTable(nit)
+		if len(entries) != 1 {
+			t.Fatalf("%s: %d entries", tc.name, len(entries))
+		}
+		e := entries[0]
+		if e.DeliverySystem != DeliverySystemSatellite || e.FrequenciesHz[0] != 12500000000 || e.SymbolRate != 28860000 || e.FECInner != 4 {
+			t.Errorf("%s: %+v", tc.name, e)
+		}
+		if e.Modulation != tc.want.Modulation || e.RollOff != tc.want.RollOff || e.ModulationSystem != tc.want.ModulationSystem {
+			t.Errorf("%s: modulation %d, roll_off %d and modulation_system %d", tc.name, e.Modulation, e.RollOff, e.ModulationSystem)
+		}
+	}
+}
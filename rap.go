@@ -0,0 +1,243 @@
+package mpeg2ts
+
+import (
+	"errors"
+)
+
+var (
+	ErrNotPESStart     = errors.New("packet does not start a PES packet")
+	ErrNoVideoStream   = errors.New("no video stream is found in PMT")
+	ErrPESHeaderTooBig = errors.New("PES header does not fit in the packet")
+)
+
+// PESTimestamps is PTS and DTS in 90kHz
+type PESTimestamps struct {
+	PTS    uint64 // 33
+	DTS    uint64 // 33
+	HasPTS bool
+	HasDTS bool
+}
+
+// ReadPESTimestamps reads PTS and DTS of the PES header which starts in the packet.
+func (p *Packet) ReadPESTimestamps() (PESTimestamps, error) {
+	_, ts, err := p.readPESHeader()
+	return ts, err
+}
+
+// readPESHeader returns the elementary stream bytes in the packet and the timestamps.
+// Rec. ITU-T H.222.0 (06/2021) pp.39-44
+func (p *Packet) readPESHeader() ([]byte, PESTimestamps, error) {
+	ts := PESTimestamps{}
+	if !p.PayloadUnitStartIndicator {
+		return nil, ts, ErrNotPESStart
+	}
+	payload, err := p.GetPayload()
+	if err != nil {
+		return nil, ts, err
+	}
+	if len(payload) < 9 || payload[0] != 0x00 || payload[1] != 0x00 || payload[2] != 0x01 {
+		return nil, ts, ErrNotPESStart
+	}
+	switch payload[3] {
+	case StreamID_ProgramStreamMap, StreamID_PaddingStream, StreamID_PrivateStream2, StreamID_ECM, StreamID_EMM, StreamID_ProgramStreamDirectory, StreamID_DSMCC, StreamID_H222_1_TypeE:
+		// no optional PES header
+		return payload[6:], ts, nil
+	}
+	headerDataLength := int(payload[8])
+	if 9+headerDataLength > len(payload) {
+		return nil, ts, ErrPESHeaderTooBig
+	}
+	flags := (payload[7] >> 6) & 0x03 // PTS_DTS_flags
+	if flags&0x02 != 0 && headerDataLength >= 5 {
+		ts.PTS = readTimestamp(payload[9:14])
+		ts.HasPTS = true
+	}
+	if flags == 0x03 && headerDataLength >= 10 {
+		ts.DTS = readTimestamp(payload[14:19])
+		ts.HasDTS = true
+	}
+	return payload[9+headerDataLength:], ts, nil
+}
+
+// 33bit timestamp with marker bits
+func readTimestamp(b []byte) uint64 {
+	return uint64((b[0]>>1)&0x07)<<30 | uint64(b[1])<<22 | uint64(b[2]>>1)<<15 | uint64(b[3])<<7 | uint64(b[4]>>1)
+}
+
+// ptsDiff returns a - b for 33bit timestamps considering wrap-around.
+func ptsDiff(a, b uint64) int64 {
+	d := int64((a - b) & (ptsWrap - 1))
+	if d >= ptsWrap/2 {
+		d -= ptsWrap
+	}
+	return d
+}
+
+// IsVideo reports whether the stream type is a video stream which can be split at random access points.
+func (st StreamType) IsVideo() bool {
+	switch st {
+	case StreamTypeISO11172_2_Video, StreamTypeISO13818_2_Video, StreamTypeISO14496_2_Visual, StreamTypeAVC, StreamTypeHEVC:
+		return true
+	}
+	return false
+}
+
+// IsRandomAccessPoint reports whether the packet starts a PES packet which can be decoded
+// without preceding data. random_access_indicator, MPEG-2 sequence/GOP header, H.264 IDR/SPS
+// and HEVC IRAP/VPS are detected.
+func (p *Packet) IsRandomAccessPoint(st StreamType) bool {
+	if !p.PayloadUnitStartIndicator {
+		return false
+	}
+	if p.HasAdaptationField() && p.AdaptationField.RandomAccessIndicator {
+		return true
+	}
+	es, _, err := p.readPESHeader()
+	if err != nil {
+		return false
+	}
+	return isKeyframeStart(st, es)
+}
+
+func isKeyframeStart(st StreamType, es []byte) bool {
+	for i := 0; i+3 < len(es); i++ {
+		if es[i] != 0x00 || es[i+1] != 0x00 || es[i+2] != 0x01 {
+			continue
+		}
+		code := es[i+3]
+		switch st {
+		case StreamTypeISO11172_2_Video, StreamTypeISO13818_2_Video:
+			if code == 0xB3 || code == 0xB8 { // sequence_header, group_of_pictures_header
+				return true
+			}
+		case StreamTypeISO14496_2_Visual:
+			if code == 0xB0 || code == 0xB3 { // visual_object_sequence, group_of_vop
+				return true
+			}
+		case StreamTypeAVC:
+			nalType := code & 0x1f
+			if nalType == 5 || nalType == 7 { // IDR slice, SPS
+				return true
+			}
+		case StreamTypeHEVC:
+			nalType := (code >> 1) & 0x3f
+			if (nalType >= 16 && nalType <= 21) || nalType == 32 { // IRAP, VPS
+				return true
+			}
+		}
+		i += 2
+	}
+	return false
+}
+
+// programTracker follows PAT and PMT of a program and finds its video stream.
+type programTracker struct {
+	// 0: the first program in PAT
+	programNumber uint16
+
+	// PAT of only the program
+	pat       Packet
+	pmt       Packet
+	pmtPID    PID
+	hasPAT    bool
+	hasPMT    bool
+	videoPID  PID
+	videoType StreamType
+	pcrPID    PID
+	streams   []StreamInfo
+}
+
+// isCompleteSectionPacket reports whether the packet carries a whole section from offset 0,
+// which ParsePAT and ParsePMT expect.
+func isCompleteSectionPacket(p Packet) bool {
+	if !p.PayloadUnitStartIndicator {
+		return false
+	}
+	payload, err := p.GetPayload()
+	if err != nil || len(payload) < 4 || payload[0] != 0 {
+		return false
+	}
+	sectionLength := int(payload[2]&0x0f)<<8 | int(payload[3])
+	return 4+sectionLength <= len(payload)
+}
+
+// observe returns true when the packet is PAT or PMT of the program.
+func (pt *programTracker) observe(p Packet) bool {
+	if p.PID == PID_PAT {
+		if !isCompleteSectionPacket(p) {
+			return true
+		}
+		pat, err := p.ParsePAT()
+		if err != nil {
+			return true
+		}
+		for _, prog := range pat.Programs {
+			if prog.ProgramNumber == 0 {
+				continue
+			}
+			if pt.programNumber == 0 || prog.ProgramNumber == pt.programNumber {
+				pt.pmtPID = prog.ProgramMapPID
+				pt.pat = programPAT(pat, prog, p)
+				pt.hasPAT = true
+				break
+			}
+		}
+		return true
+	}
+	if !pt.hasPAT || p.PID != pt.pmtPID {
+		return false
+	}
+	if !isCompleteSectionPacket(p) {
+		return true
+	}
+	pmt, err := p.ParsePMT(false)
+	if err != nil {
+		return true
+	}
+	pt.pmt = p
+	pt.hasPMT = true
+	pt.pcrPID = pmt.PCR_PID
+	pt.streams = pmt.Streams
+	pt.videoPID = PID_NullPacket
+	for _, si := range pmt.Streams {
+		if si.Type.IsVideo() {
+			pt.videoPID = si.ElementaryPID
+			pt.videoType = si.Type
+			break
+		}
+	}
+	return true
+}
+
+// programPAT returns a packet in the place of the PAT packet p, of which PAT lists only the program.
+func programPAT(pat PAT, prog PATProgram, p Packet) Packet {
+	single := PAT{TransportStreamID: pat.TransportStreamID, Version: pat.Version, CurrentNextIndicator: pat.CurrentNextIndicator, Programs: []PATProgram{prog}}
+	packets := PacketizeSection(PID_PAT, single.Encode(), func() byte { return p.ContinuityCheckIndex })
+	sp := Packet{Index: p.Index, Data: packets[0]}
+	sp.parseHeader()
+	return sp
+}
+
+// isProgramPID reports whether the packet of pid belongs to the program, which is PAT, PMT,
+// an elementary stream or PCR of the program.
+func (pt *programTracker) isProgramPID(pid PID) bool {
+	if pid == PID_PAT || (pt.hasPAT && pid == pt.pmtPID) {
+		return true
+	}
+	if !pt.hasPMT {
+		return false
+	}
+	if pid == pt.pcrPID {
+		return true
+	}
+	for _, si := range pt.streams {
+		if si.ElementaryPID == pid {
+			return true
+		}
+	}
+	return false
+}
+
+func (pt *programTracker) hasVideo() bool {
+	return pt.hasPMT && pt.videoPID != PID_NullPacket
+}
@@ -0,0 +1,206 @@
+package mpeg2ts
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrRestampNoBitrate     = errors.New("bitrate must be positive")
+	ErrRestampBitrateTooLow = errors.New("packets do not fit in the bitrate")
+	ErrRestampNoSourceClock = errors.New("source clock of the PCR PID is required to restamp by the arrival time")
+)
+
+type PCRRestampMode int
+
+const (
+	// PCR is the time of the byte position in a stream of the constant Bitrate,
+	// starting from the first PCR of the PID
+	PCRRestampConstantBitrate PCRRestampMode = iota
+	// PCR is the time when the packet arrived in the source stream, interpolated between the
+	// PCRs of the source ClockMap of its PID. Packet.Index must be the index in the source stream,
+	// as FilterByPIDs keeps it
+	PCRRestampArrivalTime
+)
+
+// PCRRestamper rewrites PCR of the packets for their new positions after re-multiplexing.
+type PCRRestamper struct {
+	Mode PCRRestampMode
+	// in bit/s for PCRRestampConstantBitrate
+	Bitrate int
+	// clocks of the source stream for PCRRestampArrivalTime by PCR PID, as programs have their
+	// own timebases
+	Sources map[PID]*ClockMap
+	// moves OPCR by the same amount as PCR of the packet, or rewrites it as PCR without PCR
+	RestampOPCR bool
+
+	// packets which have been enqueued
+	position int
+	// the first packet with PCR of each PID, as programs have their own timebases
+	first map[PID]pcrAnchor
+}
+
+type pcrAnchor struct {
+	pcr      int64
+	position int
+}
+
+// NewPCRRestamper returns a PCRRestamper with the source clocks keyed by their PCRPID.
+func NewPCRRestamper(mode PCRRestampMode, bitrate int, sources ...*ClockMap) PCRRestamper {
+	r := PCRRestamper{Mode: mode, Bitrate: bitrate, Sources: map[PID]*ClockMap{}}
+	for _, cm := range sources {
+		r.Sources[cm.PCRPID] = cm
+	}
+	return r
+}
+
+// EnqueueTSPacket returns a copy of the packet with the new PCR. Packets must be enqueued in the
+// order of the output stream.
+func (r *PCRRestamper) EnqueueTSPacket(p Packet) (Packet, error) {
+	position := r.position
+	r.position++
+	af := &p.AdaptationField
+	if !p.HasAdaptationField() || af.Length == 0 || (!af.PCRFlag && !(af.OPCRFlag && r.RestampOPCR)) {
+		return p, nil
+	}
+
+	var pcr int64
+	switch r.Mode {
+	case PCRRestampConstantBitrate:
+		if r.Bitrate <= 0 {
+			return p, ErrRestampNoBitrate
+		}
+		if r.first == nil {
+			r.first = map[PID]pcrAnchor{}
+		}
+		first, ok := r.first[p.PID]
+		if !ok {
+			if !af.PCRFlag {
+				// no timebase yet
+				return p, nil
+			}
+			first = pcrAnchor{pcr: pcrValue(af.ProgramClockReference), position: position}
+			r.first[p.PID] = first
+		}
+		pcr = first.pcr + bitsToClock(int64(position-first.position)*PacketSizeDefault*8, r.Bitrate)
+	case PCRRestampArrivalTime:
+		source, ok := r.Sources[p.PID]
+		if !ok || source == nil {
+			return p, fmt.Errorf("PID 0x%04x: %w", p.PID, ErrRestampNoSourceClock)
+		}
+		v, err := source.PCRAtPacket(p.Index)
+		if err != nil {
+			return p, err
+		}
+		pcr = int64(v)
+	}
+
+	p = p.DeepCopy()
+	af = &p.AdaptationField
+	fieldIndex := 6
+	delta := int64(0)
+	if af.PCRFlag {
+		delta = pcr - pcrValue(af.ProgramClockReference)
+		af.ProgramClockReference = setPCR(p.Data[fieldIndex:], pcr)
+		fieldIndex += 6
+	}
+	if af.OPCRFlag && r.RestampOPCR {
+		opcr := pcr
+		if af.PCRFlag {
+			opcr = pcrValue(af.OriginalProgramClockReference) + delta
+		}
+		af.OriginalProgramClockReference = setPCR(p.Data[fieldIndex:], opcr)
+	}
+	return p, nil
+}
+
+// Restamp returns a copy of the stream with the new PCR.
+func (r *PCRRestamper) Restamp(m *MPEG2TS) (*MPEG2TS, error) {
+	mx := New(m.chunkSize)
+	for _, p := range m.PacketList.All() {
+		rp, err := r.EnqueueTSPacket(p)
+		if err != nil {
+			return nil, err
+		}
+		mx.AddPacket(rp)
+	}
+	return mx, nil
+}
+
+func pcrValue(pcr ProgramClockReference) int64 {
+	return int64(pcr.Base*300 + uint64(pcr.Extension))
+}
+
+// setPCR writes a 27MHz clock to the 6 bytes of b and returns it as ProgramClockReference.
+func setPCR(b []byte, pcr int64) ProgramClockReference {
+	copy(b, appendPCR(nil, pcr))
+	pcr %= pcrWrap
+	if pcr < 0 {
+		pcr += pcrWrap
+	}
+	return ProgramClockReference{Base: uint64(pcr / 300), Extension: uint16(pcr % 300)}
+}
+
+// StripNullPackets returns the stream without null packets, which makes it a variable bitrate stream.
+func (m *MPEG2TS) StripNullPackets() *MPEG2TS {
+	mx := New(m.chunkSize)
+	for _, p := range m.PacketList.All() {
+		if p.PID != PID_NullPacket {
+			mx.AddPacket(p)
+		}
+	}
+	return mx
+}
+
+// PadToBitrate inserts null packets so that every packet is sent at its arrival time in the source
+// stream, measured by PCR on pcrPID, at the constant bitrate. Packet.Index must be the index in
+// the source stream, and it is renumbered in the output. ErrRestampBitrateTooLow is returned if a
+// packet is later than its time by more than a packet.
+func (m *MPEG2TS) PadToBitrate(bitrate int, pcrPID PID) (*MPEG2TS, error) {
+	if bitrate <= 0 {
+		return nil, ErrRestampNoBitrate
+	}
+	// only PCR is used
+	cm := m.NewClockMap(pcrPID, SIOptions{})
+	mx := New(m.chunkSize)
+	index := 0
+	var start int64
+	for i, p := range m.PacketList.All() {
+		clock, err := cm.ClockAt(p.Index)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			start = clock
+		}
+		target := int(clockToBits(clock-start, bitrate) / (PacketSizeDefault * 8))
+		if index > target+1 {
+			return nil, fmt.Errorf("packet %d is %d packets late: %w", p.Index, index-target, ErrRestampBitrateTooLow)
+		}
+		for ; index < target; index++ {
+			mx.AddPacket(newNullPacket(index))
+		}
+		p.Index = index
+		mx.AddPacket(p)
+		index++
+	}
+	return mx, nil
+}
+
+func newNullPacket(index int) Packet {
+	p := Packet{Index: index, Data: nullPacket()}
+	p.parseHeader()
+	return p
+}
+
+// bitsToClock returns the 27MHz time to send the bits at the bitrate without overflow.
+func bitsToClock(bits int64, bitrate int) int64 {
+	rate := int64(bitrate)
+	return bits/rate*SystemClockFrequency + bits%rate*SystemClockFrequency/rate
+}
+
+// clockToBits returns the bits sent in the 27MHz time at the bitrate without overflow.
+func clockToBits(clock int64, bitrate int) int64 {
+	rate := int64(bitrate)
+	return clock/SystemClockFrequency*rate + clock%SystemClockFrequency*rate/SystemClockFrequency
+}
@@ -0,0 +1,83 @@
+package mpeg2ts
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPCRRestampPrograms(t *testing.T) {
+	// two programs of 1s and 1000s with PCR in every packet
+	const pcrPID2 = PID(0x0201)
+	pl, _ := NewPacketList(PacketSizeDefault)
+	for i := 0; i < 10; i++ {
+		pid, base := fixtureVideoPID, uint64(PTSClockFrequency)
+		if i%2 == 1 {
+			pid, base = pcrPID2, 1000*PTSClockFrequency
+		}
+		// PCR of the source is not regular
+		base += uint64(i * i * 100)
+		if err := pl.AddBytes(fixturePacket(pid, false, byte(i/2), append([]byte{0x10}, fixturePCR(base, 0)...), nil), PacketSizeDefault); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a packet takes 1ms
+	r := NewPCRRestamper(PCRRestampConstantBitrate, PacketSizeDefault*8*1000)
+	for i, p := range pl.All() {
+		rp, err := r.EnqueueTSPacket(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// from the first PCR of the PID, at the packets 0 and 1
+		want := int64(PTSClockFrequency)*300 + int64(i)*SystemClockFrequency/1000
+		if p.PID == pcrPID2 {
+			want = int64(1000*PTSClockFrequency+100)*300 + int64(i-1)*SystemClockFrequency/1000
+		}
+		if got := pcrValue(rp.AdaptationField.ProgramClockReference); got != want {
+			t.Errorf("packet %d of PID 0x%04x: PCR %d, want %d", i, p.PID, got, want)
+		}
+	}
+}
+
+func TestPCRRestampArrivalTime(t *testing.T) {
+	// two programs of 1s and 1000s with PCR in every packet, 1ms apart
+	const pcrPID2 = PID(0x0201)
+	source, _ := NewPacketList(PacketSizeDefault)
+	delayed, _ := NewPacketList(PacketSizeDefault)
+	for i := 0; i < 10; i++ {
+		pid, base := fixtureVideoPID, uint64(PTSClockFrequency)
+		if i%2 == 1 {
+			pid, base = pcrPID2, 1000*PTSClockFrequency
+		}
+		base += uint64(i * PTSClockFrequency / 1000)
+		if err := source.AddBytes(fixturePacket(pid, false, byte(i/2), append([]byte{0x10}, fixturePCR(base, 0)...), nil), PacketSizeDefault); err != nil {
+			t.Fatal(err)
+		}
+		// PCR is off after re-multiplexing
+		if err := delayed.AddBytes(fixturePacket(pid, false, byte(i/2), append([]byte{0x10}, fixturePCR(base+uint64(i*100), 0)...), nil), PacketSizeDefault); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cm1, cm2 := NewClockMap(fixtureVideoPID, SIOptions{}), NewClockMap(pcrPID2, SIOptions{})
+	for _, p := range source.All() {
+		cm1.EnqueueTSPacket(p)
+		cm2.EnqueueTSPacket(p)
+	}
+
+	r := NewPCRRestamper(PCRRestampArrivalTime, 0, &cm1, &cm2)
+	for i, p := range delayed.All() {
+		rp, err := r.EnqueueTSPacket(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := source.All()[i].AdaptationField.ProgramClockReference
+		if got := rp.AdaptationField.ProgramClockReference; got != want {
+			t.Errorf("packet %d of PID 0x%04x: PCR %+v, want %+v", i, p.PID, got, want)
+		}
+	}
+
+	r = NewPCRRestamper(PCRRestampArrivalTime, 0, &cm1)
+	if _, err := r.EnqueueTSPacket(delayed.All()[1]); !errors.Is(err, ErrRestampNoSourceClock) {
+		t.Errorf("PCR PID without the source clock: %v", err)
+	}
+}
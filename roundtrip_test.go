@@ -0,0 +1,280 @@
+package mpeg2ts
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var fixtures = []struct {
+	name  string
+	build func() []byte
+}{
+	{"adaptation.ts", fixtureAdaptationFields},
+	{"psi.ts", fixturePSI},
+	{"pes.ts", fixturePESStream},
+	{"time.ts", fixtureTimeStream},
+}
+
+func TestPacketEncodeRoundTrip(t *testing.T) {
+	for _, f := range fixtures {
+		m := loadFixture(t, f.name, f.build)
+		for _, p := range m.PacketList.All() {
+			if b := p.Encode(); !bytes.Equal(b, p.Data) {
+				t.Errorf("%s packet %d: encoded to %x, want %x", f.name, p.Index, b, p.Data)
+			}
+		}
+	}
+}
+
+func TestPSIEncodeRoundTrip(t *testing.T) {
+	m := loadFixture(t, "psi.ts", fixturePSI)
+	for _, s := range m.ReadSections(PID_PAT) {
+		pat, err := s.ParsePAT()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b := pat.Encode(); !bytes.Equal(b, s.Data) {
+			t.Errorf("PAT section %d: encoded to %x, want %x", s.SectionNumber, b, s.Data)
+		}
+	}
+	for _, pid := range []PID{fixturePMTPID, fixtureLargePMTPID} {
+		sections := m.ReadSections(pid)
+		if len(sections) != 1 {
+			t.Fatalf("PID 0x%04x: %d sections, want 1", pid, len(sections))
+		}
+		pmt, err := sections[0].ParsePMT()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b := pmt.Encode(); !bytes.Equal(b, sections[0].Data) {
+			t.Errorf("PMT on PID 0x%04x: encoded to %x, want %x", pid, b, sections[0].Data)
+		}
+	}
+}
+
+func TestSDTEncodeLongNames(t *testing.T) {
+	name := strings.Repeat("あ", 100)
+	sdt := SDT{TableID: TableID_ServiceDescriptionSection_ActualDVBTransportStream, CurrentNextIndicator: true}
+	sdt.Services = []SDTService{{ServiceID: 1, ServiceType: ServiceType_DigitalTelevision, ServiceProviderName: "provider", ServiceName: name}}
+	s, err := ParseSection(sdt.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := s.ParseSDT()
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := parsed.Services[0]
+	if svc.ServiceProviderName != "provider" {
+		t.Errorf("service_provider_name %q", svc.ServiceProviderName)
+	}
+	// 255 bytes of the descriptor leave 242 bytes after the selectors, the lengths and the provider
+	if svc.ServiceName != strings.Repeat("あ", 80) {
+		t.Errorf("service_name of %d bytes is not truncated at a character", len(svc.ServiceName))
+	}
+}
+
+func TestWriteToRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range fixtures {
+		m := loadFixture(t, f.name, f.build)
+		want := f.build()
+		buf := &bytes.Buffer{}
+		if n, err := m.WriteTo(buf); err != nil || n != int64(len(want)) {
+			t.Fatalf("%s: %d bytes written: %v", f.name, n, err)
+		}
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("%s is not written as it is loaded", f.name)
+		}
+
+		// 204 bytes packets are loaded and written again
+		fec := New(PacketSizeWithFEC)
+		for _, p := range m.PacketList.All() {
+			fec.AddPacket(p)
+		}
+		buf.Reset()
+		if _, err := fec.WriteTo(buf); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, f.name)
+		if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := LoadStandardTSWithFEC(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		written := &bytes.Buffer{}
+		if _, err := loaded.WriteTo(written); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(written.Bytes(), buf.Bytes()) {
+			t.Errorf("%s: 204 bytes packets are not written as they are loaded", f.name)
+		}
+
+		// TP_extra_header is prepended to the packets
+		buf.Reset()
+		pw, err := NewPacketWriter(buf, PacketSizeM2TS)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range m.PacketList.All() {
+			if err := pw.WritePacket(p); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := pw.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		stripped := []byte{}
+		for b := buf.Bytes(); len(b) >= PacketSizeM2TS; b = b[PacketSizeM2TS:] {
+			stripped = append(stripped, b[4:PacketSizeM2TS]...)
+		}
+		if !bytes.Equal(stripped, want) {
+			t.Errorf("%s: 192 bytes packets do not carry the packets", f.name)
+		}
+	}
+}
+
+func TestMuxerRoundTrip(t *testing.T) {
+	buf := &bytes.Buffer{}
+	mx := NewMuxer(buf)
+	err := mx.AddProgram(MuxerProgram{
+		ProgramNumber: 1,
+		PMTPID:        fixturePMTPID,
+		Streams: []MuxerStream{
+			{PID: fixtureVideoPID, Type: StreamTypeAVC},
+			{PID: fixtureAudioPID, Type: StreamTypeISO13818_7_AudioWithADTS},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	units := []AccessUnit{}
+	for i := 0; i < 10; i++ {
+		units = append(units, AccessUnit{PID: fixtureVideoPID, PTS: uint64(93600 + i*3600), DTS: uint64(90000 + i*3600), HasPTS: true, HasDTS: true, RandomAccess: i == 0, Data: fixturePayload(1000+i*37, byte(i))})
+		units = append(units, AccessUnit{PID: fixtureAudioPID, PTS: uint64(90000 + i*3600), HasPTS: true, Data: fixturePayload(200+i, byte(0x80+i))})
+	}
+	for _, au := range units {
+		if err := mx.WriteAccessUnit(au); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "mux.ts")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := LoadStandardTS(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cr := m.CheckStream(); cr.DropCount != 0 {
+		t.Errorf("%d packets are dropped", cr.DropCount)
+	}
+
+	pats := m.ReadSections(PID_PAT)
+	if len(pats) == 0 {
+		t.Fatal("no PAT")
+	}
+	pat, err := pats[0].ParsePAT()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pat.Programs) != 1 || pat.Programs[0].ProgramMapPID != fixturePMTPID {
+		t.Fatalf("unexpected programs %+v", pat.Programs)
+	}
+	pmts := m.ReadSections(fixturePMTPID)
+	if len(pmts) == 0 {
+		t.Fatal("no PMT")
+	}
+	pmt, err := pmts[0].ParsePMT()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pmt.PCR_PID != fixtureVideoPID || len(pmt.Streams) != 2 {
+		t.Fatalf("unexpected PMT %+v", pmt)
+	}
+
+	for _, pid := range []PID{fixtureVideoPID, fixtureAudioPID} {
+		pes := parsePES(t, m.FilterByPIDs(pid).PacketList.All())
+		want := []AccessUnit{}
+		for _, au := range units {
+			if au.PID == pid {
+				want = append(want, au)
+			}
+		}
+		// the last access unit is not emitted by PESParser
+		if len(pes) != len(want)-1 {
+			t.Fatalf("PID 0x%04x: %d PES packets, want %d", pid, len(pes), len(want)-1)
+		}
+		for i, p := range pes {
+			if p.RawPTS() != want[i].PTS || (want[i].HasDTS && p.RawDTS() != want[i].DTS) {
+				t.Errorf("PID 0x%04x PES %d: PTS %d, DTS %d", pid, i, p.RawPTS(), p.RawDTS())
+			}
+			if !bytes.Equal(p.ElementaryStream, want[i].Data) {
+				t.Errorf("PID 0x%04x PES %d: %d bytes of elementary stream, want %d", pid, i, len(p.ElementaryStream), len(want[i].Data))
+			}
+		}
+	}
+}
+
+func TestMuxerDedicatedPCR(t *testing.T) {
+	const pcrPID = PID(0x01ff)
+	buf := &bytes.Buffer{}
+	mx := NewMuxer(buf)
+	err := mx.AddProgram(MuxerProgram{
+		ProgramNumber: 1,
+		PMTPID:        fixturePMTPID,
+		PCRPID:        pcrPID,
+		Streams:       []MuxerStream{{PID: fixtureAudioPID, Type: StreamTypeISO13818_7_AudioWithADTS}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the PCR PID is reserved
+	err = mx.AddProgram(MuxerProgram{ProgramNumber: 2, PMTPID: 0x0200, Streams: []MuxerStream{{PID: pcrPID, Type: StreamTypeAVC}}})
+	if !errors.Is(err, ErrMuxerDuplicatePID) {
+		t.Errorf("stream on the PCR PID: %v", err)
+	}
+	err = mx.AddProgram(MuxerProgram{ProgramNumber: 2, PMTPID: 0x0200, PCRPID: fixtureAudioPID, Streams: []MuxerStream{{PID: 0x0201, Type: StreamTypeAVC}}})
+	if !errors.Is(err, ErrMuxerDuplicatePID) {
+		t.Errorf("PCR on a stream of another program: %v", err)
+	}
+
+	for i := 0; i < 50; i++ {
+		au := AccessUnit{PID: fixtureAudioPID, PTS: uint64(90000 + i*1920), HasPTS: true, Data: fixturePayload(300, byte(i))}
+		if err := mx.WriteAccessUnit(au); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pl, _ := NewPacketList(PacketSizeDefault)
+	for b := buf.Bytes(); len(b) > 0; b = b[PacketSizeDefault:] {
+		if err := pl.AddBytes(b[:PacketSizeDefault], PacketSizeDefault); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pcrs := []int64{}
+	for _, p := range pl.All() {
+		if p.HasAdaptationField() && p.AdaptationField.PCRFlag {
+			if p.PID != pcrPID {
+				t.Fatalf("PCR on PID 0x%04x", p.PID)
+			}
+			pcrs = append(pcrs, pcrValue(p.AdaptationField.ProgramClockReference))
+		}
+	}
+	// 49 access units of 21.3ms
+	if len(pcrs) < 25 {
+		t.Fatalf("%d PCRs", len(pcrs))
+	}
+	for i := 1; i < len(pcrs); i++ {
+		if d := pcrs[i] - pcrs[i-1]; d <= 0 || d > durationToClock(mx.PCRInterval) {
+			t.Errorf("PCR %d: interval %d", i, d)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package mpeg2ts
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+const (
+	// ETSI EN 300 468 V1.17.1 pp.87-88 Table 89, ARIB STD-B10 Part 2 p.110
+	ServiceType_DigitalTelevision      = 0x01
+	ServiceType_DigitalRadio           = 0x02
+	ServiceType_Teletext               = 0x03
+	ServiceType_DataBroadcast          = 0x0C
+	ServiceType_AVCHDDigitalTelevision = 0x19
+	ServiceType_TemporaryVideo         = 0xA1
+	ServiceType_TemporaryAudio         = 0xA2
+	ServiceType_TemporaryData          = 0xA3
+	ServiceType_Engineering            = 0xA4
+	ServiceType_PromotionVideo         = 0xA5
+	ServiceType_PromotionAudio         = 0xA6
+	ServiceType_PromotionData          = 0xA7
+	ServiceType_AccumulationData       = 0xA8
+	ServiceType_AccumulationOnlyData   = 0xA9
+	ServiceType_BookmarkListData       = 0xAA
+	ServiceType_ServerTypeSimultaneous = 0xAB
+	ServiceType_IndependentFile        = 0xAC
+	ServiceType_UltraHDTelevision      = 0xAD
+	ServiceType_Data                   = 0xC0
+	ServiceType_TLVAccumulation        = 0xC1
+	ServiceType_MultimediaService      = 0xC2
+)
+
+// Service Description Table
+// ETSI EN 300 468 V1.17.1 pp.29-30, ARIB STD-B10 Part 2 p.15
+type SDT struct {
+	TableID                byte   // 8
+	SectionSyntaxIndicator bool   // 1
+	SectionLength          uint16 // 12
+	TransportStreamID      uint16 // 16
+	Version                byte   // 5
+	CurrentNextIndicator   bool   // 1
+	SectionNumber          byte   // 8
+	LastSectionNumber      byte   // 8
+	OriginalNetworkID      uint16 // 16
+	Services               []SDTService
+	CRC32                  uint // 32
+}
+
+type SDTService struct {
+	ServiceID               uint16 // 16
+	EITUserDefinedFlags     uint8  // 3 (ARIB)
+	EITScheduleFlag         bool   // 1
+	EITPresentFollowingFlag bool   // 1
+	RunningStatus           byte   // 3
+	FreeCAMode              bool   // 1
+	DescriptorsLoopLength   uint16 // 12
+	Descriptors             []ProgramElementDescriptor
+
+	// copied from service_descriptor
+	ServiceType         uint8
+	ServiceProviderName string
+	ServiceName         string
+}
+
+// ProgramService is a PAT program joined with its SDT service.
+type ProgramService struct {
+	ProgramNumber uint16
+	ProgramMapPID PID
+	// false when no SDT service has the program number as service_id
+	HasService bool
+	Service    SDTService
+}
+
+// IsActualTS reports whether the table describes the current transport stream.
+func (sdt *SDT) IsActualTS() bool {
+	return sdt.TableID == TableID_ServiceDescriptionSection_ActualDVBTransportStream
+}
+
+func (s *Section) ParseSDT() (SDT, error) {
+	if s.TableID != TableID_ServiceDescriptionSection_ActualDVBTransportStream && s.TableID != TableID_ServiceDescriptionSection_OtherDVBTransportStream {
+		return SDT{}, fmt.Errorf("invalid TableID. expected: 0x42 or 0x46, actual: 0x%02x", s.TableID)
+	}
+	if err := s.CheckCRC(); err != nil {
+		return SDT{}, err
+	}
+	sdt := SDT{}
+	sdt.TableID = s.TableID
+	sdt.SectionSyntaxIndicator = s.SectionSyntaxIndicator
+	sdt.SectionLength = s.SectionLength
+	sdt.TransportStreamID = s.TableIDExtension
+	sdt.Version = s.Version
+	sdt.CurrentNextIndicator = s.CurrentNextIndicator
+	sdt.SectionNumber = s.SectionNumber
+	sdt.LastSectionNumber = s.LastSectionNumber
+	sdt.CRC32 = s.CRC32
+
+	data := s.Data
+	end := len(data) - 4
+	if end < 11 {
+		return SDT{}, ErrSectionTooShort
+	}
+	sdt.OriginalNetworkID = uint16(data[8])<<8 | uint16(data[9])
+	// reserved_future_use 8
+	index := 11
+
+	for index+5 <= end {
+		svc := SDTService{}
+		svc.ServiceID = uint16(data[index])<<8 | uint16(data[index+1])                    // 16
+		svc.EITUserDefinedFlags = (data[index+2] >> 2) & 0x07                             // 3
+		svc.EITScheduleFlag = ((data[index+2] >> 1) & 0x01) == 1                          // 1
+		svc.EITPresentFollowingFlag = (data[index+2] & 0x01) == 1                         // 1
+		svc.RunningStatus = (data[index+3] >> 5) & 0x07                                   // 3
+		svc.FreeCAMode = ((data[index+3] >> 4) & 0x01) == 1                               // 1
+		svc.DescriptorsLoopLength = uint16(data[index+3]&0x0f)<<8 | uint16(data[index+4]) // 12
+		index += 5
+		if index+int(svc.DescriptorsLoopLength) > end {
+			return SDT{}, ErrSectionTooShort
+		}
+		var err error
+		var diff int
+		svc.Descriptors, diff, err = readDescriptor(data, index, int(svc.DescriptorsLoopLength), s.SIOptions)
+		if err != nil {
+			return SDT{}, err
+		}
+		index += diff
+		for _, d := range svc.Descriptors {
+			if d.Tag == 0x48 { // service_descriptor
+				svc.ServiceType = d.ServiceDescriptor.ServiceType
+				svc.ServiceProviderName = d.ServiceDescriptor.ServiceProviderName
+				svc.ServiceName = d.ServiceDescriptor.ServiceName
+			}
+		}
+		sdt.Services = append(sdt.Services, svc)
+	}
+	return sdt, nil
+}
+
+// Encode returns the section from table_id to CRC_32. section_length, descriptors_loop_length
+// and CRC_32 are calculated. A service_descriptor is made from ServiceType, ServiceProviderName
+// and ServiceName in UTF-8 if the service has none in Descriptors.
+// ETSI EN 300 468 V1.17.1 pp.29-30
+func (sdt *SDT) Encode() []byte {
+	tableID := sdt.TableID
+	if tableID != TableID_ServiceDescriptionSection_OtherDVBTransportStream {
+		tableID = TableID_ServiceDescriptionSection_ActualDVBTransportStream
+	}
+	b := []byte{tableID, 0, 0}
+	b = append(b, byte(sdt.TransportStreamID>>8), byte(sdt.TransportStreamID))
+	b = append(b, 0xc0|(sdt.Version&0x1f)<<1|boolToByte(sdt.CurrentNextIndicator), sdt.SectionNumber, sdt.LastSectionNumber)
+	// reserved_future_use 8
+	b = append(b, byte(sdt.OriginalNetworkID>>8), byte(sdt.OriginalNetworkID), 0xff)
+	for _, svc := range sdt.Services {
+		b = append(b, byte(svc.ServiceID>>8), byte(svc.ServiceID))
+		b = append(b, 0xe0|(svc.EITUserDefinedFlags&0x07)<<2|boolToByte(svc.EITScheduleFlag)<<1|boolToByte(svc.EITPresentFollowingFlag))
+		descriptors := svc.Descriptors
+		if !hasDescriptor(descriptors, 0x48) && (svc.ServiceName != "" || svc.ServiceProviderName != "") {
+			descriptors = append(descriptors, svc.serviceDescriptor())
+		}
+		loop := appendDescriptorLoop(nil, descriptors)
+		// running_status 3, free_CA_mode 1 and descriptors_loop_length 12 take the place of the
+		// reserved bits of the loop
+		loop[0] = (svc.RunningStatus&0x07)<<5 | boolToByte(svc.FreeCAMode)<<4 | loop[0]&0x0f
+		b = append(b, loop...)
+	}
+	return finishSection(b)
+}
+
+// serviceDescriptor returns a service_descriptor with the names in UTF-8. The longer name is
+// truncated at a character boundary to keep the descriptor within 255 bytes.
+// ETSI EN 300 468 V1.17.1 p.86
+func (svc *SDTService) serviceDescriptor() ProgramElementDescriptor {
+	texts := [][]byte{}
+	for _, name := range []string{svc.ServiceProviderName, svc.ServiceName} {
+		text := []byte{}
+		if name != "" {
+			// character table selector of UTF-8
+			text = append([]byte{0x15}, name...)
+		}
+		texts = append(texts, text)
+	}
+	// service_type and the 2 lengths take 3 bytes
+	for len(texts[0])+len(texts[1]) > 255-3 {
+		i := 0
+		if len(texts[1]) > len(texts[0]) {
+			i = 1
+		}
+		n := len(texts[i]) - 1
+		for n > 1 && !utf8.RuneStart(texts[i][n]) {
+			n--
+		}
+		texts[i] = texts[i][:n]
+	}
+	raw := []byte{svc.ServiceType}
+	for _, text := range texts {
+		raw = append(raw, byte(len(text)))
+		raw = append(raw, text...)
+	}
+	return ProgramElementDescriptor{Tag: 0x48, Length: byte(len(raw)), Raw: raw}
+}
+
+func hasDescriptor(peds []ProgramElementDescriptor, tag byte) bool {
+	for _, ped := range peds {
+		if ped.Tag == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// JoinSDTServices maps every program of pat to the service of the actual TS SDT sections
+// which has the same service_id as the program_number. The network PID entry is skipped.
+func JoinSDTServices(pat PAT, sdts ...SDT) []ProgramService {
+	services := map[uint16]SDTService{}
+	for _, sdt := range sdts {
+		if !sdt.IsActualTS() || sdt.TransportStreamID != pat.TransportStreamID {
+			continue
+		}
+		for _, svc := range sdt.Services {
+			services[svc.ServiceID] = svc
+		}
+	}
+
+	pss := []ProgramService{}
+	for _, p := range pat.Programs {
+		if p.ProgramNumber == 0x0000 {
+			continue
+		}
+		ps := ProgramService{ProgramNumber: p.ProgramNumber, ProgramMapPID: p.ProgramMapPID}
+		ps.Service, ps.HasService = services[p.ProgramNumber]
+		pss = append(pss, ps)
+	}
+	return pss
+}
@@ -0,0 +1,119 @@
+package mpeg2ts
+
+import (
+	"fmt"
+	"time"
+)
+
+// Software Download Trigger Table
+// ARIB STD-B21 p.141, ARIB STD-B10 Part 2 p.43
+type SDTT struct {
+	TableID                byte   // 8
+	SectionSyntaxIndicator bool   // 1
+	SectionLength          uint16 // 12
+	MakerID                uint8  // 8
+	ModelID                uint8  // 8
+	Version                byte   // 5
+	CurrentNextIndicator   bool   // 1
+	SectionNumber          byte   // 8
+	LastSectionNumber      byte   // 8
+	TransportStreamID      uint16 // 16
+	OriginalNetworkID      uint16 // 16
+	ServiceID              uint16 // 16
+	NumOfContents          uint8  // 8
+	Contents               []SDTTContent
+	CRC32                  uint // 32
+}
+
+type SDTTContent struct {
+	Group                        uint8  // 4
+	TargetVersion                uint16 // 12
+	NewVersion                   uint16 // 12
+	DownloadLevel                uint8  // 2
+	VersionIndicator             uint8  // 2
+	ContentDescriptionLength     uint16 // 12
+	ScheduleDescriptionLength    uint16 // 12
+	ScheduleTimeShiftInformation uint8  // 4
+	Schedules                    []SDTTSchedule
+	Descriptors                  []ProgramElementDescriptor
+}
+
+type SDTTSchedule struct {
+	RAWStartTime uint64 // 40
+	RAWDuration  uint32 // 24
+
+	StartTime time.Time
+	Duration  time.Duration
+}
+
+func (s *Section) ParseSDTT() (SDTT, error) {
+	if s.TableID != TableID_SoftwareDownloadTriggerSection {
+		return SDTT{}, fmt.Errorf("invalid TableID. expected: 0x%02x, actual: 0x%02x", TableID_SoftwareDownloadTriggerSection, s.TableID)
+	}
+	if err := s.CheckCRC(); err != nil {
+		return SDTT{}, err
+	}
+	sdtt := SDTT{}
+	sdtt.TableID = s.TableID
+	sdtt.SectionSyntaxIndicator = s.SectionSyntaxIndicator
+	sdtt.SectionLength = s.SectionLength
+	sdtt.MakerID = uint8(s.TableIDExtension >> 8)
+	sdtt.ModelID = uint8(s.TableIDExtension & 0xff)
+	sdtt.Version = s.Version
+	sdtt.CurrentNextIndicator = s.CurrentNextIndicator
+	sdtt.SectionNumber = s.SectionNumber
+	sdtt.LastSectionNumber = s.LastSectionNumber
+	sdtt.CRC32 = s.CRC32
+
+	data := s.Data
+	end := len(data) - 4
+	if end < 15 {
+		return SDTT{}, ErrSectionTooShort
+	}
+	sdtt.TransportStreamID = uint16(data[8])<<8 | uint16(data[9])
+	sdtt.OriginalNetworkID = uint16(data[10])<<8 | uint16(data[11])
+	sdtt.ServiceID = uint16(data[12])<<8 | uint16(data[13])
+	sdtt.NumOfContents = data[14]
+	index := 15
+
+	for n := 0; n < int(sdtt.NumOfContents); n++ {
+		if index+8 > end {
+			return SDTT{}, ErrSectionTooShort
+		}
+		c := SDTTContent{}
+		c.Group = (data[index] >> 4) & 0x0f                                              // 4
+		c.TargetVersion = uint16(data[index]&0x0f)<<8 | uint16(data[index+1])            // 12
+		c.NewVersion = uint16(data[index+2])<<4 | uint16(data[index+3]>>4)               // 12
+		c.DownloadLevel = (data[index+3] >> 2) & 0x03                                    // 2
+		c.VersionIndicator = data[index+3] & 0x03                                        // 2
+		c.ContentDescriptionLength = uint16(data[index+4])<<4 | uint16(data[index+5]>>4) // 12
+		// reserved 4
+		c.ScheduleDescriptionLength = uint16(data[index+6])<<4 | uint16(data[index+7]>>4) // 12
+		c.ScheduleTimeShiftInformation = data[index+7] & 0x0f                             // 4
+		index += 8
+		if c.ScheduleDescriptionLength > c.ContentDescriptionLength || index+int(c.ContentDescriptionLength) > end {
+			return SDTT{}, ErrSectionTooShort
+		}
+
+		for i := 0; i+8 <= int(c.ScheduleDescriptionLength); i += 8 {
+			sc := SDTTSchedule{}
+			b := data[index+i:]
+			sc.RAWStartTime = uint64(b[0])<<32 | uint64(b[1])<<24 | uint64(b[2])<<16 | uint64(b[3])<<8 | uint64(b[4])
+			sc.RAWDuration = uint32(b[5])<<16 | uint32(b[6])<<8 | uint32(b[7])
+			sc.StartTime = getTimestampByMJD(sc.RAWStartTime, s.SIOptions.orARIB().location())
+			sc.Duration = getDurationByBCD(sc.RAWDuration)
+			c.Schedules = append(c.Schedules, sc)
+		}
+		index += int(c.ScheduleDescriptionLength)
+
+		var err error
+		var diff int
+		c.Descriptors, diff, err = readDescriptor(data, index, int(c.ContentDescriptionLength-c.ScheduleDescriptionLength), s.SIOptions.orARIB())
+		if err != nil {
+			return SDTT{}, err
+		}
+		index += diff
+		sdtt.Contents = append(sdtt.Contents, c)
+	}
+	return sdtt, nil
+}
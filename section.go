@@ -0,0 +1,184 @@
+package mpeg2ts
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrSectionTooShort    = errors.New("section is too short")
+	ErrSectionCRCMismatch = errors.New("CRC32 mismatch")
+)
+
+// PSI/SI section
+// Rec. ITU-T H.222.0 (06/2021) p.50, ETSI EN 300 468 V1.17.1 p.23
+type Section struct {
+	PID                    PID
+	TableID                byte   // 8
+	SectionSyntaxIndicator bool   // 1
+	PrivateIndicator       bool   // 1
+	Reserved1              byte   // 2
+	SectionLength          uint16 // 12
+
+	// section_syntax_indicator == 1
+	TableIDExtension     uint16 // 16
+	Reserved2            byte   // 2
+	Version              byte   // 5
+	CurrentNextIndicator bool   // 1
+	SectionNumber        byte   // 8
+	LastSectionNumber    byte   // 8
+	CRC32                uint   // 32
+
+	// whole section bytes. from table_id to CRC_32
+	Data []byte
+	// conventions of the network which the Parse methods decode SI with
+	SIOptions SIOptions
+}
+
+// ParseSection parses the common header of a section.
+// data must start with table_id and contain at least section_length+3 bytes.
+func ParseSection(data []byte) (Section, error) {
+	s := Section{}
+	if len(data) < 3 {
+		return Section{}, ErrSectionTooShort
+	}
+	s.TableID = data[0]
+	s.SectionSyntaxIndicator = ((data[1] >> 7) & 0x01) == 1
+	s.PrivateIndicator = ((data[1] >> 6) & 0x01) == 1
+	s.Reserved1 = (data[1] >> 4) & 0x03
+	s.SectionLength = uint16(data[1]&0x0F)<<8 | uint16(data[2])
+	if len(data) < int(s.SectionLength)+3 {
+		return Section{}, ErrSectionTooShort
+	}
+	s.Data = make([]byte, int(s.SectionLength)+3)
+	copy(s.Data, data)
+
+	if !s.SectionSyntaxIndicator {
+		return s, nil
+	}
+	if s.SectionLength < 9 {
+		return Section{}, fmt.Errorf("invalid section_length %d", s.SectionLength)
+	}
+	s.TableIDExtension = uint16(data[3])<<8 | uint16(data[4])
+	s.Reserved2 = (data[5] >> 6) & 0x03
+	s.Version = (data[5] >> 1) & 0x1F
+	s.CurrentNextIndicator = (data[5] & 0x01) == 0x01
+	s.SectionNumber = data[6]
+	s.LastSectionNumber = data[7]
+	crcIndex := int(s.SectionLength) - 1
+	s.CRC32 = uint(data[crcIndex])<<24 | uint(data[crcIndex+1])<<16 | uint(data[crcIndex+2])<<8 | uint(data[crcIndex+3])
+	return s, nil
+}
+
+// Body returns the bytes following the section header.
+// CRC_32 is excluded when section_syntax_indicator is set.
+func (s *Section) Body() []byte {
+	if !s.SectionSyntaxIndicator {
+		return s.Data[3:]
+	}
+	return s.Data[8 : len(s.Data)-4]
+}
+
+func (s *Section) CheckCRC() error {
+	if !s.SectionSyntaxIndicator {
+		return nil
+	}
+	if uint32(s.CRC32) != calculateCRC(s.Data[:len(s.Data)-4]) {
+		return ErrSectionCRCMismatch
+	}
+	return nil
+}
+
+// SectionAssembler reassembles sections which span several TS packets.
+type SectionAssembler struct {
+	// set to the sections which are reassembled
+	SIOptions SIOptions
+
+	buffers map[PID]*sectionBuffer
+}
+
+type sectionBuffer struct {
+	data                 []byte
+	started              bool
+	continuityCheckIndex byte
+}
+
+func NewSectionAssembler() SectionAssembler {
+	sa := SectionAssembler{}
+	sa.buffers = map[PID]*sectionBuffer{}
+	return sa
+}
+
+// EnqueueTSPacket feeds a TS packet and returns the sections completed by it.
+func (sa *SectionAssembler) EnqueueTSPacket(p Packet) ([]Section, error) {
+	payload, err := p.GetPayload()
+	if err != nil {
+		return nil, err
+	}
+	if p.TransportErrorIndicator || len(payload) == 0 {
+		return nil, nil
+	}
+
+	sb, ok := sa.buffers[p.PID]
+	if !ok {
+		sb = &sectionBuffer{}
+		sa.buffers[p.PID] = sb
+	} else if sb.started && (sb.continuityCheckIndex+1)%16 != p.ContinuityCheckIndex {
+		if sb.continuityCheckIndex == p.ContinuityCheckIndex {
+			// duplicate packet
+			return nil, nil
+		}
+		// packet lost. drop incomplete section
+		sb.data = sb.data[:0]
+		sb.started = false
+	}
+	sb.continuityCheckIndex = p.ContinuityCheckIndex
+
+	sections := []Section{}
+	if p.PayloadUnitStartIndicator {
+		pointer := int(payload[0])
+		if pointer+1 > len(payload) {
+			sb.data = sb.data[:0]
+			sb.started = false
+			return nil, fmt.Errorf("invalid pointer_field %d", pointer)
+		}
+		if sb.started {
+			sb.data = append(sb.data, payload[1:1+pointer]...)
+			sections = append(sections, sb.extract(p.PID, sa.SIOptions)...)
+		}
+		sb.data = append(sb.data[:0], payload[1+pointer:]...)
+		sb.started = true
+	} else if sb.started {
+		sb.data = append(sb.data, payload...)
+	}
+	sections = append(sections, sb.extract(p.PID, sa.SIOptions)...)
+	return sections, nil
+}
+
+func (sb *sectionBuffer) extract(pid PID, opts SIOptions) []Section {
+	sections := []Section{}
+	for len(sb.data) >= 3 {
+		if sb.data[0] == 0xff {
+			// stuffing bytes. the rest of the packet is discarded
+			sb.data = sb.data[:0]
+			sb.started = false
+			break
+		}
+		length := int(uint16(sb.data[1]&0x0F)<<8|uint16(sb.data[2])) + 3
+		if len(sb.data) < length {
+			break
+		}
+		s, err := ParseSection(sb.data[:length])
+		if err == nil {
+			s.PID = pid
+			s.SIOptions = opts
+			sections = append(sections, s)
+		}
+		sb.data = append(sb.data[:0], sb.data[length:]...)
+	}
+	if len(sb.data) == 0 {
+		// the next section always starts with payload_unit_start_indicator
+		sb.started = false
+	}
+	return sections
+}
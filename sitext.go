@@ -0,0 +1,66 @@
+package mpeg2ts
+
+import "time"
+
+// SIOptions are the conventions of the network which SI tables are decoded with.
+// The zero value decodes DVB SI of ETSI EN 300 468.
+type SIOptions struct {
+	// decodes text fields of SI descriptors and tables. nil for DecodeDVBString.
+	// Strings which start with a DVB character table selector are always decoded by DecodeDVBString.
+	TextDecoder func(b []byte) (string, error)
+	// time zone of MJD/BCD coded times. nil for UTC
+	Location *time.Location
+	// names of content_nibble_level_1. nil for DVBContentGenres
+	ContentGenres map[uint8]string
+	// selects the ARIB STD-B10 meaning of the fields which differ from ETSI EN 300 468
+	ARIB bool
+}
+
+// ARIBSIOptions returns the options of ARIB STD-B10 SI, of which the text is ARIB STD-B24 8-unit code,
+// the times are JST and the genres are ARIBContentGenres.
+func ARIBSIOptions() SIOptions {
+	return SIOptions{TextDecoder: DecodeARIBString, Location: LocationJST, ContentGenres: ARIBContentGenres, ARIB: true}
+}
+
+// orARIB returns the options with the ARIB conventions for the fields which are not set.
+// It is used for the tables which are only defined by ARIB STD-B10.
+func (o SIOptions) orARIB() SIOptions {
+	if o.TextDecoder == nil {
+		o.TextDecoder = DecodeARIBString
+	}
+	if o.Location == nil {
+		o.Location = LocationJST
+	}
+	if o.ContentGenres == nil {
+		o.ContentGenres = ARIBContentGenres
+	}
+	o.ARIB = true
+	return o
+}
+
+func (o SIOptions) location() *time.Location {
+	if o.Location == nil {
+		return time.UTC
+	}
+	return o.Location
+}
+
+func (o SIOptions) contentGenres() map[uint8]string {
+	if o.ContentGenres == nil {
+		return DVBContentGenres
+	}
+	return o.ContentGenres
+}
+
+// decodeText returns as much text as could be decoded. raw bytes are kept in the *Char fields.
+func (o SIOptions) decodeText(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	decoder := o.TextDecoder
+	if decoder == nil || isDVBCharacterTableSelector(b[0]) {
+		decoder = DecodeDVBString
+	}
+	s, _ := decoder(b)
+	return s
+}
@@ -0,0 +1,32 @@
+package mpeg2ts
+
+import "testing"
+
+func TestSIOptions(t *testing.T) {
+	// service_type, service_provider_name "Café" and service_name of ARIB 8-unit code
+	descriptor := fixtureDescriptor(0x48, 0x01, 5, 'C', 'a', 'f', 0xc2, 'e', 2, 0xa4, 0xa2)
+	body := []byte{0x00, 0x01, 0xff, 0x00, 0x01, 0xfc, 0x80 | byte(len(descriptor)>>8), byte(len(descriptor))}
+	body = append(body, descriptor...)
+	s, err := ParseSection(fixtureLongSection(TableID_ServiceDescriptionSection_ActualDVBTransportStream, 1, 0, 0, 0, body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sdt, err := s.ParseSDT()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := sdt.Services[0].ServiceProviderName; name != "Café" {
+		t.Errorf("DVB service_provider_name %q, want %q", name, "Café")
+	}
+
+	s.SIOptions = ARIBSIOptions()
+	sdt, err = s.ParseSDT()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := DecodeARIBString([]byte{0xa4, 0xa2})
+	if name := sdt.Services[0].ServiceName; name == "" || name != want {
+		t.Errorf("ARIB service_name %q, want %q", name, want)
+	}
+}
@@ -23,7 +23,7 @@ type TOT struct {
 	TDT
 	Reserved2         byte   // 4
 	DescriptorsLength uint16 // 12
-	Descriptors       []struct{}
+	Descriptors       []ProgramElementDescriptor
 	CRC32             uint //32
 
 	Timestamp time.Time
@@ -31,13 +31,30 @@ type TOT struct {
 
 var ErrPacketIsTDT = errors.New("This packet is TDT. Use ParseTDT")
 
-func (p *Packet) ParseTDT(acceptTOT bool) (TDT, error) {
+// LocationJST is the time zone of ARIB STD-B10 SI
+var LocationJST = time.FixedZone("JST", 9*60*60)
 
-	tdt := TDT{}
+// ParseTDT parses TDT in UTC. Use Section.ParseTDT with SIOptions for ARIB TDT, which is JST.
+func (p *Packet) ParseTDT(acceptTOT bool) (TDT, error) {
 	payload, err := p.GetPayload()
 	if err != nil {
 		return TDT{}, err
 	}
+	return parseTDT(payload, acceptTOT, SIOptions{})
+}
+
+// ParseTDT parses a section which has been reassembled by SectionAssembler, with the time zone
+// of SIOptions.
+func (s *Section) ParseTDT(acceptTOT bool) (TDT, error) {
+	return parseTDT(append([]byte{0}, s.Data...), acceptTOT, s.SIOptions)
+}
+
+// parseTDT parses a section which follows pointer_field in payload.
+func parseTDT(payload []byte, acceptTOT bool, opts SIOptions) (TDT, error) {
+	tdt := TDT{}
+	if len(payload) < 9 {
+		return TDT{}, ErrSectionTooShort
+	}
 	tdt.TableID = payload[1]
 	if tdt.TableID != TableID_TimeDateSection && tdt.TableID != TableID_TimeOffsetSection {
 		return TDT{}, fmt.Errorf("invalid TableID. expected: 0x70, actual: 0x%02x", tdt.TableID)
@@ -51,33 +68,54 @@ func (p *Packet) ParseTDT(acceptTOT bool) (TDT, error) {
 	tdt.SectionLength = uint16(payload[2]&0x0F)<<8 | uint16(payload[3])
 	tdt.RAWTimestamp = uint64(payload[4])<<32 | uint64(payload[5])<<24 | uint64(payload[6])<<16 | uint64(payload[7])<<8 | uint64(payload[8])
 
-	tdt.Timestamp = getTimestampByMJD(tdt.RAWTimestamp)
+	tdt.Timestamp = getTimestampByMJD(tdt.RAWTimestamp, opts.location())
 	return tdt, nil
 }
 
+// ParseTOT parses TOT in UTC. Use Section.ParseTOT with SIOptions for ARIB TOT, which is JST.
 func (p *Packet) ParseTOT() (TOT, error) {
-
-	tot := TOT{}
 	payload, err := p.GetPayload()
 	if err != nil {
 		return TOT{}, err
 	}
+	return parseTOT(payload, SIOptions{})
+}
+
+// ParseTOT parses a section which has been reassembled by SectionAssembler, with SIOptions.
+func (s *Section) ParseTOT() (TOT, error) {
+	return parseTOT(append([]byte{0}, s.Data...), s.SIOptions)
+}
+
+// parseTOT parses a section which follows pointer_field in payload.
+func parseTOT(payload []byte, opts SIOptions) (TOT, error) {
+	tot := TOT{}
+	if len(payload) < 11 {
+		return TOT{}, ErrSectionTooShort
+	}
 	tot.TableID = payload[1]
 	if tot.TableID != TableID_TimeOffsetSection {
 		return TOT{}, ErrPacketIsTDT
 	}
-	tot.TDT, err = p.ParseTDT(true)
+	var err error
+	tot.TDT, err = parseTDT(payload, true, opts)
 	if err != nil {
 		return TOT{}, err
 	}
+	if int(tot.SectionLength)+4 > len(payload) {
+		return TOT{}, fmt.Errorf("invalid section_length %d", tot.SectionLength)
+	}
 	tot.Reserved2 = (payload[9] >> 4) & 0x0f
 	tot.DescriptorsLength = uint16(payload[9]&0x0f)<<8 | uint16(payload[10])
-	for i := 0; i < int(tot.DescriptorsLength); i++ {
-		// FIXME: implement descriptor
+	if 11+int(tot.DescriptorsLength) > int(tot.SectionLength) {
+		return TOT{}, fmt.Errorf("descriptors_loop_length %d exceeds section", tot.DescriptorsLength)
+	}
+	tot.Descriptors, _, err = readDescriptor(payload, 11, int(tot.DescriptorsLength), opts)
+	if err != nil {
+		return TOT{}, err
 	}
 	tot.CRC32 = uint(payload[tot.SectionLength])<<24 | uint(payload[tot.SectionLength+1])<<16 | uint(payload[tot.SectionLength+2])<<8 | uint(payload[tot.SectionLength+3])
 
-	tot.Timestamp = getTimestampByMJD(tot.RAWTimestamp)
+	tot.Timestamp = getTimestampByMJD(tot.RAWTimestamp, opts.location())
 	crc := calculateCRC(payload[1:tot.SectionLength])
 	if uint32(tot.CRC32) != crc {
 		return TOT{}, errors.New("CRC32 mismatch")
@@ -85,16 +123,69 @@ func (p *Packet) ParseTOT() (TOT, error) {
 	return tot, nil
 }
 
-func getTimestampByMJD(mjd uint64) time.Time {
+// LocalTime returns the timestamp in the local time of the country.
+// country is ISO 3166 alpha-3 code such as "JPN". It returns false when TOT has no
+// local_time_offset_descriptor for the country.
+// local_time_offset is the offset from the time zone of TOT, which is UTC in DVB and JST in ARIB,
+// so the zone of the local time is offset from UTC by both of them.
+func (tot *TOT) LocalTime(country string) (time.Time, bool) {
+	for _, d := range tot.Descriptors {
+		if d.Tag != 0x58 {
+			continue
+		}
+		for _, lto := range d.LocalTimeOffsetDescriptor.Offsets {
+			if iso639String(lto.CountryCode) != country {
+				continue
+			}
+			offset := lto.LocalTimeOffset
+			if !lto.TimeOfChange.IsZero() && !tot.Timestamp.Before(lto.TimeOfChange) {
+				offset = lto.NextTimeOffset
+			}
+			_, base := tot.Timestamp.Zone()
+			return tot.Timestamp.In(time.FixedZone(country, base+int(offset/time.Second))), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Encode returns the section of TDT with Timestamp in the time zone of opts.
+// ETSI EN 300 468 V1.17.1 p.34
+func (tdt *TDT) Encode(opts SIOptions) []byte {
+	mjd := getMJDByTimestamp(tdt.Timestamp, opts.location())
+	// section_syntax_indicator 0, reserved_future_use 1, reserved 2, section_length 5
+	return []byte{TableID_TimeDateSection, 0x70, 0x05, byte(mjd >> 32), byte(mjd >> 24), byte(mjd >> 16), byte(mjd >> 8), byte(mjd)}
+}
+
+func getTimestampByMJD(mjd uint64, loc *time.Location) time.Time {
 	rawDate := mjd >> 24
 	mjdOrigin := time.Date(1858, 11, 17, 0, 00, 00, 00, time.UTC)
 	mjdDate := mjdOrigin.Add(time.Duration(rawDate) * time.Hour * 24)
 	hour := bcdToDec(byte((mjd >> 16) & 0xff))
 	min := bcdToDec(byte((mjd >> 8) & 0xff))
 	sec := bcdToDec(byte((mjd) & 0xff))
-	return mjdDate.Add(time.Duration(hour) * time.Hour).Add(time.Duration(min) * time.Minute).Add(time.Duration(sec) * time.Second)
+	return time.Date(mjdDate.Year(), mjdDate.Month(), mjdDate.Day(), int(hour), int(min), int(sec), 0, loc)
+}
+
+// getMJDByTimestamp returns 16bit MJD and 24bit BCD coded time of t in loc.
+func getMJDByTimestamp(t time.Time, loc *time.Location) uint64 {
+	t = t.In(loc)
+	date := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	mjd := uint64(date.Sub(time.Date(1858, 11, 17, 0, 0, 0, 0, time.UTC)) / (24 * time.Hour))
+	return mjd<<24 | uint64(decToBCD(byte(t.Hour())))<<16 | uint64(decToBCD(byte(t.Minute())))<<8 | uint64(decToBCD(byte(t.Second())))
+}
+
+// 24bit BCD coded hh:mm:ss
+func getDurationByBCD(bcd uint32) time.Duration {
+	hour := bcdToDec(byte((bcd >> 16) & 0xff))
+	min := bcdToDec(byte((bcd >> 8) & 0xff))
+	sec := bcdToDec(byte(bcd & 0xff))
+	return time.Duration(hour)*time.Hour + time.Duration(min)*time.Minute + time.Duration(sec)*time.Second
 }
 
 func bcdToDec(bcd byte) byte {
 	return bcd>>4*10 + bcd&0x0f
 }
+
+func decToBCD(dec byte) byte {
+	return dec/10<<4 | dec%10
+}
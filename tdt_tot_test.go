@@ -0,0 +1,112 @@
+package mpeg2ts
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTDTTOT(t *testing.T) {
+	m := loadFixture(t, "time.ts", fixtureTimeStream)
+	packets := m.PacketList.All()
+	if len(packets) != 2 {
+		t.Fatalf("%d packets, want 2", len(packets))
+	}
+
+	tdt, err := packets[0].ParseTDT(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tdt.Timestamp.Equal(fixtureTime) {
+		t.Errorf("TDT is %s, want %s", tdt.Timestamp, fixtureTime)
+	}
+	if _, err := packets[0].ParseTOT(); err != ErrPacketIsTDT {
+		t.Errorf("ParseTOT of TDT returns %v", err)
+	}
+	if _, err := packets[1].ParseTDT(false); err == nil {
+		t.Error("TOT is parsed as TDT")
+	}
+
+	tot, err := packets[1].ParseTOT()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "tdt", tdt)
+	checkGolden(t, "tot", tot)
+
+	tests := []struct {
+		country string
+		offset  time.Duration
+	}{
+		{"JPN", 9 * time.Hour},
+		// before time_of_change
+		{"DEU", 1 * time.Hour},
+	}
+	for _, tt := range tests {
+		lt, ok := tot.LocalTime(tt.country)
+		if !ok {
+			t.Errorf("no local time of %s", tt.country)
+			continue
+		}
+		if _, offset := lt.Zone(); offset != int(tt.offset/time.Second) || !lt.Equal(fixtureTime) {
+			t.Errorf("local time of %s is %s", tt.country, lt)
+		}
+	}
+	if _, ok := tot.LocalTime("USA"); ok {
+		t.Error("local time of USA is found")
+	}
+}
+
+func TestARIBTOT(t *testing.T) {
+	// JST 21:34:56 and JPN local_time_offset 0, which is the offset from JST
+	tot := append([]byte{TableID_TimeOffsetSection, 0x70, 0x00}, fixtureMJD(time.Date(2024, 3, 10, 21, 34, 56, 0, time.UTC))...)
+	tot = append(tot, fixtureLoop(fixtureDescriptor(0x58, 'J', 'P', 'N', 0x02, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00, 0x00))...)
+	length := len(tot) - 3 + 4
+	tot[1], tot[2] = 0x70|byte(length>>8), byte(length)
+	crc := calculateCRC(tot)
+	s, err := ParseSection(append(tot, byte(crc>>24), byte(crc>>16), byte(crc>>8), byte(crc)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SIOptions = ARIBSIOptions()
+
+	parsed, err := s.ParseTOT()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !parsed.Timestamp.Equal(fixtureTime) {
+		t.Errorf("TOT is %s, want %s", parsed.Timestamp, fixtureTime)
+	}
+	lt, ok := parsed.LocalTime("JPN")
+	if _, offset := lt.Zone(); !ok || offset != 9*60*60 || !lt.Equal(fixtureTime) {
+		t.Errorf("local time of JPN is %s", lt)
+	}
+	if tdt := (&TDT{Timestamp: fixtureTime}).Encode(ARIBSIOptions()); string(tdt[3:]) != string(tot[3:8]) {
+		t.Errorf("TDT in JST is encoded to %x", tdt)
+	}
+}
+
+func TestClockMapARIB(t *testing.T) {
+	// PCR of 0s and 1s around TDT of JST 21:34:56
+	tdt := append([]byte{TableID_TimeDateSection, 0x70, 0x05}, fixtureMJD(time.Date(2024, 3, 10, 21, 34, 56, 0, time.UTC))...)
+	b := fixturePacket(fixtureVideoPID, false, 0, append([]byte{0x10}, fixturePCR(0, 0)...), nil)
+	b = append(b, fixtureSectionPackets(PID_TDT_TOT, 0, tdt)...)
+	b = append(b, fixturePacket(fixtureVideoPID, false, 0, append([]byte{0x10}, fixturePCR(PTSClockFrequency, 0)...), nil)...)
+	pl, _ := NewPacketList(PacketSizeDefault)
+	for ; len(b) > 0; b = b[PacketSizeDefault:] {
+		if err := pl.AddBytes(b[:PacketSizeDefault], PacketSizeDefault); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cm := NewClockMap(fixtureVideoPID, ARIBSIOptions())
+	for _, p := range pl.All() {
+		cm.EnqueueTSPacket(p)
+	}
+	if wc, err := cm.WallClockAt(1); err != nil || !wc.Equal(fixtureTime) {
+		t.Errorf("wall clock of TDT is %s, %v, want %s", wc, err, fixtureTime)
+	}
+	// TDT is the middle of the PCRs
+	if pcr, err := cm.PCRAt(fixtureTime); err != nil || pcr != SystemClockFrequency/2 {
+		t.Errorf("PCR of TDT is %d, %v", pcr, err)
+	}
+}
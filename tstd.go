@@ -0,0 +1,216 @@
+package mpeg2ts
+
+import (
+	"fmt"
+)
+
+const (
+	// Rec. ITU-T H.222.0 (06/2021) pp.14-15
+	tstdTBSize = 512
+	// Rx and BSn of audio elementary streams
+	tstdAudioRx     = 2000000
+	tstdAudioBSize  = 3584
+	tstdAC3BSize    = 2592 // ATSC A/52:2018 Annex A
+	tstdDefaultRmax = 15000000
+	// BSoh of AVC and HEVC holds 1/750s of the rate
+	tstdOverheadRate = 750
+)
+
+// TSTDBuffer is the buffers of an elementary stream decoder in the transport stream system
+// target decoder. Sizes are in bytes and rates are in bit/s. Streams with MBSize 0 have a single
+// buffer B of EBSize after TB, like audio streams.
+// Rec. ITU-T H.222.0 (06/2021) pp.12-18
+type TSTDBuffer struct {
+	TBSize int
+	// TB to MB, or to B
+	Rx     int
+	MBSize int
+	// MB to EB by the leak method
+	Rbx    int
+	EBSize int
+}
+
+// DefaultTSTDBuffer returns the buffers for the stream type. maxBitrate is Rmax of a video stream
+// in bit/s, or 0 for 15Mbit/s of MPEG-2 MP@ML. EB of a video stream holds as long as the VBV
+// buffer of MP@ML does at the rate.
+func DefaultTSTDBuffer(st StreamType, maxBitrate int) TSTDBuffer {
+	b := TSTDBuffer{TBSize: tstdTBSize, Rx: tstdAudioRx, EBSize: tstdAudioBSize}
+	switch {
+	case st.IsVideo():
+		if maxBitrate <= 0 {
+			maxBitrate = tstdDefaultRmax
+		}
+		overhead := maxBitrate * 6 / 5
+		if overhead < 2000000 {
+			overhead = 2000000
+		}
+		b.Rx = maxBitrate * 6 / 5
+		b.Rbx = maxBitrate * 6 / 5
+		// BSmux of 4ms and BSoh
+		b.MBSize = maxBitrate/8*4/1000 + overhead/tstdOverheadRate/8
+		// vbv_buffer_size of MP@ML is 1835008 bits
+		b.EBSize = int(int64(maxBitrate) * 1835008 / tstdDefaultRmax / 8)
+	case st == StreamTypeATSC_AC3 || st == StreamTypeATSC_EAC3:
+		b.EBSize = tstdAC3BSize
+	}
+	return b
+}
+
+// TSTDViolation is an overflow or an underflow of a T-STD buffer. The muxer returns it instead of
+// writing the packets which cause it.
+type TSTDViolation struct {
+	PID PID
+	// "TB", "MB", "EB" or "B"
+	Buffer    string
+	Underflow bool
+	// system time clock in 27MHz when the violation occurs
+	Clock int64
+	// bytes in the buffer, and the buffer size or the access unit size of an underflow
+	Level int
+	Size  int
+}
+
+func (v *TSTDViolation) Error() string {
+	if v.Underflow {
+		return fmt.Sprintf("T-STD %s underflow on PID 0x%04x at %d: %d bytes of %d bytes access unit", v.Buffer, v.PID, v.Clock, v.Level, v.Size)
+	}
+	return fmt.Sprintf("T-STD %s overflow on PID 0x%04x at %d: %d bytes in %d bytes", v.Buffer, v.PID, v.Clock, v.Level, v.Size)
+}
+
+// tstdModel follows the fullness of the buffers of an elementary stream.
+type tstdModel struct {
+	TSTDBuffer
+	pid PID
+	// packets in TB. bytes which are not delivered to MB, like the headers, come first
+	tb      []tstdChunk
+	tbLevel float64
+	mb      float64
+	eb      float64
+	// access units in the order of decoding
+	removals []tstdRemoval
+	clock    int64
+}
+
+type tstdChunk struct {
+	discard float64
+	es      float64
+}
+
+type tstdRemoval struct {
+	clock int64
+	size  int
+}
+
+func newTSTDModel(pid PID, buffer TSTDBuffer, clock int64) tstdModel {
+	return tstdModel{TSTDBuffer: buffer, pid: pid, clock: clock}
+}
+
+// addAccessUnit schedules the removal of size bytes from EB, or B, at the decoding time.
+func (m *tstdModel) addAccessUnit(clock int64, size int) {
+	m.removals = append(m.removals, tstdRemoval{clock: clock, size: size})
+}
+
+// canReceive reports whether a packet fits in TB and the bytes in the buffers do not
+// exceed MB and EB in total.
+func (m *tstdModel) canReceive(discard, es int) bool {
+	if m.tbLevel+float64(discard+es) > float64(m.TBSize) {
+		return false
+	}
+	inTB := 0.0
+	for _, c := range m.tb {
+		inTB += c.es
+	}
+	return inTB+m.mb+m.eb+float64(es) <= float64(m.MBSize+m.EBSize)
+}
+
+// receive puts a packet into TB.
+func (m *tstdModel) receive(discard, es int) *TSTDViolation {
+	m.tb = append(m.tb, tstdChunk{discard: float64(discard), es: float64(es)})
+	m.tbLevel += float64(discard + es)
+	if m.tbLevel > float64(m.TBSize) {
+		return m.violation("TB", false, m.tbLevel, m.TBSize)
+	}
+	return nil
+}
+
+// advance drains the buffers up to the clock and removes the access units whose decoding time
+// has come.
+func (m *tstdModel) advance(clock int64) *TSTDViolation {
+	for len(m.removals) > 0 && m.removals[0].clock <= clock {
+		r := m.removals[0]
+		if v := m.leak(r.clock); v != nil {
+			return v
+		}
+		if m.eb+1e-6 < float64(r.size) {
+			return m.violation(m.ebName(), true, m.eb, r.size)
+		}
+		m.eb -= float64(r.size)
+		m.removals = m.removals[1:]
+	}
+	return m.leak(clock)
+}
+
+func (m *tstdModel) leak(clock int64) *TSTDViolation {
+	if clock <= m.clock {
+		return nil
+	}
+	seconds := float64(clock-m.clock) / SystemClockFrequency
+	m.clock = clock
+
+	out := float64(m.Rx) / 8 * seconds
+	delivered := 0.0
+	for out > 0 && len(m.tb) > 0 {
+		c := &m.tb[0]
+		n := min64(out, c.discard)
+		c.discard -= n
+		out -= n
+		m.tbLevel -= n
+		n = min64(out, c.es)
+		c.es -= n
+		out -= n
+		m.tbLevel -= n
+		delivered += n
+		if c.discard <= 0 && c.es <= 0 {
+			m.tb = m.tb[1:]
+		}
+	}
+	if len(m.tb) == 0 {
+		m.tbLevel = 0
+	}
+
+	if m.MBSize == 0 {
+		m.eb += delivered
+	} else {
+		m.mb += delivered
+		n := min64(min64(float64(m.Rbx)/8*seconds, m.mb), float64(m.EBSize)-m.eb)
+		if n > 0 {
+			m.mb -= n
+			m.eb += n
+		}
+		if m.mb > float64(m.MBSize) {
+			return m.violation("MB", false, m.mb, m.MBSize)
+		}
+	}
+	if m.eb > float64(m.EBSize)+1e-6 {
+		return m.violation(m.ebName(), false, m.eb, m.EBSize)
+	}
+	return nil
+}
+
+func (m *tstdModel) ebName() string {
+	if m.MBSize == 0 {
+		return "B"
+	}
+	return "EB"
+}
+
+func (m *tstdModel) violation(buffer string, underflow bool, level float64, size int) *TSTDViolation {
+	return &TSTDViolation{PID: m.pid, Buffer: buffer, Underflow: underflow, Clock: m.clock, Level: int(level), Size: size}
+}
+
+func min64(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
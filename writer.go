@@ -0,0 +1,213 @@
+package mpeg2ts
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+const (
+	// BDAV MPEG-2 transport stream packet with TP_extra_header
+	PacketSizeM2TS = 192
+
+	// 7 packets fill an UDP datagram on Ethernet
+	packetWriterDefaultBatchSize = 7
+	// bits of arrival_time_stamp
+	m2tsArrivalTimeWrap = 1 << 30
+)
+
+// PacketWriter writes packets to files, pipes or sockets. Packets are converted to PacketSize and
+// a batch of BatchSize packets is written by one Write call.
+type PacketWriter struct {
+	// 188, 192 (TP_extra_header and the packet) or 204 (the packet and 16 zero bytes of FEC)
+	PacketSize int
+	BatchSize  int
+	// encodes the header and the adaptation field from the fields of Packet instead of copying Data
+	Reencode bool
+	// arrival_time_stamp of 192 bytes packets is the clock at Packet.Index if ArrivalClock is set,
+	// or the time of the byte position at Bitrate. It is 0 if neither is set.
+	ArrivalClock *ClockMap
+	Bitrate      int
+
+	w       io.Writer
+	buffer  []byte
+	packets int
+	// packets and bytes which have been written
+	count   int64
+	written int64
+	mutex   *sync.Mutex
+}
+
+func NewPacketWriter(w io.Writer, packetSize int) (PacketWriter, error) {
+	switch packetSize {
+	case PacketSizeDefault, PacketSizeM2TS, PacketSizeWithFEC:
+	default:
+		return PacketWriter{}, fmt.Errorf("unsupported packet size %d", packetSize)
+	}
+	pw := PacketWriter{PacketSize: packetSize, BatchSize: packetWriterDefaultBatchSize, w: w}
+	pw.mutex = &sync.Mutex{}
+	return pw, nil
+}
+
+// WritePacket appends a packet to the batch and writes the batch when it is full.
+func (pw *PacketWriter) WritePacket(p Packet) error {
+	pw.mutex.Lock()
+	defer pw.mutex.Unlock()
+
+	body, err := packetBody(p)
+	if err != nil {
+		return err
+	}
+	if pw.Reencode {
+		body = p.Encode()
+	}
+	if pw.PacketSize == PacketSizeM2TS {
+		ats, err := pw.arrivalTime(p)
+		if err != nil {
+			return err
+		}
+		// copy_permission_indicator 2, arrival_time_stamp 30
+		pw.buffer = append(pw.buffer, byte(ats>>24)&0x3f, byte(ats>>16), byte(ats>>8), byte(ats))
+	}
+	pw.buffer = append(pw.buffer, body...)
+	if pw.PacketSize == PacketSizeWithFEC {
+		pw.buffer = append(pw.buffer, make([]byte, PacketSizeWithFEC-PacketSizeDefault)...)
+	}
+	pw.packets++
+	pw.count++
+	if pw.packets >= pw.BatchSize {
+		return pw.flush()
+	}
+	return nil
+}
+
+func (pw *PacketWriter) arrivalTime(p Packet) (int64, error) {
+	clock := int64(0)
+	switch {
+	case pw.ArrivalClock != nil:
+		c, err := pw.ArrivalClock.ClockAt(p.Index)
+		if err != nil {
+			return 0, err
+		}
+		clock = c
+	case pw.Bitrate > 0:
+		clock = bitsToClock((pw.count*PacketSizeM2TS+4)*8, pw.Bitrate)
+	}
+	clock %= m2tsArrivalTimeWrap
+	if clock < 0 {
+		clock += m2tsArrivalTimeWrap
+	}
+	return clock, nil
+}
+
+// Flush writes the packets in the batch.
+func (pw *PacketWriter) Flush() error {
+	pw.mutex.Lock()
+	defer pw.mutex.Unlock()
+	return pw.flush()
+}
+
+func (pw *PacketWriter) flush() error {
+	if len(pw.buffer) == 0 {
+		return nil
+	}
+	n, err := pw.w.Write(pw.buffer)
+	pw.written += int64(n)
+	pw.buffer = pw.buffer[:0]
+	pw.packets = 0
+	return err
+}
+
+// Written returns the bytes which have been written to the writer.
+func (pw *PacketWriter) Written() int64 {
+	pw.mutex.Lock()
+	defer pw.mutex.Unlock()
+	return pw.written
+}
+
+// Run writes the packets from the channel until it is closed or ctx is done, and flushes the batch.
+func (pw *PacketWriter) Run(ctx context.Context, packets <-chan Packet) error {
+	for {
+		select {
+		case <-ctx.Done():
+			if err := pw.Flush(); err != nil {
+				return err
+			}
+			return ctx.Err()
+		case p, ok := <-packets:
+			if !ok {
+				return pw.Flush()
+			}
+			if err := pw.WritePacket(p); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// WriteTo writes all packets in the packet size of the stream. 204 bytes packets get zero FEC bytes,
+// because the parity of the source is not kept.
+func (m *MPEG2TS) WriteTo(w io.Writer) (int64, error) {
+	size := m.chunkSize
+	switch size {
+	case PacketSizeDefault, PacketSizeM2TS, PacketSizeWithFEC:
+	default:
+		size = PacketSizeDefault
+	}
+	pw, err := NewPacketWriter(w, size)
+	if err != nil {
+		return 0, err
+	}
+	for _, p := range m.PacketList.All() {
+		if err := pw.WritePacket(p); err != nil {
+			return pw.Written(), err
+		}
+	}
+	err = pw.Flush()
+	return pw.Written(), err
+}
+
+// packetBody returns the 188 bytes of the packet without TP_extra_header or FEC bytes.
+func packetBody(p Packet) ([]byte, error) {
+	switch {
+	case len(p.Data) == PacketSizeM2TS && p.Data[0] != 0x47 && p.Data[4] == 0x47:
+		return p.Data[4:], nil
+	case len(p.Data) >= PacketSizeDefault && p.Data[0] == 0x47:
+		return p.Data[:PacketSizeDefault], nil
+	}
+	return nil, fmt.Errorf("packet %d is not a transport stream packet", p.Index)
+}
+
+// Encode returns the 188 bytes of the packet with the header and the adaptation field encoded from
+// the fields. The layout of Data is kept, so the fields which change the size of the adaptation
+// field, like PCRFlag, are not encoded.
+// Rec. ITU-T H.222.0 (06/2021) pp.22-29
+func (p *Packet) Encode() []byte {
+	b := make([]byte, PacketSizeDefault)
+	if body, err := packetBody(*p); err == nil {
+		copy(b, body)
+	}
+	b[0] = 0x47
+	b[1] = boolToByte(p.TransportErrorIndicator)<<7 | boolToByte(p.PayloadUnitStartIndicator)<<6 | boolToByte(p.TransportPriorityIndicator)<<5 | byte(p.PID>>8)&0x1f
+	b[2] = byte(p.PID)
+	b[3] = (p.TransportScrambleControl&0x03)<<6 | (p.AdaptationFieldControl&0x03)<<4 | p.ContinuityCheckIndex&0x0f
+	if !p.HasAdaptationField() || p.AdaptationField.Length == 0 {
+		return b
+	}
+	af := &p.AdaptationField
+	b[5] = b[5]&0x1f | boolToByte(af.DiscontinuityIndicator)<<7 | boolToByte(af.RandomAccessIndicator)<<6 | boolToByte(af.ESPriorityIndicator)<<5
+	fieldIndex := 6
+	if b[5]&0x10 != 0 {
+		setPCR(b[fieldIndex:], pcrValue(af.ProgramClockReference))
+		fieldIndex += 6
+	}
+	if b[5]&0x08 != 0 {
+		setPCR(b[fieldIndex:], pcrValue(af.OriginalProgramClockReference))
+		fieldIndex += 6
+	}
+	if b[5]&0x04 != 0 {
+		b[fieldIndex] = af.SpliceCountdown
+	}
+	return b
+}